@@ -7,7 +7,9 @@ package sortedkv
 type Batch interface {
 	Writer // Put and Delete
 
-	// Apply performs all batched actions on the database.
+	// Apply performs all batched actions on the database. Unlike
+	// Writer.Delete, deleting a key that is not present does not make Apply
+	// fail.
 	Apply() error
 
 	// Reset resets the batch so that it doesn't contain any items and can be reused.
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+type (
+	// batch invalidates the keys of an underlying batch when it is applied.
+	batch struct {
+		b      sortedkv.Batch
+		d      *Database
+		keys   []string
+		ranges []keyRange
+	}
+
+	keyRange struct{ start, end string }
+)
+
+// Put puts a value into the batch.
+func (b *batch) Put(key string, value string) error {
+	b.keys = append(b.keys, key)
+	return b.b.Put(key, value)
+}
+
+// PutBytes puts a value into the batch.
+func (b *batch) PutBytes(key string, value []byte) error {
+	b.keys = append(b.keys, key)
+	return b.b.PutBytes(key, value)
+}
+
+// Delete deletes a key in the batch.
+func (b *batch) Delete(key string) error {
+	b.keys = append(b.keys, key)
+	return b.b.Delete(key)
+}
+
+// DeleteRange deletes all keys in the range [start, end) in the batch. It
+// fails if the underlying batch is not a sortedkv.RangeDeleter.
+func (b *batch) DeleteRange(start string, end string) error {
+	rd, ok := b.b.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeleteRange"}
+	}
+	b.ranges = append(b.ranges, keyRange{start, end})
+	return rd.DeleteRange(start, end)
+}
+
+// DeletePrefix deletes all keys with the given prefix in the batch. It fails
+// if the underlying batch is not a sortedkv.RangeDeleter.
+func (b *batch) DeletePrefix(prefix string) error {
+	rd, ok := b.b.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeletePrefix"}
+	}
+	b.ranges = append(b.ranges, keyRange{prefix, key.IncPrefix(prefix)})
+	return rd.DeletePrefix(prefix)
+}
+
+// Apply applies the underlying batch and invalidates its keys afterwards,
+// even if it failed.
+func (b *batch) Apply() error {
+	defer func() {
+		b.d.invalidate(b.keys...)
+		for _, r := range b.ranges {
+			b.d.invalidateRange(r.start, r.end)
+		}
+	}()
+	return b.b.Apply()
+}
+
+// Reset resets the underlying batch.
+func (b *batch) Reset() {
+	b.b.Reset()
+	b.keys, b.ranges = nil, nil
+}
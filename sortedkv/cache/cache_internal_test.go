@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestGeneric(t *testing.T) {
+	test.GenericDecoratorTest(t, func() sortedkv.Database {
+		return New(memorydb.NewDatabase(), 1<<10)
+	})
+	t.Run("Watch", func(t *testing.T) {
+		test.GenericWatchTest(t, sortedkv.NewWatchable(New(memorydb.NewDatabase(), 1<<10)))
+	})
+	t.Run("Compact", func(t *testing.T) {
+		test.GenericCompactTest(t, New(memorydb.NewDatabase(), 1<<10))
+	})
+}
+
+func TestDatabase_Hits(t *testing.T) {
+	plain := memorydb.FromData(map[string]string{"a": "1"})
+	db := New(plain, 1<<10)
+
+	for i := 0; i < 3; i++ {
+		v, err := db.Get("a")
+		require.NoError(t, err)
+		assert.Equal(t, "1", v)
+		_, err = db.GetBytes("missing")
+		assert.True(t, sortedkv.IsNotFound(err))
+		has, err := db.Has("missing")
+		require.NoError(t, err)
+		assert.False(t, has)
+	}
+	assert.Equal(t, CacheStats{Hits: 7, NegativeHits: 5, Misses: 2, Entries: 2, Bytes: len("a1missing")}, db.CacheStats())
+
+	// The cache answers without the underlying database.
+	require.NoError(t, plain.Put("a", "stale"))
+	require.NoError(t, plain.Put("missing", "stale"))
+	v, err := db.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", v)
+	_, err = db.Get("missing")
+	assert.True(t, sortedkv.IsNotFound(err))
+}
+
+func TestDatabase_Invalidation(t *testing.T) {
+	db := New(memorydb.NewDatabase(), 1<<10)
+	get := func(key string) string {
+		v, err := db.Get(key)
+		if sortedkv.IsNotFound(err) {
+			return "<absent>"
+		}
+		require.NoError(t, err)
+		return v
+	}
+
+	assert.Equal(t, "<absent>", get("a"))
+	require.NoError(t, db.Put("a", "1"))
+	assert.Equal(t, "1", get("a"))
+	require.NoError(t, db.PutBytes("a", []byte("2")))
+	assert.Equal(t, "2", get("a"))
+	require.NoError(t, db.Delete("a"))
+	assert.Equal(t, "<absent>", get("a"))
+
+	b := db.NewBatch()
+	require.NoError(t, b.Put("a", "3"))
+	require.NoError(t, b.Put("b", "4"))
+	assert.Equal(t, "<absent>", get("b"))
+	require.NoError(t, b.Apply())
+	assert.Equal(t, "3", get("a"))
+	assert.Equal(t, "4", get("b"))
+
+	b.Reset()
+	require.NoError(t, b.(sortedkv.RangeDeleter).DeletePrefix("a"))
+	require.NoError(t, b.Apply())
+	assert.Equal(t, "<absent>", get("a"))
+	assert.Equal(t, "4", get("b"))
+
+	require.NoError(t, db.DeleteRange("", ""))
+	assert.Equal(t, "<absent>", get("b"))
+
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx.Put("c", "5"))
+	assert.Equal(t, "<absent>", get("c"))
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, "5", get("c"))
+}
+
+func TestDatabase_Eviction(t *testing.T) {
+	db := New(memorydb.NewDatabase(), 10)
+	require.NoError(t, db.Put("a", "1234"))  // 5 bytes.
+	require.NoError(t, db.Put("b", "1234"))  // 5 bytes.
+	require.NoError(t, db.Put("c", "12345")) // 6 bytes.
+	require.NoError(t, db.Put("big", "12345678"))
+
+	for _, k := range []string{"a", "b", "a", "c", "big"} {
+		_, err := db.Get(k)
+		require.NoError(t, err)
+	}
+	// Adding c evicts b, the least recently used entry, and then a. big does
+	// not fit at all.
+	s := db.CacheStats()
+	assert.Equal(t, uint64(2), s.Evictions)
+	assert.Equal(t, 1, s.Entries)
+	assert.Equal(t, 6, s.Bytes)
+
+	_, err := db.Get("c")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), db.CacheStats().Hits)
+}
+
+func TestDatabase_Close(t *testing.T) {
+	db := New(memorydb.NewDatabase(), 1<<10)
+	require.NoError(t, db.Put("a", "1"))
+	_, err := db.Get("a")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	_, err = db.Get("a")
+	assert.True(t, sortedkv.IsClosed(err))
+}
+
+// racyDB calls afterGet after every Get.
+type racyDB struct {
+	sortedkv.Database
+	afterGet func()
+}
+
+func (r *racyDB) Get(key string) (string, error) {
+	value, err := r.Database.Get(key)
+	if r.afterGet != nil {
+		r.afterGet()
+	}
+	return value, err
+}
+
+func TestDatabase_WriteDuringMiss(t *testing.T) {
+	racy := &racyDB{Database: memorydb.FromData(map[string]string{"a": "1"})}
+	db := New(racy, 1<<10)
+	racy.afterGet = func() {
+		racy.afterGet = nil
+		require.NoError(t, db.Put("a", "2"))
+	}
+
+	// The first Get overlaps with the Put and may return either value, but it
+	// must not cache the old one.
+	_, err := db.Get("a")
+	require.NoError(t, err)
+	v, err := db.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "2", v)
+}
+
+// TestDatabase_Concurrent checks that readers never see a value older than
+// the last completed write.
+func TestDatabase_Concurrent(t *testing.T) {
+	db := New(memorydb.NewDatabase(), 64)
+	const n = 500
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		written = make(map[string]int)
+	)
+	for w := 0; w < 4; w++ {
+		key := strconv.Itoa(w)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= n; i++ {
+				require.NoError(t, db.Put(key, strconv.Itoa(i)))
+				mu.Lock()
+				written[key] = i
+				mu.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				mu.Lock()
+				min := written[key]
+				mu.Unlock()
+				v, err := db.Get(key)
+				if sortedkv.IsNotFound(err) {
+					assert.Zero(t, min)
+					continue
+				}
+				require.NoError(t, err)
+				got, _ := strconv.Atoi(v)
+				assert.GreaterOrEqual(t, got, min, "stale read of %s", key)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+type (
+	// Database caches the reads of an underlying database. It implements
+	// sortedkv.Database, sortedkv.RangeDeleter, sortedkv.Snapshotter,
+	// sortedkv.Transactor, sortedkv.Watcher, sortedkv.Compacter and
+	// sortedkv.Stater. The optional operations fail
+	// with a *sortedkv.NotSupportedError if the underlying database does not
+	// support them.
+	Database struct {
+		db sortedkv.Database
+
+		mu    sync.Mutex
+		cache *lru
+		// gen is incremented by every invalidation. A lookup only caches its
+		// result if no invalidation happened while it read the underlying
+		// database, so that it cannot cache a value that was just overwritten.
+		gen   uint64
+		stats CacheStats
+	}
+
+	// CacheStats are the statistics of a cache.
+	CacheStats struct {
+		Hits         uint64 // Lookups that were answered by the cache.
+		NegativeHits uint64 // Hits of cached absent keys, included in Hits.
+		Misses       uint64 // Lookups that read the underlying database.
+		Evictions    uint64 // Entries that were evicted to free space.
+
+		Entries int // Number of cached entries.
+		Bytes   int // Size of the cached keys and values.
+	}
+)
+
+// New wraps db with a cache that holds at most capacity bytes of keys and
+// values.
+func New(db sortedkv.Database, capacity int) *Database {
+	return &Database{db: db, cache: newLRU(capacity)}
+}
+
+// CacheStats returns the statistics of the cache.
+func (d *Database) CacheStats() CacheStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.stats
+	s.Entries, s.Bytes = len(d.cache.entries), d.cache.size
+	return s
+}
+
+// Has returns whether a key exists. Absent keys are cached.
+func (d *Database) Has(key string) (bool, error) {
+	e, gen, ok := d.lookup(key)
+	if ok {
+		return e.found, nil
+	}
+	has, err := d.db.Has(key)
+	if err == nil && !has {
+		d.add(&entry{key: key}, gen)
+	}
+	return has, err
+}
+
+// Get returns the value of a key.
+func (d *Database) Get(key string) (string, error) {
+	e, gen, ok := d.lookup(key)
+	if !ok {
+		value, err := d.db.Get(key)
+		if err != nil && !sortedkv.IsNotFound(err) {
+			return "", err
+		}
+		e = &entry{key: key, value: value, found: err == nil}
+		d.add(e, gen)
+	}
+	if !e.found {
+		return "", &sortedkv.NotFoundError{Key: key}
+	}
+	return e.value, nil
+}
+
+// GetBytes returns the value of a key as []byte.
+func (d *Database) GetBytes(key string) ([]byte, error) {
+	value, err := d.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// lookup returns the cached entry of a key. On a miss, it returns the
+// generation to pass to add.
+func (d *Database) lookup(key string) (*entry, uint64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.cache.get(key)
+	if !ok {
+		d.stats.Misses++
+		return nil, d.gen, false
+	}
+	d.stats.Hits++
+	if !e.found {
+		d.stats.NegativeHits++
+	}
+	return e, 0, true
+}
+
+// add caches an entry that was read at generation gen, unless an
+// invalidation happened since then.
+func (d *Database) add(e *entry, gen uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.gen == gen {
+		d.stats.Evictions += uint64(d.cache.add(e))
+	}
+}
+
+// invalidate removes keys from the cache.
+func (d *Database) invalidate(keys ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.gen++
+	for _, k := range keys {
+		d.cache.remove(k)
+	}
+}
+
+// invalidateRange removes the keys in the range [start, end) from the cache.
+func (d *Database) invalidateRange(start, end string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.gen++
+	d.cache.removeRange(start, end)
+}
+
+// Put saves a value under a key.
+func (d *Database) Put(key string, value string) error {
+	defer d.invalidate(key)
+	return d.db.Put(key, value)
+}
+
+// PutBytes saves a value under a key.
+func (d *Database) PutBytes(key string, value []byte) error {
+	defer d.invalidate(key)
+	return d.db.PutBytes(key, value)
+}
+
+// Delete deletes a key.
+func (d *Database) Delete(key string) error {
+	defer d.invalidate(key)
+	return d.db.Delete(key)
+}
+
+// DeleteRange deletes all keys in the range [start, end).
+func (d *Database) DeleteRange(start string, end string) error {
+	db, ok := d.db.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeleteRange"}
+	}
+	defer d.invalidateRange(start, end)
+	return db.DeleteRange(start, end)
+}
+
+// DeletePrefix deletes all keys with the given prefix.
+func (d *Database) DeletePrefix(prefix string) error {
+	db, ok := d.db.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeletePrefix"}
+	}
+	defer d.invalidateRange(prefix, key.IncPrefix(prefix))
+	return db.DeletePrefix(prefix)
+}
+
+// NewBatch creates a batch that invalidates its keys when it is applied.
+func (d *Database) NewBatch() sortedkv.Batch {
+	return &batch{b: d.db.NewBatch(), d: d}
+}
+
+// NewIterator creates an iterator on the underlying database.
+func (d *Database) NewIterator() sortedkv.Iterator {
+	return d.db.NewIterator()
+}
+
+// NewIteratorWithRange creates an iterator on the underlying database.
+func (d *Database) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return d.db.NewIteratorWithRange(start, end)
+}
+
+// NewIteratorWithPrefix creates an iterator on the underlying database.
+func (d *Database) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return d.db.NewIteratorWithPrefix(prefix)
+}
+
+// NewSnapshot creates a snapshot of the underlying database.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	db, ok := d.db.(sortedkv.Snapshotter)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewSnapshot"}
+	}
+	return db.NewSnapshot()
+}
+
+// NewTransaction creates a transaction that invalidates its keys when it is
+// committed.
+func (d *Database) NewTransaction() (sortedkv.Transaction, error) {
+	db, ok := d.db.(sortedkv.Transactor)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewTransaction"}
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{Transaction: tx, d: d}, nil
+}
+
+// Watch calls Watch on the underlying database.
+func (d *Database) Watch(ctx context.Context, prefix string) (<-chan sortedkv.Event, error) {
+	db, ok := d.db.(sortedkv.Watcher)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "Watch"}
+	}
+	return db.Watch(ctx, prefix)
+}
+
+// CompactRange calls CompactRange of the underlying database. Compaction does
+// not change the entries, so the cache stays valid. It fails if the underlying
+// database is not a sortedkv.Compacter.
+func (d *Database) CompactRange(start string, end string) error {
+	db, ok := d.db.(sortedkv.Compacter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "CompactRange"}
+	}
+	return db.CompactRange(start, end)
+}
+
+// ApproximateSize calls ApproximateSize of the underlying database. It fails
+// if the underlying database is not a sortedkv.Stater.
+func (d *Database) ApproximateSize(start string, end string) (int64, error) {
+	db, ok := d.db.(sortedkv.Stater)
+	if !ok {
+		return 0, &sortedkv.NotSupportedError{Op: "ApproximateSize"}
+	}
+	return db.ApproximateSize(start, end)
+}
+
+// Stats returns the statistics of the underlying database, not those of the
+// cache, see CacheStats. It fails if the underlying database is not a
+// sortedkv.Stater.
+func (d *Database) Stats() (map[string]string, error) {
+	db, ok := d.db.(sortedkv.Stater)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "Stats"}
+	}
+	return db.Stats()
+}
+
+// Close clears the cache and closes the underlying database.
+func (d *Database) Close() error {
+	defer func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.gen++
+		d.cache.clear()
+	}()
+	return d.db.Close()
+}
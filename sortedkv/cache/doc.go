@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cache provides a read-through cache for sortedkv databases.
+//
+// A Database wraps another database and caches the results of Get, GetBytes
+// and Has in a least-recently-used cache that is bounded by the size of the
+// cached keys and values. Lookups of absent keys are cached as well, so that
+// repeated lookups of a missing key return a *sortedkv.NotFoundError without
+// accessing the underlying database.
+//
+// Writes through the Database, its batches and its transactions invalidate
+// the affected keys once they were applied, so reads that start after a write
+// returned never see stale data. Writes that bypass the Database, for example
+// writes to the underlying database directly, are not noticed.
+//
+// Iterators, snapshots and the reads of transactions are passed through to
+// the underlying database. They neither use nor populate the cache.
+package cache // import "polycry.pt/poly-go/sortedkv/cache"
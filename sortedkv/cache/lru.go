@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import "container/list"
+
+type (
+	// lru is a least-recently-used cache that is bounded by the total size of
+	// its entries. It is not safe for concurrent use.
+	lru struct {
+		capacity int
+		size     int
+		entries  map[string]*list.Element
+		order    *list.List // Most recently used first.
+	}
+
+	// entry is a cached lookup. Absent keys are cached with found = false.
+	entry struct {
+		key   string
+		value string
+		found bool
+	}
+)
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// size returns the number of bytes that an entry counts towards the capacity.
+func (e *entry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+// get returns the entry of a key and marks it as recently used.
+func (c *lru) get(key string) (*entry, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry), true
+}
+
+// add adds or replaces an entry and returns the number of evicted entries.
+// Entries that are larger than the capacity are not added.
+func (c *lru) add(e *entry) (evicted int) {
+	c.remove(e.key)
+	if e.size() > c.capacity {
+		return 0
+	}
+	c.entries[e.key] = c.order.PushFront(e)
+	c.size += e.size()
+	for c.size > c.capacity {
+		c.remove(c.order.Back().Value.(*entry).key)
+		evicted++
+	}
+	return evicted
+}
+
+// remove removes the entry of a key, if any.
+func (c *lru) remove(key string) {
+	el, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(el)
+	delete(c.entries, key)
+	c.size -= el.Value.(*entry).size()
+}
+
+// removeRange removes all entries in the range [start, end). An empty end
+// denotes no upper bound.
+func (c *lru) removeRange(start, end string) {
+	for key := range c.entries {
+		if key >= start && (end == "" || key < end) {
+			c.remove(key)
+		}
+	}
+}
+
+// clear removes all entries.
+func (c *lru) clear() {
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.size = 0
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import "polycry.pt/poly-go/sortedkv"
+
+// transaction invalidates the keys that an underlying transaction writes when
+// it is committed. Its reads bypass the cache.
+type transaction struct {
+	sortedkv.Transaction
+	d    *Database
+	keys []string
+}
+
+// Put saves a value under a key.
+func (t *transaction) Put(key string, value string) error {
+	t.keys = append(t.keys, key)
+	return t.Transaction.Put(key, value)
+}
+
+// PutBytes saves a value under a key.
+func (t *transaction) PutBytes(key string, value []byte) error {
+	t.keys = append(t.keys, key)
+	return t.Transaction.PutBytes(key, value)
+}
+
+// Delete deletes a key.
+func (t *transaction) Delete(key string) error {
+	t.keys = append(t.keys, key)
+	return t.Transaction.Delete(key)
+}
+
+// Commit commits the underlying transaction and invalidates its keys
+// afterwards, even if it failed.
+func (t *transaction) Commit() error {
+	defer t.d.invalidate(t.keys...)
+	return t.Transaction.Commit()
+}
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+// Compacter wraps the CompactRange method of a backing data store. It is
+// optionally implemented by Database implementations, which can be checked
+// with a type assertion.
+type Compacter interface {
+	// CompactRange compacts the storage of the keys in the range [start, end),
+	// which reclaims the space of deleted and overwritten entries. If end is
+	// empty, the range has no upper bound.
+	CompactRange(start string, end string) error
+}
+
+// Stater wraps the size and statistics methods of a backing data store. It is
+// optionally implemented by Database implementations, which can be checked
+// with a type assertion.
+type Stater interface {
+	// ApproximateSize returns the approximate number of bytes that the keys
+	// in the range [start, end) occupy in the storage. If end is empty, the
+	// range has no upper bound. Recent writes may not be included yet.
+	ApproximateSize(start string, end string) (int64, error)
+
+	// Stats returns backend specific statistics of the whole data store as
+	// pairs of property names and values.
+	Stats() (map[string]string, error)
+}
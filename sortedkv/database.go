@@ -10,16 +10,6 @@ package sortedkv // import "polycry.pt/poly-go/sortedkv"
 
 import "io"
 
-// NotFoundError is returned whenever a key is not in the db.
-type NotFoundError struct {
-	Key string
-}
-
-// Error returns the error string.
-func (e *NotFoundError) Error() string {
-	return "sortedkv: key not found: " + e.Key
-}
-
 // Reader wraps the Has, Get and GetBytes methods of a key-value store.
 type Reader interface {
 	// Has checks if a key is present in the store.
@@ -47,6 +37,22 @@ type Writer interface {
 	Delete(key string) error
 }
 
+// RangeDeleter wraps the DeleteRange and DeletePrefix methods of a key-value
+// store or Batch. It is optionally implemented by Database and Batch
+// implementations, which can be checked with a type assertion. The batches of
+// tables implement it even if the wrapped batch does not, see
+// NotSupportedError.
+type RangeDeleter interface {
+	// DeleteRange atomically removes all keys in the range [start, end). If end
+	// is empty, the range has no upper bound. Deleting an empty range is not an
+	// error.
+	DeleteRange(start string, end string) error
+
+	// DeletePrefix atomically removes all keys with the given prefix. Deleting
+	// an empty range is not an error.
+	DeletePrefix(prefix string) error
+}
+
 // Database is a key-value store (not to be confused with SQL-like databases).
 type Database interface {
 	Reader
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package dump exports sortedkv databases to streams and imports them again,
+// for example to back up a database or to move its data to another backend.
+//
+// Export writes the entries with a key prefix in a versioned binary format and
+// Import writes them to a database in batches. ExportJSON and ImportJSON do
+// the same with JSON lines, which is slower and larger but human readable.
+//
+// Binary format
+//
+// A dump starts with the magic bytes "SKVDUMP" and a version byte. The
+// entries follow in chunks. A chunk is the number of its entries as uvarint,
+// the length of its payload as big-endian uint32, the payload and the
+// CRC-32C of the payload as big-endian uint32. The payload consists of the
+// entries, each of which is the length of its key as uvarint, the key, the
+// length of its value as uvarint and the value. An empty chunk ends the dump,
+// followed by the total number of entries as big-endian uint64 and its
+// CRC-32C, so that truncated dumps are detected.
+//
+// Import applies every batch as soon as the next entry does not fit into it,
+// so a corrupted or truncated dump may be partially imported. The entries are
+// only passed to a batch after their chunk was verified, and the last batch is
+// only applied after the end of the dump was verified. Dumps that fit into a
+// single batch are therefore imported completely or not at all.
+package dump // import "polycry.pt/poly-go/sortedkv/dump"
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dump
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/leveldb"
+	"polycry.pt/poly-go/sortedkv/logdb"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+type (
+	format struct {
+		name   string
+		export func(sortedkv.Iterable, string, io.Writer, ...Option) error
+		imp    func(io.Reader, sortedkv.Batcher, ...Option) error
+	}
+
+	backend struct {
+		name string
+		open func(t *testing.T) sortedkv.Database
+	}
+)
+
+var (
+	formats = []format{
+		{"binary", Export, Import},
+		{"json", ExportJSON, ImportJSON},
+	}
+
+	backends = []backend{
+		{"memorydb", func(*testing.T) sortedkv.Database { return memorydb.NewDatabase() }},
+		{"leveldb", func(t *testing.T) sortedkv.Database {
+			db, err := leveldb.LoadDatabase(t.TempDir())
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			return db
+		}},
+		{"logdb", func(t *testing.T) sortedkv.Database {
+			db, err := logdb.Open(filepath.Join(t.TempDir(), "log"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			return db
+		}},
+	}
+)
+
+// randomData returns random entries, including empty and binary keys and
+// values.
+func randomData(t *testing.T, n int) map[string]string {
+	rng := pkgtest.Prng(t)
+	data := map[string]string{"": "empty key", "empty value": "", "p.\x00\xff": "\xff\xfe"}
+	for i := 0; i < n; i++ {
+		value := make([]byte, rng.Intn(100))
+		rng.Read(value)
+		data["p."+strconv.Itoa(rng.Int())] = string(value)
+	}
+	return data
+}
+
+func fill(t *testing.T, db sortedkv.Database, data map[string]string) {
+	t.Helper()
+	for k, v := range data {
+		require.NoError(t, db.Put(k, v))
+	}
+}
+
+// readAll returns the entries of db.
+func TestRoundTrip(t *testing.T) {
+	data := randomData(t, 500)
+	for _, f := range formats {
+		for _, from := range backends {
+			for _, to := range backends {
+				t.Run(f.name+"/"+from.name+"->"+to.name, func(t *testing.T) {
+					src, dst := from.open(t), to.open(t)
+					fill(t, src, data)
+
+					var buf bytes.Buffer
+					require.NoError(t, f.export(src, "", &buf, WithChunkSize(1<<10)))
+					require.NoError(t, f.imp(&buf, dst, WithBatchSize(64)))
+					assert.Equal(t, data, test.ReadAll(t, dst))
+				})
+			}
+		}
+	}
+}
+
+func TestExport_Prefix(t *testing.T) {
+	data := randomData(t, 50)
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			src := memorydb.FromData(data)
+			dst := memorydb.FromData(map[string]string{"kept": "v", "p.\x00\xff": "overwritten"})
+			var buf bytes.Buffer
+			require.NoError(t, f.export(src, "p.", &buf))
+			require.NoError(t, f.imp(&buf, dst))
+
+			want := map[string]string{"kept": "v"}
+			for k, v := range data {
+				if len(k) >= 2 && k[:2] == "p." {
+					want[k] = v
+				}
+			}
+			assert.Equal(t, want, test.ReadAll(t, dst))
+		})
+	}
+}
+
+func TestExport_Empty(t *testing.T) {
+	for _, f := range formats {
+		var buf bytes.Buffer
+		require.NoError(t, f.export(memorydb.NewDatabase(), "", &buf))
+		dst := memorydb.NewDatabase()
+		require.NoError(t, f.imp(&buf, dst))
+		assert.Empty(t, test.ReadAll(t, dst))
+	}
+}
+
+func TestExport_Snapshot(t *testing.T) {
+	for _, f := range formats {
+		src := memorydb.FromData(map[string]string{"a": "1"})
+		var buf bytes.Buffer
+		require.NoError(t, f.export(src, "", &buf, WithSnapshot()))
+		dst := memorydb.NewDatabase()
+		require.NoError(t, f.imp(&buf, dst))
+		assert.Equal(t, map[string]string{"a": "1"}, test.ReadAll(t, dst))
+
+		err := f.export(iterableOnly{src}, "", &buf, WithSnapshot())
+		assert.True(t, sortedkv.IsNotSupported(err))
+	}
+}
+
+// iterableOnly hides all methods of a database but the Iterable ones.
+type iterableOnly struct{ sortedkv.Iterable }
+
+// countingBatcher counts the applied batches.
+type countingBatcher struct {
+	sortedkv.Database
+	applied int
+}
+
+func (c *countingBatcher) NewBatch() sortedkv.Batch {
+	return &countingBatch{c.Database.NewBatch(), c}
+}
+
+type countingBatch struct {
+	sortedkv.Batch
+	c *countingBatcher
+}
+
+func (b *countingBatch) Apply() error {
+	b.c.applied++
+	return b.Batch.Apply()
+}
+
+func TestImport_BatchSize(t *testing.T) {
+	data := randomData(t, 97) // 100 entries.
+	for _, f := range formats {
+		var buf bytes.Buffer
+		require.NoError(t, f.export(memorydb.FromData(data), "", &buf, WithChunkSize(100)))
+		dst := &countingBatcher{Database: memorydb.NewDatabase()}
+		require.NoError(t, f.imp(&buf, dst, WithBatchSize(30)))
+		assert.Equal(t, 4, dst.applied, f.name)
+		assert.Equal(t, data, test.ReadAll(t, dst))
+	}
+}
+
+func TestImport_Corruption(t *testing.T) {
+	data := randomData(t, 20)
+	var buf bytes.Buffer
+	require.NoError(t, Export(memorydb.FromData(data), "", &buf, WithChunkSize(200)))
+	dump := buf.Bytes()
+
+	// Every flipped bit after the header must be detected.
+	for i := len(magic) + 1; i < len(dump); i++ {
+		corrupt := append([]byte(nil), dump...)
+		corrupt[i] ^= 0x10
+		err := Import(bytes.NewReader(corrupt), memorydb.NewDatabase())
+		assert.True(t, sortedkv.IsCorrupted(err), "flipped byte %d: %v", i, err)
+	}
+
+	// Every truncation must be detected, and nothing must be imported if the
+	// dump fits into one batch.
+	for i := 0; i < len(dump); i++ {
+		dst := memorydb.NewDatabase()
+		err := Import(bytes.NewReader(dump[:i]), dst)
+		assert.Error(t, err, "truncated to %d bytes", i)
+		assert.Empty(t, test.ReadAll(t, dst))
+	}
+
+	// A damaged trailer of a dump that exactly fills one batch must not leave
+	// the batch applied.
+	for i := len(dump) - 12; i < len(dump); i++ {
+		corrupt := append([]byte(nil), dump...)
+		corrupt[i] ^= 0x10
+		dst := memorydb.NewDatabase()
+		err := Import(bytes.NewReader(corrupt), dst, WithBatchSize(len(data)))
+		assert.True(t, sortedkv.IsCorrupted(err), "flipped trailer byte %d: %v", i, err)
+		assert.Empty(t, test.ReadAll(t, dst))
+	}
+
+	err := Import(bytes.NewReader([]byte("SKVDUMP\x02")), memorydb.NewDatabase())
+	assert.EqualError(t, err, "unsupported dump version 2")
+	err = Import(bytes.NewReader([]byte("NOTADUMP")), memorydb.NewDatabase())
+	assert.True(t, sortedkv.IsCorrupted(err))
+}
+
+func TestImportJSON_Corruption(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, ExportJSON(memorydb.FromData(map[string]string{"a": "1", "b": "\xff"}), "", &buf))
+	dump := buf.String()
+	assert.Equal(t, `{"format":"sortedkv-dump","version":1}
+{"key":"a","value":"1"}
+{"key":"b","valueBase64":"/w=="}
+{"count":2}
+`, dump)
+
+	for _, corrupt := range []string{
+		dump[:len(dump)-len(`{"count":2}`)-1],
+		`{"format":"other","version":1}` + dump[len(`{"format":"sortedkv-dump","version":1}`):],
+		dump[:len(dump)-3] + "3}\n",
+		`{"format":"sortedkv-dump","version":1}` + "\n" + `{"key":"a","keyBase64":"YQ==","value":""}` + "\n",
+	} {
+		dst := memorydb.NewDatabase()
+		err := ImportJSON(bytes.NewReader([]byte(corrupt)), dst)
+		assert.True(t, sortedkv.IsCorrupted(err), "%q: %v", corrupt, err)
+		assert.Empty(t, test.ReadAll(t, dst))
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// Version is the version of the binary format that Export writes.
+const Version = 1
+
+// magic starts every binary dump.
+const magic = "SKVDUMP"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Export writes all entries of db whose keys have the given prefix to w. The
+// keys are written in full, including the prefix.
+func Export(db sortedkv.Iterable, prefix string, w io.Writer, opts ...Option) error {
+	o := newOptions(opts)
+	return iterate(db, prefix, o, func(it sortedkv.Iterator) error {
+		bw := bufio.NewWriter(w)
+		if _, err := bw.WriteString(magic + string([]byte{Version})); err != nil {
+			return err
+		}
+
+		var (
+			payload []byte
+			n       int
+			total   uint64
+		)
+		for it.Next() {
+			payload = appendBytes(payload, it.Key())
+			payload = appendBytes(payload, string(it.ValueBytes()))
+			n++
+			total++
+			if len(payload) >= o.chunkSize {
+				if err := writeChunk(bw, n, payload); err != nil {
+					return err
+				}
+				payload, n = payload[:0], 0
+			}
+		}
+		if err := it.Close(); err != nil {
+			return errors.WithMessage(err, "iterating database")
+		}
+		if n > 0 {
+			if err := writeChunk(bw, n, payload); err != nil {
+				return err
+			}
+		}
+		if err := writeTrailer(bw, total); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
+
+// iterate calls f with an iterator over the prefix of db, or of a snapshot of
+// db if requested. f must close the iterator.
+func iterate(db sortedkv.Iterable, prefix string, o options, f func(sortedkv.Iterator) error) error {
+	if !o.snapshot {
+		it := db.NewIteratorWithPrefix(prefix)
+		defer it.Close()
+		return f(it)
+	}
+	snapshotter, ok := db.(sortedkv.Snapshotter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "NewSnapshot"}
+	}
+	s, err := snapshotter.NewSnapshot()
+	if err != nil {
+		return errors.WithMessage(err, "creating snapshot")
+	}
+	defer s.Close()
+	it := s.NewIteratorWithPrefix(prefix)
+	defer it.Close()
+	return f(it)
+}
+
+func appendBytes(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], x)]...)
+}
+
+func appendUint32(buf []byte, x uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], x)
+	return append(buf, b[:]...)
+}
+
+func writeChunk(w io.Writer, n int, payload []byte) error {
+	header := appendUvarint(nil, uint64(n))
+	header = appendUint32(header, uint32(len(payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	_, err := w.Write(appendUint32(nil, crc32.Checksum(payload, castagnoli)))
+	return err
+}
+
+func writeTrailer(w io.Writer, total uint64) error {
+	var count [8]byte
+	binary.BigEndian.PutUint64(count[:], total)
+	trailer := appendUvarint(nil, 0)
+	trailer = append(trailer, count[:]...)
+	trailer = appendUint32(trailer, crc32.Checksum(count[:], castagnoli))
+	_, err := w.Write(trailer)
+	return err
+}
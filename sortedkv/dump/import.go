@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// Import writes the entries of a dump that was written by Export to db. The
+// entries are written in batches. Existing keys that are not part of the dump
+// are kept.
+func Import(r io.Reader, db sortedkv.Batcher, opts ...Option) error {
+	o := newOptions(opts)
+	br := bufio.NewReader(r)
+	if err := readHeader(br); err != nil {
+		return err
+	}
+
+	w := newBatchWriter(db, o.batchSize)
+	var total uint64
+	for chunk := 0; ; chunk++ {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return truncated(err, "reading chunk %d", chunk)
+		}
+		if n == 0 {
+			break
+		}
+		payload, err := readChunk(br, chunk)
+		if err != nil {
+			return err
+		}
+		if err := decodeEntries(payload, n, chunk, w.put); err != nil {
+			return err
+		}
+		total += n
+	}
+	if err := readTrailer(br, total); err != nil {
+		return err
+	}
+	return w.flush()
+}
+
+func readHeader(r *bufio.Reader) error {
+	var header [len(magic) + 1]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return truncated(err, "reading header")
+	}
+	if string(header[:len(magic)]) != magic {
+		return corrupted("not a sortedkv dump")
+	}
+	if v := header[len(magic)]; v != Version {
+		return errors.Errorf("unsupported dump version %d", v)
+	}
+	return nil
+}
+
+// readChunk reads and verifies the payload of a chunk.
+func readChunk(r *bufio.Reader, chunk int) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, truncated(err, "reading chunk %d", chunk)
+	}
+	// The payload is copied instead of allocated upfront, so that a corrupted
+	// length cannot cause a huge allocation.
+	var payload bytes.Buffer
+	if _, err := io.CopyN(&payload, r, int64(binary.BigEndian.Uint32(size[:]))); err != nil {
+		return nil, truncated(err, "reading chunk %d", chunk)
+	}
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return nil, truncated(err, "reading chunk %d", chunk)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != crc32.Checksum(payload.Bytes(), castagnoli) {
+		return nil, corrupted("checksum mismatch in chunk %d", chunk)
+	}
+	return payload.Bytes(), nil
+}
+
+// decodeEntries decodes the n entries of a payload and calls put for each.
+func decodeEntries(payload []byte, n uint64, chunk int, put func(key string, value []byte) error) error {
+	for i := uint64(0); i < n; i++ {
+		key, rest, ok := decodeBytes(payload)
+		if !ok {
+			return corrupted("invalid entry in chunk %d", chunk)
+		}
+		value, rest, ok := decodeBytes(rest)
+		if !ok {
+			return corrupted("invalid entry in chunk %d", chunk)
+		}
+		if err := put(string(key), value); err != nil {
+			return err
+		}
+		payload = rest
+	}
+	if len(payload) != 0 {
+		return corrupted("trailing data in chunk %d", chunk)
+	}
+	return nil
+}
+
+func decodeBytes(buf []byte) (b []byte, rest []byte, ok bool) {
+	l, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < l {
+		return nil, nil, false
+	}
+	return buf[n : n+int(l)], buf[n+int(l):], true
+}
+
+func readTrailer(r *bufio.Reader, total uint64) error {
+	var trailer [12]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return truncated(err, "reading trailer")
+	}
+	if binary.BigEndian.Uint32(trailer[8:]) != crc32.Checksum(trailer[:8], castagnoli) {
+		return corrupted("checksum mismatch in trailer")
+	}
+	if count := binary.BigEndian.Uint64(trailer[:8]); count != total {
+		return corrupted("dump has %d entries, trailer says %d", total, count)
+	}
+	return nil
+}
+
+// batchWriter writes entries in batches of a given size.
+type batchWriter struct {
+	db    sortedkv.Batcher
+	batch sortedkv.Batch
+	size  int
+	n     int
+}
+
+func newBatchWriter(db sortedkv.Batcher, size int) *batchWriter {
+	return &batchWriter{db: db, batch: db.NewBatch(), size: size}
+}
+
+// put adds an entry to the current batch. A full batch is only applied when
+// the next entry is added, so that the last batch is always applied by flush.
+func (w *batchWriter) put(key string, value []byte) error {
+	if w.n >= w.size {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.n++
+	return w.batch.PutBytes(key, value)
+}
+
+// flush applies the current batch.
+func (w *batchWriter) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	if err := w.batch.Apply(); err != nil {
+		return errors.WithMessage(err, "applying batch")
+	}
+	w.batch.Reset()
+	w.n = 0
+	return nil
+}
+
+func corrupted(format string, args ...interface{}) error {
+	return &sortedkv.CorruptedError{Err: errors.Errorf("dump: "+format, args...)}
+}
+
+// truncated returns a corruption error for an unexpected end of the dump, and
+// other read errors as they are.
+func truncated(err error, format string, args ...interface{}) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return corrupted("unexpected end of dump while "+format, args...)
+	}
+	return errors.WithMessagef(err, format, args...)
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dump
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// jsonFormat identifies JSON dumps.
+const jsonFormat = "sortedkv-dump"
+
+// jsonLine is a line of a JSON dump. The first line is the header with
+// Format and Version, the last line is the trailer with Count and all other
+// lines are entries. Keys and values that are not valid UTF-8 are encoded in
+// base64.
+type jsonLine struct {
+	Format      string  `json:"format,omitempty"`
+	Version     int     `json:"version,omitempty"`
+	Key         *string `json:"key,omitempty"`
+	KeyBase64   *string `json:"keyBase64,omitempty"`
+	Value       *string `json:"value,omitempty"`
+	ValueBase64 *string `json:"valueBase64,omitempty"`
+	Count       *uint64 `json:"count,omitempty"`
+}
+
+// ExportJSON writes all entries of db whose keys have the given prefix to w
+// as JSON lines. Chunking options are ignored.
+func ExportJSON(db sortedkv.Iterable, prefix string, w io.Writer, opts ...Option) error {
+	o := newOptions(opts)
+	return iterate(db, prefix, o, func(it sortedkv.Iterator) error {
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		if err := enc.Encode(jsonLine{Format: jsonFormat, Version: Version}); err != nil {
+			return err
+		}
+		var count uint64
+		for it.Next() {
+			var line jsonLine
+			line.Key, line.KeyBase64 = encodeJSONString(it.Key())
+			line.Value, line.ValueBase64 = encodeJSONString(string(it.ValueBytes()))
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+			count++
+		}
+		if err := it.Close(); err != nil {
+			return errors.WithMessage(err, "iterating database")
+		}
+		if err := enc.Encode(jsonLine{Count: &count}); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
+
+// ImportJSON writes the entries of a dump that was written by ExportJSON to
+// db, like Import.
+func ImportJSON(r io.Reader, db sortedkv.Batcher, opts ...Option) error {
+	o := newOptions(opts)
+	dec := json.NewDecoder(r)
+	var header jsonLine
+	if err := dec.Decode(&header); err != nil {
+		return truncated(err, "reading header")
+	}
+	if header.Format != jsonFormat {
+		return corrupted("not a sortedkv JSON dump")
+	}
+	if header.Version != Version {
+		return errors.Errorf("unsupported dump version %d", header.Version)
+	}
+
+	w := newBatchWriter(db, o.batchSize)
+	for n := uint64(0); ; n++ {
+		var line jsonLine
+		if err := dec.Decode(&line); err != nil {
+			return truncated(err, "reading entry %d", n)
+		}
+		if line.Count != nil {
+			if *line.Count != n {
+				return corrupted("dump has %d entries, trailer says %d", n, *line.Count)
+			}
+			break
+		}
+		key, err := decodeJSONString(line.Key, line.KeyBase64)
+		if err != nil {
+			return corrupted("invalid key of entry %d: %v", n, err)
+		}
+		value, err := decodeJSONString(line.Value, line.ValueBase64)
+		if err != nil {
+			return corrupted("invalid value of entry %d: %v", n, err)
+		}
+		if err := w.put(key, []byte(value)); err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
+
+// encodeJSONString returns s as plain string if it is valid UTF-8 and as
+// base64 otherwise.
+func encodeJSONString(s string) (plain *string, b64 *string) {
+	if utf8.ValidString(s) {
+		return &s, nil
+	}
+	enc := base64.StdEncoding.EncodeToString([]byte(s))
+	return nil, &enc
+}
+
+func decodeJSONString(plain *string, b64 *string) (string, error) {
+	switch {
+	case plain != nil && b64 == nil:
+		return *plain, nil
+	case plain == nil && b64 != nil:
+		b, err := base64.StdEncoding.DecodeString(*b64)
+		return string(b), err
+	default:
+		return "", errors.New("exactly one of the plain and base64 encodings must be set")
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dump
+
+const (
+	// DefaultChunkSize is the default payload size of the chunks of a dump.
+	DefaultChunkSize = 64 << 10
+	// DefaultBatchSize is the default number of entries per imported batch.
+	DefaultBatchSize = 1024
+)
+
+type (
+	// Option configures an export or import.
+	Option func(*options)
+
+	options struct {
+		chunkSize int
+		batchSize int
+		snapshot  bool
+	}
+)
+
+// WithChunkSize sets the payload size in bytes after which Export starts a
+// new chunk. Entries are never split, so chunks with large entries are
+// larger.
+func WithChunkSize(size int) Option {
+	return func(o *options) { o.chunkSize = size }
+}
+
+// WithBatchSize sets the number of entries that Import and ImportJSON write
+// per batch.
+func WithBatchSize(n int) Option {
+	return func(o *options) { o.batchSize = n }
+}
+
+// WithSnapshot makes Export and ExportJSON read from a snapshot, so that the
+// dump is a consistent view of the database even if it is written to
+// concurrently. The exported database must be a sortedkv.Snapshotter.
+func WithSnapshot() Option {
+	return func(o *options) { o.snapshot = true }
+}
+
+func newOptions(opts []Option) options {
+	o := options{chunkSize: DefaultChunkSize, batchSize: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
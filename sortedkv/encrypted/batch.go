@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import "polycry.pt/poly-go/sortedkv"
+
+// batch encrypts the writes to an underlying batch.
+type batch struct {
+	b sortedkv.Batch
+	c *crypter
+}
+
+// Put puts a value into the batch.
+func (b *batch) Put(key string, value string) error {
+	return b.PutBytes(key, []byte(value))
+}
+
+// PutBytes puts a value into the batch. If keys are encrypted, the entries of
+// the key under older key versions are deleted.
+func (b *batch) PutBytes(key string, value []byte) error {
+	stored := b.c.storedKeys(key)
+	for _, old := range stored[1:] {
+		if err := b.b.Delete(old); err != nil {
+			return err
+		}
+	}
+	return b.b.PutBytes(stored[0], b.c.sealValue(key, value))
+}
+
+// Delete deletes a key in the batch.
+func (b *batch) Delete(key string) error {
+	for _, stored := range b.c.storedKeys(key) {
+		if err := b.b.Delete(stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRange deletes all keys in the range [start, end) in the batch. It
+// fails if keys are encrypted or the underlying batch is not a
+// sortedkv.RangeDeleter.
+func (b *batch) DeleteRange(start string, end string) error {
+	rd, ok := b.b.(sortedkv.RangeDeleter)
+	if !ok || b.c.encryptsKeys() {
+		return &sortedkv.NotSupportedError{Op: "DeleteRange"}
+	}
+	return rd.DeleteRange(start, end)
+}
+
+// DeletePrefix deletes all keys with the given prefix in the batch. It fails
+// if keys are encrypted or the underlying batch is not a
+// sortedkv.RangeDeleter.
+func (b *batch) DeletePrefix(prefix string) error {
+	rd, ok := b.b.(sortedkv.RangeDeleter)
+	if !ok || b.c.encryptsKeys() {
+		return &sortedkv.NotSupportedError{Op: "DeletePrefix"}
+	}
+	return rd.DeletePrefix(prefix)
+}
+
+// Apply applies the underlying batch.
+func (b *batch) Apply() error {
+	return b.b.Apply()
+}
+
+// Reset resets the underlying batch.
+func (b *batch) Reset() {
+	b.b.Reset()
+}
@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// reencryptBatchSize is the number of entries that Reencrypt rewrites per
+// batch.
+const reencryptBatchSize = 1024
+
+// Database encrypts the data of an underlying database. It implements
+// sortedkv.Database, sortedkv.RangeDeleter, sortedkv.Snapshotter,
+// sortedkv.Transactor, sortedkv.Compacter and sortedkv.Stater. The optional
+// operations fail with a *sortedkv.NotSupportedError if the underlying
+// database does not support them.
+type Database struct {
+	view
+	db sortedkv.Database
+}
+
+// New wraps db so that all data is encrypted with current. Data that was
+// written with older keys is readable if they are passed via WithOldKeys. The
+// AEADs are created with newAEAD, for example AESGCM.
+func New(db sortedkv.Database, newAEAD NewAEAD, current Key, opts ...Option) (*Database, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c, err := newCrypter(newAEAD, current, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{view: view{store: db, c: c}, db: db}, nil
+}
+
+// Put saves a value under a key.
+func (d *Database) Put(key string, value string) error {
+	return d.PutBytes(key, []byte(value))
+}
+
+// PutBytes saves a value under a key. If keys are encrypted and the key was
+// stored under an older key version, the old entry is deleted atomically.
+func (d *Database) PutBytes(key string, value []byte) error {
+	stored := d.c.storedKeys(key)
+	if len(stored) == 1 {
+		return d.db.PutBytes(stored[0], d.c.sealValue(key, value))
+	}
+	b := d.NewBatch()
+	if err := b.PutBytes(key, value); err != nil {
+		return err
+	}
+	return b.Apply()
+}
+
+// Delete deletes a key. It returns a *sortedkv.NotFoundError if the key does
+// not exist.
+func (d *Database) Delete(key string) error {
+	existing, err := d.existingKeys(key)
+	if err != nil {
+		return err
+	}
+	switch len(existing) {
+	case 0:
+		return &sortedkv.NotFoundError{Key: key}
+	case 1:
+		return d.db.Delete(existing[0])
+	}
+	b := d.db.NewBatch()
+	for _, stored := range existing {
+		if err := b.Delete(stored); err != nil {
+			return err
+		}
+	}
+	return b.Apply()
+}
+
+// DeleteRange deletes all keys in the range [start, end). It fails if keys
+// are encrypted or the underlying database is not a sortedkv.RangeDeleter.
+func (d *Database) DeleteRange(start string, end string) error {
+	db, ok := d.db.(sortedkv.RangeDeleter)
+	if !ok || d.c.encryptsKeys() {
+		return &sortedkv.NotSupportedError{Op: "DeleteRange"}
+	}
+	return db.DeleteRange(start, end)
+}
+
+// DeletePrefix deletes all keys with the given prefix. It fails if keys are
+// encrypted or the underlying database is not a sortedkv.RangeDeleter.
+func (d *Database) DeletePrefix(prefix string) error {
+	db, ok := d.db.(sortedkv.RangeDeleter)
+	if !ok || d.c.encryptsKeys() {
+		return &sortedkv.NotSupportedError{Op: "DeletePrefix"}
+	}
+	return db.DeletePrefix(prefix)
+}
+
+// CompactRange calls CompactRange of the underlying database. It fails if keys
+// are encrypted, since their ranges are not preserved, or the underlying
+// database is not a sortedkv.Compacter.
+func (d *Database) CompactRange(start string, end string) error {
+	db, ok := d.db.(sortedkv.Compacter)
+	if !ok || d.c.encryptsKeys() {
+		return &sortedkv.NotSupportedError{Op: "CompactRange"}
+	}
+	return db.CompactRange(start, end)
+}
+
+// ApproximateSize calls ApproximateSize of the underlying database. The size
+// includes the overhead of the encryption. It fails if keys are encrypted or
+// the underlying database is not a sortedkv.Stater.
+func (d *Database) ApproximateSize(start string, end string) (int64, error) {
+	db, ok := d.db.(sortedkv.Stater)
+	if !ok || d.c.encryptsKeys() {
+		return 0, &sortedkv.NotSupportedError{Op: "ApproximateSize"}
+	}
+	return db.ApproximateSize(start, end)
+}
+
+// Stats returns the statistics of the underlying database. It fails if the
+// underlying database is not a sortedkv.Stater.
+func (d *Database) Stats() (map[string]string, error) {
+	db, ok := d.db.(sortedkv.Stater)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "Stats"}
+	}
+	return db.Stats()
+}
+
+// NewBatch creates a batch that encrypts its writes.
+func (d *Database) NewBatch() sortedkv.Batch {
+	return &batch{b: d.db.NewBatch(), c: d.c}
+}
+
+// NewSnapshot creates a snapshot that decrypts the data of a snapshot of the
+// underlying database.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	db, ok := d.db.(sortedkv.Snapshotter)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewSnapshot"}
+	}
+	s, err := db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{view: view{store: s, c: d.c}, s: s}, nil
+}
+
+// NewTransaction creates a transaction that encrypts the data of a
+// transaction on the underlying database.
+func (d *Database) NewTransaction() (sortedkv.Transaction, error) {
+	db, ok := d.db.(sortedkv.Transactor)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewTransaction"}
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{view: view{store: tx, c: d.c}, tx: tx}, nil
+}
+
+// Reencrypt rewrites all entries that were not written with the current key,
+// so that the old keys are no longer needed afterwards. Entries are rewritten
+// in batches. Writes to the database that happen concurrently with Reencrypt
+// may be lost, so Reencrypt should be run while the database is not in use.
+func (d *Database) Reencrypt() error {
+	it := d.db.NewIterator()
+	defer it.Close()
+
+	b := d.db.NewBatch()
+	n := 0
+	for it.Next() {
+		stored, data := it.Key(), it.ValueBytes()
+		key, version := stored, d.c.current.version
+		if d.c.encryptsKeys() {
+			var err error
+			if key, version, err = d.c.openKey(stored); err != nil {
+				return err
+			}
+		}
+		if version == d.c.current.version && valueVersion(data) == d.c.current.version {
+			continue
+		}
+
+		value, err := d.c.openValue(key, data)
+		if err != nil {
+			return err
+		}
+		current := d.c.storedKeys(key)[0]
+		if current != stored {
+			if err := b.Delete(stored); err != nil {
+				return err
+			}
+		}
+		if err := b.PutBytes(current, d.c.sealValue(key, value)); err != nil {
+			return err
+		}
+		if n++; n == reencryptBatchSize {
+			if err := b.Apply(); err != nil {
+				return errors.WithMessage(err, "applying batch")
+			}
+			b.Reset()
+			n = 0
+		}
+	}
+	if err := it.Close(); err != nil {
+		return err
+	}
+	return errors.WithMessage(b.Apply(), "applying batch")
+}
+
+// Close closes the underlying database.
+func (d *Database) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package encrypted provides a wrapper around sortedkv databases that
+// encrypts the stored data at rest.
+//
+// Values are sealed with an AEAD, such as AES-GCM or ChaCha20-Poly1305, under
+// a random nonce. The key of a value is its additional data, so that values
+// cannot be moved to other keys unnoticed. Every sealed value is tagged with
+// the version of the Key that sealed it. Values are always written with the
+// current key, and values of older keys stay readable if these keys are passed
+// via WithOldKeys. Database.Reencrypt rewrites all data with the current key,
+// after which the old keys are no longer needed.
+//
+// Keys
+//
+// With WithKeyEncryption, the keys are encrypted deterministically as well. To
+// keep tables working, the key prefixes of the given tables stay in clear
+// text and only the rest of a key is encrypted. Since encrypted keys do not
+// preserve order, iterators read all keys of the affected tables into memory
+// and sort them. Iterating a table or a prefix within a table is therefore
+// proportional to the size of the table. Range deletions, range compactions
+// and size estimates of ranges are not supported with encrypted keys.
+package encrypted // import "polycry.pt/poly-go/sortedkv/encrypted"
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+// modes are the tested configurations of the wrapper.
+var modes = []struct {
+	name string
+	opts []Option
+}{
+	{"values", nil},
+	{"keys", []Option{WithKeyEncryption("Table.", "Table.Inner.", "table")}},
+}
+
+func newKey(t *testing.T, version byte) Key {
+	t.Helper()
+	secret := make([]byte, MinSecretSize)
+	pkgtest.Prng(t).Read(secret)
+	return Key{Version: version, Secret: secret}
+}
+
+func newDatabase(t *testing.T, db sortedkv.Database, key Key, opts ...Option) *Database {
+	t.Helper()
+	enc, err := New(db, AESGCM, key, opts...)
+	require.NoError(t, err)
+	return enc
+}
+
+// runModes runs f on a new, empty encrypted database for every mode.
+func runModes(t *testing.T, f func(t *testing.T, db *Database)) {
+	t.Helper()
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			f(t, newDatabase(t, memorydb.NewDatabase(), newKey(t, 1), mode.opts...))
+		})
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericDatabaseTest(t, db) })
+}
+
+func TestDatabase_Close(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericClosedDatabaseTest(t, db) })
+}
+
+func TestBatch(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericBatchTest(t, db) })
+	runModes(t, func(t *testing.T, db *Database) {
+		test.GenericBatchTest(t, sortedkv.NewTable(db, "table"))
+	})
+}
+
+func TestIterator(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericIteratorTest(t, db) })
+	runModes(t, func(t *testing.T, db *Database) { test.GenericSeekableIteratorTest(t, db) })
+	runModes(t, func(t *testing.T, db *Database) {
+		test.GenericSeekableIteratorTest(t, sortedkv.NewTable(db, "table"))
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericSnapshotTest(t, db) })
+}
+
+func TestTable(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericTableTest(t, db) })
+}
+
+func TestTransaction(t *testing.T) {
+	runModes(t, func(t *testing.T, db *Database) { test.GenericTransactionTest(t, db) })
+}
+
+func TestRangeDelete(t *testing.T) {
+	db := newDatabase(t, memorydb.NewDatabase(), newKey(t, 1))
+	test.GenericRangeDeleteTest(t, db)
+
+	db = newDatabase(t, memorydb.NewDatabase(), newKey(t, 1), WithKeyEncryption())
+	assert.True(t, sortedkv.IsNotSupported(db.DeleteRange("a", "b")))
+	assert.True(t, sortedkv.IsNotSupported(db.DeletePrefix("a")))
+}
+
+func TestCompact(t *testing.T) {
+	db := newDatabase(t, memorydb.NewDatabase(), newKey(t, 1))
+	test.GenericCompactTest(t, db)
+
+	db = newDatabase(t, memorydb.NewDatabase(), newKey(t, 1), WithKeyEncryption())
+	assert.True(t, sortedkv.IsNotSupported(db.CompactRange("a", "b")))
+	_, err := db.ApproximateSize("a", "b")
+	assert.True(t, sortedkv.IsNotSupported(err))
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.NotEmpty(t, stats)
+}
+
+func TestNew(t *testing.T) {
+	plain := memorydb.NewDatabase()
+	_, err := New(plain, AESGCM, Key{Version: 1, Secret: make([]byte, MinSecretSize-1)})
+	assert.Error(t, err, "short secret")
+	_, err = New(plain, AESGCM, newKey(t, 1), WithOldKeys(newKey(t, 1)))
+	assert.Error(t, err, "duplicate version")
+}
+
+func TestDatabase_Ciphertext(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			plain := memorydb.NewDatabase()
+			db := newDatabase(t, plain, newKey(t, 1), mode.opts...)
+			require.NoError(t, db.Put("Table.secret key", "secret value"))
+			require.NoError(t, db.Put("other secret key", "secret value"))
+
+			it := plain.NewIterator()
+			n := 0
+			for ; it.Next(); n++ {
+				assert.NotContains(t, it.Value(), "secret")
+				if len(mode.opts) == 0 {
+					continue
+				}
+				assert.NotContains(t, it.Key(), "secret")
+				if bytes.Contains([]byte(it.Key()), []byte("Table.")) {
+					assert.Equal(t, tablePrefix("Table."), it.Key()[:len(tablePrefix("Table."))])
+				}
+			}
+			require.NoError(t, it.Close())
+			assert.Equal(t, 2, n)
+		})
+	}
+}
+
+func TestDatabase_Tampering(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			plain := memorydb.NewDatabase()
+			db := newDatabase(t, plain, newKey(t, 1), mode.opts...)
+			require.NoError(t, db.Put("a", "1"))
+			require.NoError(t, db.Put("b", "2"))
+			stored := db.c.storedKeys("a")[0]
+
+			// A value that is moved to another key must not be accepted.
+			value, err := plain.GetBytes(stored)
+			require.NoError(t, err)
+			require.NoError(t, plain.PutBytes(db.c.storedKeys("b")[0], value))
+			_, err = db.Get("b")
+			assert.True(t, sortedkv.IsCorrupted(err), "moved value")
+
+			value[len(value)-1] ^= 1
+			require.NoError(t, plain.PutBytes(stored, value))
+			_, err = db.Get("a")
+			assert.True(t, sortedkv.IsCorrupted(err), "flipped bit")
+
+			it := db.NewIterator()
+			for it.Next() {
+			}
+			assert.True(t, sortedkv.IsCorrupted(it.Close()), "iterator")
+		})
+	}
+}
+
+func TestDatabase_Rotation(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			plain := memorydb.NewDatabase()
+			oldKey, newKey := newKey(t, 1), newKey(t, 2)
+			db := newDatabase(t, plain, oldKey, mode.opts...)
+			data := map[string]string{"a": "1", "Table.b": "2", "Table.Inner.c": "3"}
+			for k, v := range data {
+				require.NoError(t, db.Put(k, v))
+			}
+
+			db = newDatabase(t, plain, newKey, append(mode.opts, WithOldKeys(oldKey))...)
+			assertContains(t, db, data)
+			// Overwriting a key must not leave the old version behind.
+			data["a"] = "1'"
+			require.NoError(t, db.Put("a", data["a"]))
+			assertContains(t, db, data)
+
+			require.NoError(t, db.Reencrypt())
+			db = newDatabase(t, plain, newKey, mode.opts...)
+			assertContains(t, db, data)
+			require.NoError(t, db.Delete("a"))
+			assert.True(t, sortedkv.IsNotFound(db.Delete("a")))
+		})
+	}
+}
+
+// assertContains asserts that db contains exactly data.
+func assertContains(t *testing.T, db sortedkv.Database, data map[string]string) {
+	t.Helper()
+	for k, v := range data {
+		value, err := db.Get(k)
+		require.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	assert.Equal(t, data, test.ReadAll(t, db))
+}
+
+func TestSplitTable(t *testing.T) {
+	for _, table := range []string{"", "a", "\x00", "a\x00\x01b", "\xff\x00"} {
+		stored := tablePrefix(table) + "rest\x00"
+		got, rest, err := splitTable(stored)
+		require.NoError(t, err)
+		assert.Equal(t, table, got)
+		assert.Equal(t, "rest\x00", rest)
+	}
+	_, _, err := splitTable("a\x00\x02")
+	assert.True(t, sortedkv.IsCorrupted(err))
+	_, _, err = splitTable("a")
+	assert.True(t, sortedkv.IsCorrupted(err))
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"sort"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// valueIterator decrypts the values of an iterator over clear text keys.
+	valueIterator struct {
+		it    sortedkv.Iterator
+		c     *crypter
+		value []byte
+		valid bool
+		err   error
+	}
+
+	// seekableValueIterator is a valueIterator over a seekable iterator.
+	seekableValueIterator struct {
+		*valueIterator
+		seekable sortedkv.SeekableIterator
+	}
+
+	// sortedIterator iterates over decrypted entries that were sorted in
+	// memory. It implements sortedkv.SeekableIterator.
+	sortedIterator struct {
+		entries []entry
+		pos     int // -1 before the first entry, len(entries) after the last.
+		err     error
+	}
+
+	entry struct {
+		key   string
+		value []byte
+	}
+)
+
+// newValueIterator wraps it. The returned iterator is seekable if it is.
+func newValueIterator(it sortedkv.Iterator, c *crypter) sortedkv.Iterator {
+	vit := &valueIterator{it: it, c: c}
+	if seekable, ok := it.(sortedkv.SeekableIterator); ok {
+		return &seekableValueIterator{valueIterator: vit, seekable: seekable}
+	}
+	return vit
+}
+
+// Next moves the iterator to the next entry.
+func (i *valueIterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+	return i.load(i.it.Next())
+}
+
+// load decrypts the value of the current entry of the underlying iterator if
+// ok is true.
+func (i *valueIterator) load(ok bool) bool {
+	i.valid, i.value = false, nil
+	if !ok {
+		return false
+	}
+	value, err := i.c.openValue(i.it.Key(), i.it.ValueBytes())
+	if err != nil {
+		i.err = err
+		return false
+	}
+	i.valid, i.value = true, value
+	return true
+}
+
+// Key returns the key of the current entry.
+func (i *valueIterator) Key() string {
+	if !i.valid {
+		return ""
+	}
+	return i.it.Key()
+}
+
+// Value returns the decrypted value of the current entry.
+func (i *valueIterator) Value() string {
+	return string(i.value)
+}
+
+// ValueBytes returns the decrypted value of the current entry.
+func (i *valueIterator) ValueBytes() []byte {
+	return i.value
+}
+
+// Close closes the underlying iterator. It returns the first decryption or
+// iteration error.
+func (i *valueIterator) Close() error {
+	err := i.it.Close()
+	i.valid, i.value = false, nil
+	if i.err != nil {
+		return i.err
+	}
+	return err
+}
+
+// Seek moves the iterator to the first entry whose key is greater than or
+// equal to key.
+func (i *seekableValueIterator) Seek(key string) bool {
+	if i.err != nil {
+		return false
+	}
+	return i.load(i.seekable.Seek(key))
+}
+
+// Prev moves the iterator to the previous entry.
+func (i *seekableValueIterator) Prev() bool {
+	if i.err != nil {
+		return false
+	}
+	return i.load(i.seekable.Prev())
+}
+
+// First moves the iterator to the first entry.
+func (i *seekableValueIterator) First() bool {
+	if i.err != nil {
+		return false
+	}
+	return i.load(i.seekable.First())
+}
+
+// Last moves the iterator to the last entry.
+func (i *seekableValueIterator) Last() bool {
+	if i.err != nil {
+		return false
+	}
+	return i.load(i.seekable.Last())
+}
+
+// valid returns whether the iterator is positioned at an entry.
+func (i *sortedIterator) valid() bool {
+	return i.pos >= 0 && i.pos < len(i.entries)
+}
+
+// Next moves the iterator to the next entry.
+func (i *sortedIterator) Next() bool {
+	if i.pos < len(i.entries) {
+		i.pos++
+	}
+	return i.valid()
+}
+
+// Prev moves the iterator to the previous entry.
+func (i *sortedIterator) Prev() bool {
+	if i.pos >= 0 {
+		i.pos--
+	}
+	return i.valid()
+}
+
+// First moves the iterator to the first entry.
+func (i *sortedIterator) First() bool {
+	i.pos = 0
+	return i.valid()
+}
+
+// Last moves the iterator to the last entry.
+func (i *sortedIterator) Last() bool {
+	i.pos = len(i.entries) - 1
+	return i.valid()
+}
+
+// Seek moves the iterator to the first entry whose key is greater than or
+// equal to key.
+func (i *sortedIterator) Seek(key string) bool {
+	i.pos = sort.Search(len(i.entries), func(j int) bool { return i.entries[j].key >= key })
+	return i.valid()
+}
+
+// Key returns the key of the current entry.
+func (i *sortedIterator) Key() string {
+	if !i.valid() {
+		return ""
+	}
+	return i.entries[i.pos].key
+}
+
+// Value returns the value of the current entry.
+func (i *sortedIterator) Value() string {
+	return string(i.ValueBytes())
+}
+
+// ValueBytes returns the value of the current entry.
+func (i *sortedIterator) ValueBytes() []byte {
+	if !i.valid() {
+		return nil
+	}
+	return i.entries[i.pos].value
+}
+
+// Close releases the entries. It returns the error that occurred when the
+// iterator was created, if any.
+func (i *sortedIterator) Close() error {
+	i.entries, i.pos = nil, -1
+	return i.err
+}
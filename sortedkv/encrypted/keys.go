@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// MinSecretSize is the minimal size of the secret of a Key.
+const MinSecretSize = 32
+
+type (
+	// Key is a versioned secret. The secret must have at least MinSecretSize
+	// bytes of entropy.
+	Key struct {
+		Version byte
+		Secret  []byte
+	}
+
+	// NewAEAD creates an AEAD from a 32-byte key. chacha20poly1305.New from
+	// golang.org/x/crypto can be used as NewAEAD as well.
+	NewAEAD func(key []byte) (cipher.AEAD, error)
+
+	// keyVersion holds the ciphers of a Key.
+	keyVersion struct {
+		version  byte
+		values   cipher.AEAD // Seals values.
+		keys     cipher.AEAD // Seals keys.
+		nonceKey []byte      // Derives the nonces of sealed keys.
+	}
+)
+
+// AESGCM creates an AES-256-GCM AEAD.
+func AESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newKeyVersion derives the ciphers of a key.
+func newKeyVersion(newAEAD NewAEAD, key Key) (*keyVersion, error) {
+	if len(key.Secret) < MinSecretSize {
+		return nil, errors.Errorf("secret of key version %d too short", key.Version)
+	}
+	values, err := newAEAD(derive(key.Secret, "values"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating value cipher")
+	}
+	keys, err := newAEAD(derive(key.Secret, "keys"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating key cipher")
+	}
+	return &keyVersion{
+		version:  key.Version,
+		values:   values,
+		keys:     keys,
+		nonceKey: derive(key.Secret, "key nonces"),
+	}, nil
+}
+
+// derive derives a 32-byte subkey from secret for the given purpose.
+func derive(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("polycry.pt/poly-go/sortedkv/encrypted: " + purpose))
+	return mac.Sum(nil)
+}
+
+// crypter seals and opens keys and values.
+type crypter struct {
+	current  *keyVersion
+	versions map[byte]*keyVersion
+	others   []*keyVersion // All versions but the current, ordered by version.
+	tables   []string      // Clear text key prefixes, nil if keys are not encrypted.
+}
+
+func newCrypter(newAEAD NewAEAD, current Key, opts options) (*crypter, error) {
+	c := &crypter{versions: make(map[byte]*keyVersion)}
+	for _, key := range append([]Key{current}, opts.oldKeys...) {
+		if _, ok := c.versions[key.Version]; ok {
+			return nil, errors.Errorf("duplicate key version %d", key.Version)
+		}
+		v, err := newKeyVersion(newAEAD, key)
+		if err != nil {
+			return nil, err
+		}
+		c.versions[key.Version] = v
+		if c.current == nil {
+			c.current = v
+		} else {
+			c.others = append(c.others, v)
+		}
+	}
+	sort.Slice(c.others, func(i, j int) bool { return c.others[i].version < c.others[j].version })
+
+	if opts.encryptKeys {
+		// The table of a key is its longest table prefix, and keys outside of
+		// all tables belong to the empty table.
+		c.tables = append([]string{""}, opts.tables...)
+		sort.Slice(c.tables, func(i, j int) bool { return len(c.tables[i]) > len(c.tables[j]) })
+	}
+	return c, nil
+}
+
+// encryptsKeys returns whether keys are encrypted.
+func (c *crypter) encryptsKeys() bool {
+	return c.tables != nil
+}
+
+// sealValue seals the value of a key with the current version.
+func (c *crypter) sealValue(key string, value []byte) []byte {
+	v := c.current
+	data := make([]byte, 1+v.values.NonceSize(), 1+v.values.NonceSize()+len(value)+v.values.Overhead())
+	data[0] = v.version
+	nonce := data[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		panic("reading randomness: " + err.Error())
+	}
+	return v.values.Seal(data, nonce, value, []byte(key))
+}
+
+// openValue opens the sealed value of a key.
+func (c *crypter) openValue(key string, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, corrupted(errors.New("empty sealed value"))
+	}
+	v, ok := c.versions[data[0]]
+	if !ok {
+		return nil, corrupted(errors.Errorf("value sealed with unknown key version %d", data[0]))
+	}
+	if len(data) < 1+v.values.NonceSize() {
+		return nil, corrupted(errors.New("sealed value too short"))
+	}
+	nonce := data[1 : 1+v.values.NonceSize()]
+	value, err := v.values.Open(nil, nonce, data[1+v.values.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, corrupted(errors.WithMessagef(err, "opening value of key %q", key))
+	}
+	return value, nil
+}
+
+// valueVersion returns the key version of a sealed value.
+func valueVersion(data []byte) byte {
+	if len(data) == 0 {
+		return 0
+	}
+	return data[0]
+}
+
+// storedKeys returns the keys that key may be stored under, the key of the
+// current version first.
+func (c *crypter) storedKeys(key string) []string {
+	if !c.encryptsKeys() {
+		return []string{key}
+	}
+	keys := []string{c.sealKey(key, c.current)}
+	for _, v := range c.others {
+		keys = append(keys, c.sealKey(key, v))
+	}
+	return keys
+}
+
+// table returns the table of a key.
+func (c *crypter) table(key string) string {
+	for _, t := range c.tables {
+		if len(key) >= len(t) && key[:len(t)] == t {
+			return t
+		}
+	}
+	return ""
+}
+
+// sealKey seals a key deterministically with the given version. The sealed key
+// is the escaped table, tableTerminator, the version, the nonce and the sealed
+// rest of the key. The nonce is derived from the key, so that equal keys
+// result in equal sealed keys.
+func (c *crypter) sealKey(key string, v *keyVersion) string {
+	table := c.table(key)
+	rest := []byte(key[len(table):])
+
+	mac := hmac.New(sha256.New, v.nonceKey)
+	var tableLen [8]byte
+	binary.BigEndian.PutUint64(tableLen[:], uint64(len(table)))
+	mac.Write(tableLen[:])
+	mac.Write([]byte(key))
+	nonce := mac.Sum(nil)[:v.keys.NonceSize()]
+
+	data := append([]byte(tablePrefix(table)), v.version)
+	data = append(data, nonce...)
+	return string(v.keys.Seal(data, nonce, rest, []byte(table)))
+}
+
+// openKey opens a sealed key and returns the key and its version.
+func (c *crypter) openKey(stored string) (string, byte, error) {
+	table, sealed, err := splitTable(stored)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(sealed) == 0 {
+		return "", 0, corrupted(errors.New("empty sealed key"))
+	}
+	v, ok := c.versions[sealed[0]]
+	if !ok {
+		return "", 0, corrupted(errors.Errorf("key sealed with unknown key version %d", sealed[0]))
+	}
+	if len(sealed) < 1+v.keys.NonceSize() {
+		return "", 0, corrupted(errors.New("sealed key too short"))
+	}
+	nonce := []byte(sealed[1 : 1+v.keys.NonceSize()])
+	rest, err := v.keys.Open(nil, nonce, []byte(sealed[1+v.keys.NonceSize():]), []byte(table))
+	if err != nil {
+		return "", 0, corrupted(errors.WithMessage(err, "opening key"))
+	}
+	return table + string(rest), v.version, nil
+}
+
+func corrupted(err error) error {
+	return &sortedkv.CorruptedError{Err: errors.WithMessage(err, "encrypted")}
+}
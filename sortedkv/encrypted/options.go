@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+type (
+	// Option configures a Database. Options are passed to New.
+	Option func(*options)
+
+	options struct {
+		oldKeys     []Key
+		encryptKeys bool
+		tables      []string
+	}
+)
+
+// WithOldKeys adds keys that are only used to read data that was written
+// with them. Their versions must differ from each other and from the current
+// key.
+func WithOldKeys(keys ...Key) Option {
+	return func(o *options) { o.oldKeys = append(o.oldKeys, keys...) }
+}
+
+// WithKeyEncryption enables the deterministic encryption of keys. The given
+// table prefixes stay in clear text, so that iterating these tables only
+// reads their keys. The table of a key is the longest of these prefixes of
+// the key.
+func WithKeyEncryption(tables ...string) Option {
+	return func(o *options) {
+		o.encryptKeys = true
+		o.tables = append(o.tables, tables...)
+	}
+}
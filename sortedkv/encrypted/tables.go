@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+// A sealed key starts with its table, in which 0x00 is escaped as 0x00 0xff,
+// followed by tableTerminator. This keeps the keys of every table in a
+// contiguous range.
+const (
+	tableEscape     = "\x00"
+	tableEscaped    = "\x00\xff"
+	tableTerminator = "\x00\x01"
+)
+
+// tablePrefix returns the common prefix of the sealed keys of a table.
+func tablePrefix(table string) string {
+	return strings.ReplaceAll(table, tableEscape, tableEscaped) + tableTerminator
+}
+
+// splitTable splits a sealed key into its table and the rest.
+func splitTable(stored string) (table string, rest string, err error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(stored, tableEscape)
+		if i < 0 || i+1 >= len(stored) {
+			return "", "", corrupted(errors.New("unterminated table of sealed key"))
+		}
+		b.WriteString(stored[:i])
+		switch stored[i : i+2] {
+		case tableEscaped:
+			b.WriteString(tableEscape)
+			stored = stored[i+2:]
+		case tableTerminator:
+			return b.String(), stored[i+2:], nil
+		default:
+			return "", "", corrupted(errors.New("invalid escape in table of sealed key"))
+		}
+	}
+}
+
+// tablesInRange returns the tables whose keys may lie in the range
+// [start, end): the longest table that contains the range and all tables
+// within that table.
+func (c *crypter) tablesInRange(start, end string) []string {
+	outer := ""
+	for _, t := range c.tables {
+		if strings.HasPrefix(start, t) && (strings.HasPrefix(end, t) || end == key.IncPrefix(t)) {
+			outer = t
+			break
+		}
+	}
+
+	var tables []string
+	for _, t := range c.tables {
+		if strings.HasPrefix(t, outer) {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// transaction encrypts the data of a transaction on the underlying database.
+type transaction struct {
+	view
+	tx sortedkv.Transaction
+}
+
+// Put saves a value under a key.
+func (t *transaction) Put(key string, value string) error {
+	return t.PutBytes(key, []byte(value))
+}
+
+// PutBytes saves a value under a key. If keys are encrypted, the entries of
+// the key under older key versions are deleted.
+func (t *transaction) PutBytes(key string, value []byte) error {
+	stored := t.c.storedKeys(key)
+	if err := t.deleteExisting(stored[1:]); err != nil {
+		return err
+	}
+	return t.tx.PutBytes(stored[0], t.c.sealValue(key, value))
+}
+
+// Delete deletes a key. It returns a *sortedkv.NotFoundError if the key does
+// not exist.
+func (t *transaction) Delete(key string) error {
+	existing, err := t.existingKeys(key)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return &sortedkv.NotFoundError{Key: key}
+	}
+	return t.deleteExisting(existing)
+}
+
+// deleteExisting deletes those of the stored keys that exist.
+func (t *transaction) deleteExisting(stored []string) error {
+	for _, s := range stored {
+		err := t.tx.Delete(s)
+		if err != nil && !sortedkv.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit commits the underlying transaction. The key of a returned
+// *sortedkv.ConflictError is decrypted.
+func (t *transaction) Commit() error {
+	err := t.tx.Commit()
+	var conflict *sortedkv.ConflictError
+	if t.c.encryptsKeys() && errors.As(err, &conflict) {
+		key, _, kerr := t.c.openKey(conflict.Key)
+		if kerr != nil {
+			return kerr
+		}
+		return &sortedkv.ConflictError{Key: key}
+	}
+	return err
+}
+
+// Discard discards the underlying transaction.
+func (t *transaction) Discard() {
+	t.tx.Discard()
+}
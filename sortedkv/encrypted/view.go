@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encrypted
+
+import (
+	"sort"
+	"strings"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+type (
+	// store is the part of a database, snapshot or transaction that a view
+	// reads.
+	store interface {
+		sortedkv.Reader
+		sortedkv.Iterable
+	}
+
+	// view decrypts the data of a store. It implements sortedkv.Reader and
+	// sortedkv.Iterable.
+	view struct {
+		store store
+		c     *crypter
+	}
+)
+
+// Has returns true if the store contains a key.
+func (v *view) Has(key string) (bool, error) {
+	for _, stored := range v.c.storedKeys(key) {
+		if has, err := v.store.Has(stored); err != nil || has {
+			return has, err
+		}
+	}
+	return false, nil
+}
+
+// Get returns the value of a key.
+func (v *view) Get(key string) (string, error) {
+	value, err := v.GetBytes(key)
+	return string(value), err
+}
+
+// GetBytes returns the value of a key as []byte.
+func (v *view) GetBytes(key string) ([]byte, error) {
+	_, value, err := v.lookup(key)
+	return value, err
+}
+
+// lookup returns the key that key is stored under and its value.
+func (v *view) lookup(key string) (string, []byte, error) {
+	for _, stored := range v.c.storedKeys(key) {
+		data, err := v.store.GetBytes(stored)
+		if sortedkv.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return "", nil, err
+		}
+		value, err := v.c.openValue(key, data)
+		return stored, value, err
+	}
+	return "", nil, &sortedkv.NotFoundError{Key: key}
+}
+
+// existingKeys returns the keys that key is stored under.
+func (v *view) existingKeys(key string) ([]string, error) {
+	var existing []string
+	for _, stored := range v.c.storedKeys(key) {
+		has, err := v.store.Has(stored)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			existing = append(existing, stored)
+		}
+	}
+	return existing, nil
+}
+
+// NewIterator creates an iterator over all keys.
+func (v *view) NewIterator() sortedkv.Iterator {
+	return v.NewIteratorWithRange("", "")
+}
+
+// NewIteratorWithRange creates an iterator over the keys in the range
+// [start, end).
+func (v *view) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	if !v.c.encryptsKeys() {
+		return newValueIterator(v.store.NewIteratorWithRange(start, end), v.c)
+	}
+	return v.newSortedIterator(start, end)
+}
+
+// NewIteratorWithPrefix creates an iterator over the keys with the given
+// prefix.
+func (v *view) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	if !v.c.encryptsKeys() {
+		return newValueIterator(v.store.NewIteratorWithPrefix(prefix), v.c)
+	}
+	return v.newSortedIterator(prefix, key.IncPrefix(prefix))
+}
+
+// newSortedIterator reads all entries in the range [start, end) from the
+// tables that may contain them and returns a sorted iterator over them.
+func (v *view) newSortedIterator(start, end string) sortedkv.Iterator {
+	var entries []entry
+	for _, table := range v.c.tablesInRange(start, end) {
+		it := v.store.NewIteratorWithPrefix(tablePrefix(table))
+		for it.Next() {
+			k, _, err := v.c.openKey(it.Key())
+			if err != nil {
+				it.Close()
+				return &sortedIterator{err: err}
+			}
+			if k < start || (end != "" && k >= end) || !strings.HasPrefix(k, table) || v.c.table(k) != table {
+				continue
+			}
+			value, err := v.c.openValue(k, it.ValueBytes())
+			if err != nil {
+				it.Close()
+				return &sortedIterator{err: err}
+			}
+			entries = append(entries, entry{key: k, value: value})
+		}
+		if err := it.Close(); err != nil {
+			return &sortedIterator{err: err}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return &sortedIterator{entries: entries, pos: -1}
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The following errors are the targets to test for with errors.Is. Each of
+// them matches all errors of the corresponding typed error. Use errors.As to
+// access the fields of a typed error.
+var (
+	ErrNotFound     = errors.New("sortedkv: not found")
+	ErrClosed       = errors.New("sortedkv: closed")
+	ErrCorrupted    = errors.New("sortedkv: corrupted")
+	ErrReadOnly     = errors.New("sortedkv: read-only")
+	ErrConflict     = errors.New("sortedkv: conflict")
+	ErrNotSupported = errors.New("sortedkv: not supported")
+	ErrAccessDenied = errors.New("sortedkv: access denied")
+)
+
+// ErrTransactionDone is returned when a transaction is used after it was
+// committed or discarded.
+var ErrTransactionDone error = &ClosedError{Resource: "transaction"}
+
+type (
+	// NotFoundError is returned whenever a key is not in the db.
+	NotFoundError struct {
+		Key string
+	}
+
+	// ClosedError is returned whenever a database or one of its resources is
+	// used after it was closed.
+	ClosedError struct {
+		// Resource is the closed resource. If empty, it is the database.
+		Resource string
+	}
+
+	// CorruptedError is returned whenever a database detects that its stored
+	// data is corrupted.
+	CorruptedError struct {
+		Err error
+	}
+
+	// ReadOnlyError is returned whenever a write is attempted on a read-only
+	// database.
+	ReadOnlyError struct {
+		Op string
+	}
+
+	// ConflictError is returned by Transaction.Commit whenever a key that was
+	// read by the transaction was changed in the database before the commit.
+	ConflictError struct {
+		Key string
+	}
+
+	// NotSupportedError is returned whenever an optional operation is not
+	// supported by the underlying database. Wrappers such as NewTable and
+	// NewWatchable implement all optional interfaces regardless of the
+	// database that they wrap and fail with a NotSupportedError if it does
+	// not support an operation. A successful type assertion therefore does
+	// not guarantee that an optional operation is supported.
+	NotSupportedError struct {
+		Op string
+	}
+
+	// AccessDeniedError is returned whenever an operation is not allowed by
+	// the policy of a restricted database.
+	AccessDeniedError struct {
+		Op  string
+		Key string // Key or start of the range of the operation, if any.
+	}
+)
+
+// Error returns the error string.
+func (e *NotFoundError) Error() string {
+	return "sortedkv: key not found: " + e.Key
+}
+
+// Is returns whether target is ErrNotFound.
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// Error returns the error string.
+func (e *ClosedError) Error() string {
+	if e.Resource == "" {
+		return "sortedkv: database closed"
+	}
+	return "sortedkv: " + e.Resource + " closed"
+}
+
+// Is returns whether target is ErrClosed.
+func (e *ClosedError) Is(target error) bool {
+	return target == ErrClosed
+}
+
+// Error returns the error string.
+func (e *CorruptedError) Error() string {
+	return "sortedkv: data corrupted: " + e.Err.Error()
+}
+
+// Is returns whether target is ErrCorrupted.
+func (e *CorruptedError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+// Unwrap returns the underlying error.
+func (e *CorruptedError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the error string.
+func (e *ReadOnlyError) Error() string {
+	if e.Op == "" {
+		return "sortedkv: database is read-only"
+	}
+	return "sortedkv: database is read-only: " + e.Op
+}
+
+// Is returns whether target is ErrReadOnly.
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnly
+}
+
+// Error returns the error string.
+func (e *ConflictError) Error() string {
+	return "sortedkv: transaction conflict on key: " + e.Key
+}
+
+// Is returns whether target is ErrConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Error returns the error string.
+func (e *NotSupportedError) Error() string {
+	return "sortedkv: operation not supported: " + e.Op
+}
+
+// Is returns whether target is ErrNotSupported.
+func (e *NotSupportedError) Is(target error) bool {
+	return target == ErrNotSupported
+}
+
+// Error returns the error string.
+func (e *AccessDeniedError) Error() string {
+	if e.Key == "" {
+		return "sortedkv: access denied: " + e.Op
+	}
+	return "sortedkv: access denied: " + e.Op + " " + e.Key
+}
+
+// Is returns whether target is ErrAccessDenied.
+func (e *AccessDeniedError) Is(target error) bool {
+	return target == ErrAccessDenied
+}
+
+// IsNotFound returns whether err is or wraps a *NotFoundError.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsClosed returns whether err is or wraps a *ClosedError.
+func IsClosed(err error) bool {
+	return errors.Is(err, ErrClosed)
+}
+
+// IsCorrupted returns whether err is or wraps a *CorruptedError.
+func IsCorrupted(err error) bool {
+	return errors.Is(err, ErrCorrupted)
+}
+
+// IsReadOnly returns whether err is or wraps a *ReadOnlyError.
+func IsReadOnly(err error) bool {
+	return errors.Is(err, ErrReadOnly)
+}
+
+// IsConflict returns whether err is or wraps a *ConflictError.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsNotSupported returns whether err is or wraps a *NotSupportedError.
+func IsNotSupported(err error) bool {
+	return errors.Is(err, ErrNotSupported)
+}
+
+// IsAccessDenied returns whether err is or wraps an *AccessDeniedError.
+func IsAccessDenied(err error) bool {
+	return errors.Is(err, ErrAccessDenied)
+}
+
+// stripErrorPrefix removes the prefix from the key of a *NotFoundError or
+// *ConflictError. Other errors are returned unchanged. It is used by tables to
+// report keys relative to the table.
+func stripErrorPrefix(err error, prefix string) error {
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) && strings.HasPrefix(notFound.Key, prefix) {
+		return &NotFoundError{Key: notFound.Key[len(prefix):]}
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) && strings.HasPrefix(conflict.Key, prefix) {
+		return &ConflictError{Key: conflict.Key[len(prefix):]}
+	}
+	return err
+}
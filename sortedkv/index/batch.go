@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package index
+
+import "polycry.pt/poly-go/sortedkv"
+
+type (
+	// Batch buffers writes to an indexed table until Apply is called. Apply
+	// writes the records and all their index entries atomically.
+	Batch struct {
+		t   *Table
+		ops []batchOp
+	}
+
+	// batchOp is a buffered put, or deletion if value is nil.
+	batchOp struct {
+		key   string
+		value *string
+	}
+)
+
+// NewBatch creates a batch on the table.
+func (t *Table) NewBatch() sortedkv.Batch {
+	return &Batch{t: t}
+}
+
+// Put puts a record into the batch.
+func (b *Batch) Put(key, value string) error {
+	b.ops = append(b.ops, batchOp{key: key, value: &value})
+	return nil
+}
+
+// PutBytes puts a record into the batch.
+func (b *Batch) PutBytes(key string, value []byte) error {
+	return b.Put(key, string(value))
+}
+
+// Delete deletes a record in the batch. Deleting an absent record is not an
+// error.
+func (b *Batch) Delete(key string) error {
+	b.ops = append(b.ops, batchOp{key: key})
+	return nil
+}
+
+// Apply writes the records of the batch and updates their index entries
+// atomically.
+func (b *Batch) Apply() error {
+	b.t.mu.Lock()
+	defer b.t.mu.Unlock()
+
+	// Holding t.mu, the records cannot change until the batch is applied.
+	batch := b.t.db.NewBatch()
+	pending := make(map[string]*string)
+	for _, op := range b.ops {
+		old, ok := pending[op.key]
+		if !ok {
+			value, err := b.t.db.Get(recordPrefix + op.key)
+			if err == nil {
+				old = &value
+			} else if !sortedkv.IsNotFound(err) {
+				return err
+			}
+		}
+		if old == nil && op.value == nil {
+			continue
+		}
+		if err := b.t.update(batch, op.key, old, op.value); err != nil {
+			return err
+		}
+		pending[op.key] = op.value
+	}
+	return batch.Apply()
+}
+
+// Reset resets the batch so that it can be reused.
+func (b *Batch) Reset() {
+	b.ops = nil
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package index maintains secondary indexes of sortedkv tables. A Table stores
+// primary records under their keys and, for every declared Index, an index
+// entry per index value of a record. Every write updates the record and all
+// its index entries atomically in one sortedkv.Batch, so that the indexes
+// never drift out of sync with the records.
+//
+// All writes to an indexed table must go through its Table, which serializes
+// them. The index entries can then be looked up and range-scanned by index
+// value.
+package index // import "polycry.pt/poly-go/sortedkv/index"
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/typed"
+)
+
+type (
+	// Extractor returns the index values of a record. A record may have no,
+	// one or multiple index values.
+	Extractor func(key, value string) []string
+
+	// Index declares a secondary index.
+	Index struct {
+		Name    string
+		Extract Extractor
+	}
+
+	// Table is a table of records with secondary indexes.
+	Table struct {
+		db      sortedkv.Database // Table of the records and index entries.
+		indexes []Index
+
+		mu sync.Mutex // Serializes writes.
+	}
+
+	// entryKey is the key of an index entry: the index name, the index value
+	// and the key of the record.
+	entryKey = typed.Tuple3[string, string, string]
+)
+
+// The records are stored under recordPrefix and their keys. The index entries
+// are stored under indexPrefix and their encoded entryKey and have an empty
+// value.
+const (
+	recordPrefix = "r"
+	indexPrefix  = "i"
+)
+
+var entryCodec = typed.Tuple3Codec[string, string, string]{
+	C1: typed.String[string]{},
+	C2: typed.String[string]{},
+	C3: typed.String[string]{},
+}
+
+// NewTable creates an indexed table on the table of db with the given prefix.
+// The index names must be unique and non-empty.
+func NewTable(db sortedkv.Database, prefix string, indexes ...Index) (*Table, error) {
+	names := make(map[string]struct{}, len(indexes))
+	for _, idx := range indexes {
+		if idx.Name == "" || idx.Extract == nil {
+			return nil, errors.New("index needs a name and an extractor")
+		}
+		if _, ok := names[idx.Name]; ok {
+			return nil, errors.Errorf("duplicate index %q", idx.Name)
+		}
+		names[idx.Name] = struct{}{}
+	}
+
+	return &Table{
+		db:      sortedkv.NewTable(db, prefix),
+		indexes: append([]Index(nil), indexes...),
+	}, nil
+}
+
+// Has returns true if the table contains a record with the given key.
+func (t *Table) Has(key string) (bool, error) {
+	return t.db.Has(recordPrefix + key)
+}
+
+// Get returns the value of a record. The key of a returned
+// *sortedkv.NotFoundError is the key of the record.
+func (t *Table) Get(key string) (string, error) {
+	value, err := t.db.Get(recordPrefix + key)
+	return value, stripNotFound(err, key)
+}
+
+// GetBytes returns the value of a record as []byte.
+func (t *Table) GetBytes(key string) ([]byte, error) {
+	value, err := t.db.GetBytes(recordPrefix + key)
+	return value, stripNotFound(err, key)
+}
+
+// Put inserts or overwrites a record and updates its index entries
+// atomically.
+func (t *Table) Put(key, value string) error {
+	b := t.NewBatch()
+	if err := b.Put(key, value); err != nil {
+		return err
+	}
+	return b.Apply()
+}
+
+// PutBytes inserts or overwrites a record and updates its index entries
+// atomically.
+func (t *Table) PutBytes(key string, value []byte) error {
+	return t.Put(key, string(value))
+}
+
+// Delete removes a record and its index entries atomically. If the record is
+// not present, a *sortedkv.NotFoundError is returned.
+func (t *Table) Delete(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old, err := t.db.Get(recordPrefix + key)
+	if err != nil {
+		return stripNotFound(err, key)
+	}
+	batch := t.db.NewBatch()
+	if err := t.update(batch, key, &old, nil); err != nil {
+		return err
+	}
+	return batch.Apply()
+}
+
+// NewIterator creates an iterator over all records, in key order.
+func (t *Table) NewIterator() sortedkv.Iterator {
+	return sortedkv.NewTable(t.db, recordPrefix).NewIterator()
+}
+
+// update writes the changes of a record from old to value into batch. A nil
+// old or value denotes an absent record.
+func (t *Table) update(batch sortedkv.Batch, key string, old, value *string) error {
+	for _, idx := range t.indexes {
+		if old != nil {
+			for _, v := range idx.Extract(key, *old) {
+				if err := batch.Delete(indexPrefix + entryCodec.EncodeKey(entryKey{V1: idx.Name, V2: v, V3: key})); err != nil {
+					return err
+				}
+			}
+		}
+		if value != nil {
+			for _, v := range idx.Extract(key, *value) {
+				if err := batch.Put(indexPrefix+entryCodec.EncodeKey(entryKey{V1: idx.Name, V2: v, V3: key}), ""); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if value == nil {
+		return batch.Delete(recordPrefix + key)
+	}
+	return batch.Put(recordPrefix+key, *value)
+}
+
+// index returns the index with the given name.
+func (t *Table) index(name string) (Index, error) {
+	for _, idx := range t.indexes {
+		if idx.Name == name {
+			return idx, nil
+		}
+	}
+	return Index{}, errors.Errorf("unknown index %q", name)
+}
+
+// stripNotFound sets the key of a *sortedkv.NotFoundError to the key of the
+// record.
+func stripNotFound(err error, key string) error {
+	if sortedkv.IsNotFound(err) {
+		return &sortedkv.NotFoundError{Key: key}
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package index
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+// Test records have values of the form "city|tag,tag".
+var testIndexes = []Index{
+	{Name: "city", Extract: func(_, value string) []string {
+		return []string{strings.SplitN(value, "|", 2)[0]}
+	}},
+	{Name: "tag", Extract: func(_, value string) []string {
+		parts := strings.SplitN(value, "|", 2)
+		if len(parts) < 2 || parts[1] == "" {
+			return nil
+		}
+		return strings.Split(parts[1], ",")
+	}},
+}
+
+func newTestTable(t *testing.T, db sortedkv.Database) *Table {
+	t.Helper()
+	table, err := NewTable(db, "people.", testIndexes...)
+	require.NoError(t, err)
+	return table
+}
+
+func TestTable(t *testing.T) {
+	db := memorydb.NewDatabase()
+	table := newTestTable(t, db)
+
+	require.NoError(t, table.Put("alice", "berlin|a,b"))
+	require.NoError(t, table.Put("bob", "paris|b"))
+	require.NoError(t, table.Put("carol", "berlin|"))
+	require.NoError(t, table.Put("dave\x00", "bonn\x00|a"))
+	requireConsistent(t, table)
+
+	value, err := table.Get("alice")
+	require.NoError(t, err)
+	assert.Equal(t, "berlin|a,b", value)
+	assertLookup(t, table, "city", "berlin", "alice", "carol")
+	assertLookup(t, table, "city", "bonn", nil...)
+	assertLookup(t, table, "city", "bonn\x00", "dave\x00")
+	assertLookup(t, table, "tag", "b", "alice", "bob")
+
+	assertScan(t, table, "city", "b", "p", "berlin:alice", "berlin:carol", "bonn\x00:dave\x00")
+	assertScan(t, table, "city", "bonn", "", "bonn\x00:dave\x00", "paris:bob")
+	assertScan(t, table, "tag", "", "", "a:alice", "a:dave\x00", "b:alice", "b:bob")
+
+	require.NoError(t, table.Put("alice", "paris|c"))
+	require.NoError(t, table.Delete("bob"))
+	err = table.Delete("bob")
+	assert.Equal(t, &sortedkv.NotFoundError{Key: "bob"}, err)
+	requireConsistent(t, table)
+	assertLookup(t, table, "city", "paris", "alice")
+	assertLookup(t, table, "tag", "b", nil...)
+	assertLookup(t, table, "tag", "c", "alice")
+
+	_, err = table.Lookup("unknown", "")
+	assert.Error(t, err)
+}
+
+func TestTable_NoSnapshots(t *testing.T) {
+	// Without snapshots, the index iterators read the live table.
+	table := newTestTable(t, struct{ sortedkv.Database }{memorydb.NewDatabase()})
+	require.NoError(t, table.Put("alice", "berlin|a"))
+	require.NoError(t, table.Put("bob", "paris|a"))
+	assertScan(t, table, "city", "", "", "berlin:alice", "paris:bob")
+	assertLookup(t, table, "tag", "a", "alice", "bob")
+}
+
+func TestNewTable(t *testing.T) {
+	db := memorydb.NewDatabase()
+	_, err := NewTable(db, "", testIndexes[0], testIndexes[0])
+	assert.Error(t, err, "duplicate index")
+	_, err = NewTable(db, "", Index{Name: "", Extract: testIndexes[0].Extract})
+	assert.Error(t, err, "empty name")
+	_, err = NewTable(db, "", Index{Name: "name"})
+	assert.Error(t, err, "no extractor")
+}
+
+func TestBatch(t *testing.T) {
+	table := newTestTable(t, memorydb.NewDatabase())
+	require.NoError(t, table.Put("alice", "berlin|a"))
+
+	batch := table.NewBatch()
+	require.NoError(t, batch.Put("bob", "bonn|b"))
+	require.NoError(t, batch.Put("bob", "paris|c"))
+	require.NoError(t, batch.Delete("alice"))
+	require.NoError(t, batch.Put("alice", "rome|a"))
+	require.NoError(t, batch.Delete("carol"))
+	assertLookup(t, table, "city", "berlin", "alice")
+	require.NoError(t, batch.Apply())
+
+	requireConsistent(t, table)
+	assertLookup(t, table, "city", "berlin", nil...)
+	assertLookup(t, table, "city", "bonn", nil...)
+	assertLookup(t, table, "city", "paris", "bob")
+	assertLookup(t, table, "city", "rome", "alice")
+}
+
+func TestBatch_Atomic(t *testing.T) {
+	db := &failingDatabase{Database: memorydb.NewDatabase()}
+	table := newTestTable(t, db)
+	require.NoError(t, table.Put("alice", "berlin|a"))
+
+	db.fail = true
+	assert.Error(t, table.Put("alice", "paris|b"))
+	assert.Error(t, table.Delete("alice"))
+	requireConsistent(t, table)
+	assertLookup(t, table, "city", "berlin", "alice")
+}
+
+func TestTable_Random(t *testing.T) {
+	rng := pkgtest.Prng(t)
+	table := newTestTable(t, memorydb.NewDatabase())
+	cities := []string{"berlin", "bonn", "paris"}
+	tags := []string{"a", "b", "c", "d"}
+	for i := 0; i < 500; i++ {
+		batch := table.NewBatch()
+		for j := rng.Intn(4); j >= 0; j-- {
+			key := "k" + strconv.Itoa(rng.Intn(20))
+			if rng.Intn(3) == 0 {
+				require.NoError(t, batch.Delete(key))
+				continue
+			}
+			var recordTags []string
+			for _, tag := range tags {
+				if rng.Intn(2) == 0 {
+					recordTags = append(recordTags, tag)
+				}
+			}
+			value := cities[rng.Intn(len(cities))] + "|" + strings.Join(recordTags, ",")
+			require.NoError(t, batch.Put(key, value))
+		}
+		require.NoError(t, batch.Apply())
+	}
+	requireConsistent(t, table)
+}
+
+// requireConsistent checks that the index entries match the records.
+func requireConsistent(t *testing.T, table *Table) {
+	t.Helper()
+	var expected []string
+	it := table.NewIterator()
+	for it.Next() {
+		for _, idx := range table.indexes {
+			for _, v := range idx.Extract(it.Key(), it.Value()) {
+				expected = append(expected, idx.Name+"/"+v+"/"+it.Key())
+			}
+		}
+	}
+	require.NoError(t, it.Close())
+
+	var actual []string
+	it = table.db.NewIteratorWithPrefix(indexPrefix)
+	for it.Next() {
+		entry, err := entryCodec.DecodeKey(it.Key()[len(indexPrefix):])
+		require.NoError(t, err)
+		actual = append(actual, entry.V1+"/"+entry.V2+"/"+entry.V3)
+	}
+	require.NoError(t, it.Close())
+
+	sort.Strings(expected)
+	sort.Strings(actual)
+	require.Equal(t, expected, actual)
+}
+
+func assertLookup(t *testing.T, table *Table, index, value string, keys ...string) {
+	t.Helper()
+	actual, err := table.Lookup(index, value)
+	require.NoError(t, err)
+	assert.Equal(t, keys, actual)
+}
+
+func assertScan(t *testing.T, table *Table, index, start, end string, entries ...string) {
+	t.Helper()
+	it, err := table.NewIndexIterator(index, start, end)
+	require.NoError(t, err)
+	var actual []string
+	for it.Next() {
+		value, err := table.Get(it.Key())
+		require.NoError(t, err)
+		assert.Equal(t, value, it.Value())
+		actual = append(actual, it.IndexValue()+":"+it.Key())
+	}
+	require.NoError(t, it.Close())
+	assert.Equal(t, entries, actual)
+}
+
+// failingDatabase is a database whose batches fail to apply if fail is set.
+type failingDatabase struct {
+	sortedkv.Database
+	fail bool
+}
+
+func (d *failingDatabase) NewBatch() sortedkv.Batch {
+	return &failingBatch{Batch: d.Database.NewBatch(), db: d}
+}
+
+type failingBatch struct {
+	sortedkv.Batch
+	db *failingDatabase
+}
+
+func (b *failingBatch) Apply() error {
+	if b.db.fail {
+		return errors.New("batch failed")
+	}
+	return b.Batch.Apply()
+}
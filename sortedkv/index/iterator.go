@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package index
+
+import (
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// Iterator iterates over the records of an index range, ordered by index
+// value and then by key. A record with multiple index values in the range is
+// returned once per index value. If the database is a sortedkv.Snapshotter,
+// the iterator reads from a snapshot and is therefore consistent.
+type Iterator struct {
+	view     view
+	snapshot sortedkv.Snapshot // nil if the database has no snapshots.
+	entries  sortedkv.Iterator
+
+	indexValue string
+	key        string
+	value      string
+	err        error
+}
+
+// view is the part of a database or snapshot that an Iterator reads.
+type view interface {
+	sortedkv.Reader
+	sortedkv.Iterable
+}
+
+// Lookup returns the keys of all records with the given index value, in key
+// order.
+func (t *Table) Lookup(index, value string) ([]string, error) {
+	it, err := t.NewIndexIterator(index, value, value+"\x00")
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	return keys, it.Close()
+}
+
+// NewIndexIterator creates an iterator over the records with index values in
+// the range [start, end). An empty end denotes no upper bound.
+func (t *Table) NewIndexIterator(index, start, end string) (*Iterator, error) {
+	if _, err := t.index(index); err != nil {
+		return nil, err
+	}
+
+	// The entries of the index lie between (index, start, "") and either
+	// (index, end, "") or, without an end, the entries of the next index.
+	startKey := entryCodec.EncodeKey(entryKey{V1: index, V2: start})
+	endKey := entryCodec.EncodeKey(entryKey{V1: index + "\x00"})
+	if end != "" {
+		endKey = entryCodec.EncodeKey(entryKey{V1: index, V2: end})
+	}
+	// Without snapshot support, the iterator reads the live table.
+	it := &Iterator{view: t.db}
+	if snapshotter, ok := t.db.(sortedkv.Snapshotter); ok {
+		s, err := snapshotter.NewSnapshot()
+		if err == nil {
+			it.view, it.snapshot = s, s
+		} else if !sortedkv.IsNotSupported(err) {
+			return nil, err
+		}
+	}
+	it.entries = it.view.NewIteratorWithRange(indexPrefix+startKey, indexPrefix+endKey)
+	return it, nil
+}
+
+// Next moves the iterator to the next record. It returns false if the
+// iterator is exhausted or failed.
+func (it *Iterator) Next() bool {
+	for it.err == nil && it.entries.Next() {
+		entry, err := entryCodec.DecodeKey(it.entries.Key()[len(indexPrefix):])
+		if err != nil {
+			it.err = &sortedkv.CorruptedError{Err: errors.WithMessage(err, "decoding index entry")}
+			return false
+		}
+		value, err := it.view.Get(recordPrefix + entry.V3)
+		if sortedkv.IsNotFound(err) {
+			// Without a snapshot, the record may have been deleted after the
+			// entry was read.
+			continue
+		} else if err != nil {
+			it.err = err
+			return false
+		}
+		it.indexValue, it.key, it.value = entry.V2, entry.V3, value
+		return true
+	}
+	return false
+}
+
+// IndexValue returns the index value of the current record.
+func (it *Iterator) IndexValue() string {
+	return it.indexValue
+}
+
+// Key returns the key of the current record.
+func (it *Iterator) Key() string {
+	return it.key
+}
+
+// Value returns the value of the current record.
+func (it *Iterator) Value() string {
+	return it.value
+}
+
+// Close releases the iterator. It returns any accumulated error.
+func (it *Iterator) Close() error {
+	err := it.entries.Close()
+	if it.snapshot != nil {
+		if serr := it.snapshot.Close(); err == nil {
+			err = serr
+		}
+		it.snapshot = nil
+	}
+	if it.err != nil {
+		return it.err
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package txn
+
+import "polycry.pt/poly-go/sortedkv"
+
+// iterator merges a database iterator with the buffered writes of a
+// transaction. Buffered writes take precedence over database entries.
+type iterator struct {
+	tx      *Transaction
+	db      sortedkv.Iterator
+	pending []Write
+
+	started bool
+	dbValid bool // Whether dbKey and dbValue hold the next database entry.
+	dbKey   string
+	dbValue string
+
+	key   string
+	value string
+	err   error
+}
+
+// newIterator creates an iterator merging db with the buffered writes for
+// which inRange returns true. Later writes to the transaction are not visible
+// to the iterator.
+func (t *Transaction) newIterator(db sortedkv.Iterator, inRange func(string) bool) *iterator {
+	return &iterator{
+		tx:      t,
+		db:      db,
+		pending: t.pendingWrites(inRange),
+	}
+}
+
+// advanceDB moves the database iterator to its next entry.
+func (it *iterator) advanceDB() {
+	it.dbValid = it.db.Next()
+	if it.dbValid {
+		it.dbKey, it.dbValue = it.db.Key(), it.db.Value()
+	}
+}
+
+// Next moves the iterator to the next key/value pair.
+func (it *iterator) Next() bool {
+	if it.db == nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		it.advanceDB()
+	}
+
+	for {
+		hasPending := len(it.pending) > 0
+		if !hasPending && !it.dbValid {
+			it.key, it.value = "", ""
+			return false
+		}
+
+		if hasPending && (!it.dbValid || it.pending[0].Key <= it.dbKey) {
+			w := it.pending[0]
+			it.pending = it.pending[1:]
+			if it.dbValid && w.Key == it.dbKey {
+				it.advanceDB() // Shadowed by the buffered write.
+			}
+			if w.Delete {
+				continue
+			}
+			it.key, it.value = w.Key, w.Value
+			return true
+		}
+
+		it.key, it.value = it.dbKey, it.dbValue
+		it.tx.recordRead(it.key, it.value, true)
+		it.advanceDB()
+		return true
+	}
+}
+
+// Key returns the key of the current element.
+func (it *iterator) Key() string {
+	return it.key
+}
+
+// Value returns the value of the current element.
+func (it *iterator) Value() string {
+	return it.value
+}
+
+// ValueBytes returns the value converted to bytes of the current element.
+func (it *iterator) ValueBytes() []byte {
+	return []byte(it.value)
+}
+
+// Close closes this iterator.
+func (it *iterator) Close() error {
+	err := it.err
+	it.err = nil
+	if it.db != nil {
+		if dberr := it.db.Close(); err == nil {
+			err = dberr
+		}
+		it.db = nil
+	}
+	it.key, it.value = "", ""
+	it.pending = nil
+	return err
+}
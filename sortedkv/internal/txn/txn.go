@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package txn implements optimistic read-write transactions on top of a few
+// primitives of a sortedkv backend. It is shared by the sortedkv backends.
+package txn // import "polycry.pt/poly-go/sortedkv/internal/txn"
+
+import (
+	"sort"
+	"strings"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// LookupFunc returns the value of a key and whether the key is present.
+	LookupFunc func(key string) (value string, ok bool, err error)
+
+	// CommitFunc atomically commits a transaction. It has to call
+	// tx.Validate() on the current state of the database and, if that
+	// succeeds, apply tx.Writes() before any other write can happen.
+	CommitFunc func(tx *Transaction) error
+
+	// Write is a buffered write of a transaction.
+	Write struct {
+		Key    string
+		Value  string
+		Delete bool
+	}
+
+	// Transaction implements sortedkv.Transaction.
+	Transaction struct {
+		lookup LookupFunc
+		db     sortedkv.Iterable
+		commit CommitFunc
+
+		reads  map[string]read
+		writes map[string]Write
+		done   bool
+	}
+
+	// read records the state of a key when it was first read.
+	read struct {
+		value string
+		ok    bool
+	}
+)
+
+// New creates a new transaction that reads from the database using lookup and
+// db and commits using commit.
+func New(lookup LookupFunc, db sortedkv.Iterable, commit CommitFunc) *Transaction {
+	return &Transaction{
+		lookup: lookup,
+		db:     db,
+		commit: commit,
+		reads:  make(map[string]read),
+		writes: make(map[string]Write),
+	}
+}
+
+// get returns the value of key as seen by the transaction and records the
+// read if the value was read from the database.
+func (t *Transaction) get(key string) (string, bool, error) {
+	if t.done {
+		return "", false, sortedkv.ErrTransactionDone
+	}
+	if w, ok := t.writes[key]; ok {
+		return w.Value, !w.Delete, nil
+	}
+
+	value, ok, err := t.lookup(key)
+	if err != nil {
+		return "", false, err
+	}
+	t.recordRead(key, value, ok)
+	return value, ok, nil
+}
+
+// recordRead records that the key had the given state. Only the first read of
+// a key is recorded.
+func (t *Transaction) recordRead(key, value string, ok bool) {
+	if _, recorded := t.reads[key]; !recorded {
+		t.reads[key] = read{value: value, ok: ok}
+	}
+}
+
+// Has returns whether the key is present in the transaction's view.
+func (t *Transaction) Has(key string) (bool, error) {
+	_, ok, err := t.get(key)
+	return ok, err
+}
+
+// Get returns the value of the key in the transaction's view.
+func (t *Transaction) Get(key string) (string, error) {
+	value, ok, err := t.get(key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", &sortedkv.NotFoundError{Key: key}
+	}
+	return value, nil
+}
+
+// GetBytes returns the value of the key in the transaction's view in bytes.
+func (t *Transaction) GetBytes(key string) ([]byte, error) {
+	value, err := t.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// Put buffers a write of value to key.
+func (t *Transaction) Put(key string, value string) error {
+	if t.done {
+		return sortedkv.ErrTransactionDone
+	}
+	t.writes[key] = Write{Key: key, Value: value}
+	return nil
+}
+
+// PutBytes buffers a write of value to key.
+func (t *Transaction) PutBytes(key string, value []byte) error {
+	return t.Put(key, string(value))
+}
+
+// Delete buffers the deletion of key. It fails if the key is not present in
+// the transaction's view.
+func (t *Transaction) Delete(key string) error {
+	_, ok, err := t.get(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &sortedkv.NotFoundError{Key: key}
+	}
+	t.writes[key] = Write{Key: key, Delete: true}
+	return nil
+}
+
+// NewIterator creates an iterator over the transaction's view.
+func (t *Transaction) NewIterator() sortedkv.Iterator {
+	return t.NewIteratorWithRange("", "")
+}
+
+// NewIteratorWithRange creates an iterator over the transaction's view of the
+// range [start, end).
+func (t *Transaction) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	if t.done {
+		return &iterator{err: sortedkv.ErrTransactionDone}
+	}
+	return t.newIterator(t.db.NewIteratorWithRange(start, end), func(key string) bool {
+		return key >= start && (end == "" || key < end)
+	})
+}
+
+// NewIteratorWithPrefix creates an iterator over the transaction's view of all
+// keys with the given prefix.
+func (t *Transaction) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	if t.done {
+		return &iterator{err: sortedkv.ErrTransactionDone}
+	}
+	return t.newIterator(t.db.NewIteratorWithPrefix(prefix), func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// Commit validates the transaction's reads and applies its writes.
+func (t *Transaction) Commit() error {
+	if t.done {
+		return sortedkv.ErrTransactionDone
+	}
+	t.done = true
+	return t.commit(t)
+}
+
+// Discard drops all buffered writes.
+func (t *Transaction) Discard() {
+	t.done = true
+	t.reads = nil
+	t.writes = nil
+}
+
+// Validate checks that all keys read by the transaction still have the same
+// state according to lookup. It returns a *sortedkv.ConflictError otherwise.
+func (t *Transaction) Validate(lookup LookupFunc) error {
+	keys := make([]string, 0, len(t.reads))
+	for key := range t.reads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok, err := lookup(key)
+		if err != nil {
+			return err
+		}
+		if r := t.reads[key]; r.ok != ok || r.value != value {
+			return &sortedkv.ConflictError{Key: key}
+		}
+	}
+	return nil
+}
+
+// Writes returns the buffered writes in ascending key order.
+func (t *Transaction) Writes() []Write {
+	return t.pendingWrites(func(string) bool { return true })
+}
+
+// pendingWrites returns the buffered writes for which filter returns true, in
+// ascending key order.
+func (t *Transaction) pendingWrites(filter func(string) bool) []Write {
+	writes := make([]Write, 0, len(t.writes))
+	for key, w := range t.writes {
+		if filter(key) {
+			writes = append(writes, w)
+		}
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Key < writes[j].Key })
+	return writes
+}
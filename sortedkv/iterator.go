@@ -33,6 +33,36 @@ type Iterator interface {
 	Close() error
 }
 
+// SeekableIterator is an Iterator that can be moved freely within its key
+// range. The iterators of the sortedkv backends implement it, which can be
+// checked with a type assertion.
+//
+// The iterator has a position before the first and after the last key/value
+// pair of its range. Next() moves from before the first to the first pair, and
+// Prev() moves from after the last to the last pair. When Next() or Prev()
+// return false, the iterator is positioned after the last or before the first
+// pair, respectively.
+type SeekableIterator interface {
+	Iterator
+
+	// Seek moves the iterator to the first key/value pair whose key is greater
+	// than or equal to the given key. It returns whether such a pair exists in
+	// the iterator's range.
+	Seek(key string) bool
+
+	// Prev moves the iterator to the previous key/value pair. It returns false
+	// if the iterator is exhausted or closed, and true otherwise.
+	Prev() bool
+
+	// First moves the iterator to the first key/value pair. It returns false if
+	// the iterator's range is empty.
+	First() bool
+
+	// Last moves the iterator to the last key/value pair. It returns false if
+	// the iterator's range is empty.
+	Last() bool
+}
+
 // Iterable wraps the NewIterator methods of a backing data store.
 type Iterable interface {
 	// NewIterator creates a binary-alphabetical iterator over the entire keyspace
@@ -1,6 +1,7 @@
 // SPDX-License-Identifier: Apache-2.0
 
-// Package key of sortedkv provides helper functions to manipulate db keys
+// Package key of sortedkv provides helper functions to manipulate db keys and
+// an order-preserving encoding of composite keys.
 package key // import "polycry.pt/poly-go/sortedkv/key"
 
 // Next returns the key with a zero byte appended, which is the next key in the
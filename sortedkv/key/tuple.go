@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package key
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Tuple is a composite key. Its elements can be strings, byte slices, signed
+// and unsigned integers and *big.Int.
+//
+// Tuples are encoded such that the order of encoded tuples is the order of
+// the tuples: Tuples are ordered element by element and a tuple sorts before
+// all longer tuples that it is a prefix of. Elements of different kinds are
+// ordered byte slices first, then strings, then integers. Integers of all
+// types share one order, so int8(-1) < uint64(1) < big.NewInt(2).
+//
+// Elements are escaped, so that they can contain any bytes. The encoding of a
+// tuple is a prefix of the encodings of all tuples that start with its
+// elements. It is also a prefix of the encodings of some unrelated tuples,
+// whose last string or byte slice element has the same bytes and continues
+// with a 0x00 byte, so partial tuples must be scanned with Range instead of
+// NewIteratorWithPrefix.
+type Tuple []interface{}
+
+// Type tags of the encoded elements, ordered by kind. Integers of up to 8
+// bytes have a tag per length, so that their order is the order of the tags.
+const (
+	tagBytes  = 0x01
+	tagString = 0x02
+
+	tagNegBigInt = 0x0b // Negative integer of more than 8 bytes.
+	tagIntZero   = 0x14 // Zero. The tag of an integer of n bytes is 0x14±n.
+	tagPosBigInt = 0x1d // Positive integer of more than 8 bytes.
+
+	intSize       = 8   // Maximal number of bytes of a small integer.
+	maxBigIntSize = 255 // Maximal number of bytes of a big integer.
+)
+
+// Strings and bytes are terminated by a 0x00 byte. A 0x00 byte within them is
+// escaped as 0x00 0xff.
+const (
+	escape     = "\x00"
+	escaped    = "\x00\xff"
+	terminator = '\x00'
+	rangeEnd   = "\xff" // Sorts after all type tags.
+)
+
+// Encode encodes the tuple. It fails if an element has an unsupported type or
+// is an integer of more than 255 bytes.
+func (t Tuple) Encode() (string, error) {
+	var b strings.Builder
+	for i, e := range t {
+		if err := encodeElement(&b, e); err != nil {
+			return "", errors.WithMessagef(err, "encoding element %d", i)
+		}
+	}
+	return b.String(), nil
+}
+
+// Range returns the key range [start, end) of the tuple and all tuples that
+// start with its elements. The range of the empty tuple is the whole key
+// space.
+//
+// The range ends before the encoding of the tuple followed by 0xff, since
+// every further element starts with a type tag below 0xff, but an escaped
+// 0x00 byte continues a terminated element with 0xff.
+func (t Tuple) Range() (start, end string, err error) {
+	if start, err = t.Encode(); err != nil || start == "" {
+		return start, "", err
+	}
+	return start, start + rangeEnd, nil
+}
+
+// Decode decodes an encoded tuple. Strings are decoded as string and byte
+// slices as []byte. Integers are decoded as int64 if they fit, else as uint64
+// if they fit, else as *big.Int.
+func Decode(key string) (Tuple, error) {
+	t := Tuple{}
+	for len(key) > 0 {
+		var (
+			e   interface{}
+			err error
+		)
+		if e, key, err = decodeElement(key); err != nil {
+			return nil, errors.WithMessagef(err, "decoding element %d", len(t))
+		}
+		t = append(t, e)
+	}
+	return t, nil
+}
+
+func encodeElement(b *strings.Builder, e interface{}) error {
+	switch e := e.(type) {
+	case string:
+		encodeString(b, tagString, e)
+	case []byte:
+		encodeString(b, tagBytes, string(e))
+	case int:
+		encodeInt(b, int64(e))
+	case int8:
+		encodeInt(b, int64(e))
+	case int16:
+		encodeInt(b, int64(e))
+	case int32:
+		encodeInt(b, int64(e))
+	case int64:
+		encodeInt(b, e)
+	case uint:
+		encodeUint(b, false, uint64(e))
+	case uint8:
+		encodeUint(b, false, uint64(e))
+	case uint16:
+		encodeUint(b, false, uint64(e))
+	case uint32:
+		encodeUint(b, false, uint64(e))
+	case uint64:
+		encodeUint(b, false, e)
+	case *big.Int:
+		return encodeBigInt(b, e)
+	default:
+		return errors.Errorf("unsupported type %T", e)
+	}
+	return nil
+}
+
+func encodeString(b *strings.Builder, tag byte, s string) {
+	b.WriteByte(tag)
+	b.WriteString(strings.ReplaceAll(s, escape, escaped))
+	b.WriteByte(terminator)
+}
+
+func encodeInt(b *strings.Builder, x int64) {
+	if x < 0 {
+		encodeUint(b, true, uint64(-(x+1))+1) // -x overflows for math.MinInt64.
+		return
+	}
+	encodeUint(b, false, uint64(x))
+}
+
+// encodeUint encodes an integer with magnitude x as its tag, followed by its
+// magnitude in the minimal number of bytes big-endian. The magnitude of
+// negative integers is inverted, so that larger magnitudes sort first.
+func encodeUint(b *strings.Builder, neg bool, x uint64) {
+	var buf [intSize]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	n := intSize
+	for n > 0 && buf[intSize-n] == 0 {
+		n--
+	}
+	mag := buf[intSize-n:]
+	if !neg {
+		b.WriteByte(byte(tagIntZero + n))
+		b.Write(mag)
+		return
+	}
+	b.WriteByte(byte(tagIntZero - n))
+	for _, m := range mag {
+		b.WriteByte(^m)
+	}
+}
+
+// encodeBigInt encodes integers of up to 8 bytes like the other integers.
+// Larger integers are encoded as their tag, followed by the length of their
+// magnitude as one byte and the magnitude, inverted for negative integers.
+// A nil integer is encoded like zero.
+func encodeBigInt(b *strings.Builder, x *big.Int) error {
+	if x == nil || x.IsUint64() {
+		var u uint64
+		if x != nil {
+			u = x.Uint64()
+		}
+		encodeUint(b, false, u)
+		return nil
+	}
+	mag := x.Bytes()
+	if len(mag) <= intSize {
+		encodeUint(b, true, new(big.Int).Neg(x).Uint64())
+		return nil
+	}
+	if len(mag) > maxBigIntSize {
+		return errors.Errorf("integer of %d bytes exceeds maximum of %d bytes", len(mag), maxBigIntSize)
+	}
+
+	if x.Sign() > 0 {
+		b.WriteByte(tagPosBigInt)
+		b.WriteByte(byte(len(mag)))
+		b.Write(mag)
+		return nil
+	}
+	b.WriteByte(tagNegBigInt)
+	b.WriteByte(^byte(len(mag)))
+	for _, m := range mag {
+		b.WriteByte(^m)
+	}
+	return nil
+}
+
+// decodeElement decodes the first element of key and returns the rest of key.
+func decodeElement(key string) (interface{}, string, error) {
+	tag := key[0]
+	switch {
+	case tag == tagString:
+		return decodeString(key[1:])
+	case tag == tagBytes:
+		s, rest, err := decodeString(key[1:])
+		return []byte(s), rest, err
+	case tag == tagNegBigInt || tag == tagPosBigInt:
+		return decodeBigInt(key)
+	case tag >= tagIntZero-intSize && tag <= tagIntZero+intSize:
+		return decodeInt(key)
+	default:
+		return nil, "", errors.Errorf("invalid type tag 0x%02x", tag)
+	}
+}
+
+func decodeString(key string) (string, string, error) {
+	var s strings.Builder
+	for {
+		i := strings.IndexByte(key, terminator)
+		if i < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		s.WriteString(key[:i])
+		if !strings.HasPrefix(key[i:], escaped) {
+			return s.String(), key[i+1:], nil
+		}
+		s.WriteString(escape)
+		key = key[i+len(escaped):]
+	}
+}
+
+func decodeInt(key string) (interface{}, string, error) {
+	neg := key[0] < tagIntZero
+	n := int(key[0]) - tagIntZero
+	if neg {
+		n = -n
+	}
+	if len(key) < 1+n {
+		return nil, "", errors.New("truncated integer")
+	}
+	mag := []byte(key[1 : 1+n])
+	if neg {
+		invert(mag)
+	}
+	if n > 0 && mag[0] == 0 {
+		return nil, "", errors.New("non-canonical integer")
+	}
+
+	var buf [intSize]byte
+	copy(buf[intSize-n:], mag)
+	x := binary.BigEndian.Uint64(buf[:])
+	switch {
+	case !neg && x <= math.MaxInt64:
+		return int64(x), key[1+n:], nil
+	case !neg:
+		return x, key[1+n:], nil
+	case x <= 1<<63: // nolint: gomnd
+		return -int64(x-1) - 1, key[1+n:], nil
+	default:
+		return new(big.Int).Neg(new(big.Int).SetUint64(x)), key[1+n:], nil
+	}
+}
+
+func decodeBigInt(key string) (interface{}, string, error) {
+	neg := key[0] == tagNegBigInt
+	if len(key) < 2 {
+		return nil, "", errors.New("truncated integer")
+	}
+	n := int(key[1])
+	if neg {
+		n = int(^key[1])
+	}
+	if n <= intSize {
+		return nil, "", errors.New("non-canonical integer")
+	}
+	if len(key) < 2+n {
+		return nil, "", errors.New("truncated integer")
+	}
+	mag := []byte(key[2 : 2+n])
+	if neg {
+		invert(mag)
+	}
+	if mag[0] == 0 {
+		return nil, "", errors.New("non-canonical integer")
+	}
+
+	x := new(big.Int).SetBytes(mag)
+	if neg {
+		x.Neg(x)
+	}
+	return x, key[2+n:], nil
+}
+
+func invert(data []byte) {
+	for i := range data {
+		data[i] = ^data[i]
+	}
+}
@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package key_test
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv/key"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+func TestTuple_Encode(t *testing.T) {
+	for _, tc := range []struct {
+		tuple   key.Tuple
+		encoded string
+	}{
+		{key.Tuple{}, ""},
+		{key.Tuple{"a\x00b"}, "\x02a\x00\xffb\x00"},
+		{key.Tuple{[]byte{}}, "\x01\x00"},
+		{key.Tuple{0}, "\x14"},
+		{key.Tuple{uint8(5)}, "\x15\x05"},
+		{key.Tuple{-1}, "\x13\xfe"},
+		{key.Tuple{int64(-256)}, "\x12\xfe\xff"},
+		{key.Tuple{uint64(math.MaxUint64)}, "\x1c" + strings.Repeat("\xff", 8)},
+		{key.Tuple{int64(math.MinInt64)}, "\x0c\x7f" + strings.Repeat("\xff", 7)},
+		{key.Tuple{new(big.Int).Lsh(big.NewInt(1), 64)}, "\x1d\x09\x01" + strings.Repeat("\x00", 8)},
+		{key.Tuple{new(big.Int).Lsh(big.NewInt(-1), 64)}, "\x0b\xf6\xfe" + strings.Repeat("\xff", 8)},
+		{key.Tuple{"channel", 7, "version", uint32(1)}, "\x02channel\x00\x15\x07\x02version\x00\x15\x01"},
+	} {
+		encoded, err := tc.tuple.Encode()
+		require.NoError(t, err)
+		assert.Equal(t, tc.encoded, encoded, "%v", tc.tuple)
+	}
+
+	for _, tuple := range []key.Tuple{
+		{1.5},
+		{"a", nil},
+		{new(big.Int).Lsh(big.NewInt(1), 8*256)},
+	} {
+		_, err := tuple.Encode()
+		assert.Error(t, err, "%v", tuple)
+	}
+}
+
+func TestTuple_EncodeIntegers(t *testing.T) {
+	// All integer types encode a value the same.
+	expected := mustEncode(t, key.Tuple{big.NewInt(42)})
+	for _, x := range []interface{}{
+		int(42), int8(42), int16(42), int32(42), int64(42),
+		uint(42), uint8(42), uint16(42), uint32(42), uint64(42),
+	} {
+		assert.Equal(t, expected, mustEncode(t, key.Tuple{x}), "%T", x)
+	}
+	assert.Equal(t, mustEncode(t, key.Tuple{0}), mustEncode(t, key.Tuple{(*big.Int)(nil)}))
+	assert.Equal(t, mustEncode(t, key.Tuple{int64(math.MinInt64)}),
+		mustEncode(t, key.Tuple{big.NewInt(math.MinInt64)}))
+}
+
+func TestDecode(t *testing.T) {
+	for _, tuple := range []key.Tuple{
+		{},
+		{"", []byte{}, "\x00", []byte("\x00\xff\x00"), "a\x00\x01b"},
+		{int64(0), int64(1), int64(-1), int64(math.MaxInt64), int64(math.MinInt64)},
+		{uint64(math.MaxInt64) + 1, uint64(math.MaxUint64)},
+		{new(big.Int).Neg(new(big.Int).SetUint64(math.MaxUint64))},
+		{new(big.Int).Lsh(big.NewInt(1), 64), new(big.Int).Lsh(big.NewInt(-3), 2000)},
+	} {
+		decoded, err := key.Decode(mustEncode(t, tuple))
+		require.NoError(t, err)
+		assert.Equal(t, tuple, decoded)
+	}
+
+	for _, data := range []string{
+		"\x03",                 // Invalid tag.
+		"\x02abc",              // Unterminated string.
+		"\x16\x01",             // Truncated integer.
+		"\x15\x00",             // Leading zero.
+		"\x13\xff",             // Leading zero of a negative integer.
+		"\x1d\x08\x01\x02\x03", // Big integer that fits 8 bytes.
+		"\x1d\x09\x01",         // Truncated big integer.
+		"\x1d",                 // Missing length.
+		"\x1d\x09\x00" + strings.Repeat("\x01", 8),
+	} {
+		_, err := key.Decode(data)
+		assert.Error(t, err, "%q", data)
+	}
+}
+
+func TestTuple_Range(t *testing.T) {
+	start, end, err := key.Tuple{"channel", 1}.Range()
+	require.NoError(t, err)
+
+	inRange := func(tuple key.Tuple) bool {
+		k := mustEncode(t, tuple)
+		return k >= start && k < end
+	}
+	assert.True(t, inRange(key.Tuple{"channel", 1}))
+	assert.True(t, inRange(key.Tuple{"channel", 1, "version", 5}))
+	assert.True(t, inRange(key.Tuple{"channel", 1, []byte{0xff}}))
+	assert.False(t, inRange(key.Tuple{"channel", 2}))
+	assert.False(t, inRange(key.Tuple{"channel", 256, "version"}))
+	assert.False(t, inRange(key.Tuple{"channel"}))
+	assert.False(t, inRange(key.Tuple{"channel\x00", 1}))
+	assert.False(t, inRange(key.Tuple{"channels", 1}))
+
+	// The range of a tuple that ends in a string or byte slice does not
+	// contain tuples whose last element continues with a 0x00 byte.
+	for _, last := range []interface{}{"a", []byte("a")} {
+		start, end, err = key.Tuple{"channel", last}.Range()
+		require.NoError(t, err)
+		assert.True(t, inRange(key.Tuple{"channel", last}))
+		assert.True(t, inRange(key.Tuple{"channel", last, "a\x00"}))
+		assert.True(t, inRange(key.Tuple{"channel", last, []byte{0xff}}))
+		assert.False(t, inRange(key.Tuple{"channel", "a\x00"}))
+		assert.False(t, inRange(key.Tuple{"channel", []byte("a\x00")}))
+		assert.False(t, inRange(key.Tuple{"channel", "a\x00", 1}))
+		assert.False(t, inRange(key.Tuple{"channel", "a\x00\xff"}))
+	}
+
+	start, end, err = key.Tuple{}.Range()
+	require.NoError(t, err)
+	assert.Equal(t, "", start)
+	assert.Equal(t, "", end)
+
+	_, _, err = key.Tuple{true}.Range()
+	assert.Error(t, err)
+}
+
+// TestTuple_Order tests that the order of encoded random tuples is the order
+// of the tuples.
+func TestTuple_Order(t *testing.T) {
+	rng := pkgtest.Prng(t)
+	tuples := make([]key.Tuple, 2000)
+	for i := range tuples {
+		tuples[i] = randomTuple(rng)
+	}
+	// Prefixes of tuples and shared elements make comparisons interesting.
+	for i := 0; i < len(tuples)/4; i++ {
+		base := tuples[rng.Intn(len(tuples))]
+		prefix := append(key.Tuple{}, base[:rng.Intn(len(base)+1)]...)
+		tuples = append(tuples, append(prefix, randomElement(rng)))
+	}
+
+	encoded := make([]string, len(tuples))
+	for i, tuple := range tuples {
+		encoded[i] = mustEncode(t, tuple)
+		decoded, err := key.Decode(encoded[i])
+		require.NoError(t, err)
+		require.Zero(t, compareTuples(tuple, decoded), "%v decoded as %v", tuple, decoded)
+	}
+
+	sort.Slice(tuples, func(i, j int) bool { return compareTuples(tuples[i], tuples[j]) < 0 })
+	sort.Strings(encoded)
+	for i, tuple := range tuples {
+		require.Equal(t, mustEncode(t, tuple), encoded[i], "tuple %d: %v", i, tuple)
+	}
+}
+
+// TestTuple_RangeProperty tests that the range of a random tuple contains
+// exactly the tuples that start with its elements.
+func TestTuple_RangeProperty(t *testing.T) {
+	rng := pkgtest.Prng(t)
+	for i := 0; i < 2000; i++ {
+		a, b := randomTuple(rng), randomTuple(rng)
+		if rng.Intn(2) == 0 {
+			// Tuples that share elements make the property interesting.
+			b = append(append(key.Tuple{}, a[:rng.Intn(len(a)+1)]...), b...)
+		}
+		start, end, err := a.Range()
+		require.NoError(t, err)
+		k := mustEncode(t, b)
+		inRange := k >= start && (end == "" || k < end)
+		require.Equal(t, hasElements(b, a), inRange, "range of %v, tuple %v", a, b)
+	}
+}
+
+// hasElements returns whether the tuple starts with the elements of prefix.
+func hasElements(tuple, prefix key.Tuple) bool {
+	return len(tuple) >= len(prefix) && compareTuples(tuple[:len(prefix)], prefix) == 0
+}
+
+func mustEncode(t *testing.T, tuple key.Tuple) string {
+	t.Helper()
+	encoded, err := tuple.Encode()
+	require.NoError(t, err)
+	return encoded
+}
+
+func randomTuple(rng *rand.Rand) key.Tuple {
+	tuple := make(key.Tuple, rng.Intn(5))
+	for i := range tuple {
+		tuple[i] = randomElement(rng)
+	}
+	return tuple
+}
+
+func randomElement(rng *rand.Rand) interface{} {
+	// Few different bytes produce many shared prefixes and escapes.
+	randomBytes := func() []byte {
+		b := make([]byte, rng.Intn(4))
+		for i := range b {
+			b[i] = []byte{0x00, 0x01, 0xff, 'a'}[rng.Intn(4)]
+		}
+		return b
+	}
+	bits := uint(rng.Intn(100))
+	switch rng.Intn(6) {
+	case 0:
+		return string(randomBytes())
+	case 1:
+		return randomBytes()
+	case 2:
+		return int64(rng.Uint64()) >> (bits % 64)
+	case 3:
+		return rng.Uint64() >> (bits % 64)
+	case 4:
+		return int8(rng.Intn(256) - 128)
+	default:
+		x := new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), bits*8))
+		if rng.Intn(2) == 0 {
+			x.Neg(x)
+		}
+		return x
+	}
+}
+
+// compareTuples compares tuples element by element. Elements are ordered by
+// kind first: byte slices, then strings, then integers.
+func compareTuples(a, b key.Tuple) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareElements(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func compareElements(a, b interface{}) int {
+	ka, kb := kind(a), kind(b)
+	if ka != kb {
+		return ka - kb
+	}
+	switch a := a.(type) {
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case string:
+		return strings.Compare(a, b.(string))
+	default:
+		return toBig(a).Cmp(toBig(b))
+	}
+}
+
+func kind(e interface{}) int {
+	switch e.(type) {
+	case []byte:
+		return 0
+	case string:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func toBig(e interface{}) *big.Int {
+	switch e := e.(type) {
+	case int8:
+		return big.NewInt(int64(e))
+	case int64:
+		return big.NewInt(e)
+	case uint64:
+		return new(big.Int).SetUint64(e)
+	case *big.Int:
+		return e
+	default:
+		panic("unexpected type")
+	}
+}
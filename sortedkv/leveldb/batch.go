@@ -3,14 +3,27 @@
 package leveldb
 
 import (
-	"github.com/pkg/errors"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"polycry.pt/poly-go/sortedkv/key"
 )
 
 // Batch represents a batch and implements the batch interface.
 type Batch struct {
 	*leveldb.Batch
-	db *leveldb.DB
+	db *Database
+
+	// rangeDeletes are the range deletions of the batch. They are resolved
+	// into single deletions when the batch is applied.
+	rangeDeletes []rangeDelete
+}
+
+// rangeDelete is a range deletion that is to be applied after the first pos
+// records of a batch.
+type rangeDelete struct {
+	pos        int
+	start, end string
 }
 
 // Put puts a new value in the batch.
@@ -30,13 +43,117 @@ func (b *Batch) Delete(key string) error {
 	return nil
 }
 
-// Apply applies the batch to the database.
+// DeleteRange deletes all keys in the range [start, end) from the batch. The
+// keys in the range are determined when the batch is applied.
+func (b *Batch) DeleteRange(start string, end string) error {
+	b.rangeDeletes = append(b.rangeDeletes, rangeDelete{pos: b.Batch.Len(), start: start, end: end})
+	return nil
+}
+
+// DeletePrefix deletes all keys with the given prefix from the batch.
+func (b *Batch) DeletePrefix(prefix string) error {
+	return b.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Apply applies the batch to the database. It is synced to disk if the
+// database was opened WithSync.
 func (b *Batch) Apply() error {
-	err := b.db.Write(b.Batch, nil)
-	return errors.Wrap(err, "leveldb batch apply error")
+	return b.apply(b.db.writeOptions)
+}
+
+// ApplySync applies the batch to the database and only returns after it was
+// synced to disk, even if the database was not opened WithSync.
+func (b *Batch) ApplySync() error {
+	return b.apply(&opt.WriteOptions{Sync: true})
+}
+
+func (b *Batch) apply(wo *opt.WriteOptions) error {
+	if err := b.db.checkWritable("Apply"); err != nil {
+		return err
+	}
+	if len(b.rangeDeletes) == 0 {
+		b.db.mu.RLock()
+		defer b.db.mu.RUnlock()
+
+		err := b.db.DB.Write(b.Batch, wo)
+		return wrapError(err, "", "leveldb batch apply error")
+	}
+
+	// The range deletions must see the same database state as the write.
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+
+	resolved := &rangeResolver{db: b.db, ranges: b.rangeDeletes, batch: new(leveldb.Batch)}
+	if err := b.Batch.Replay(resolved); err != nil {
+		return wrapError(err, "", "leveldb batch apply error")
+	}
+	if resolved.resolveUntil(-1); resolved.err != nil {
+		return resolved.err
+	}
+	err := b.db.DB.Write(resolved.batch, wo)
+	return wrapError(err, "", "leveldb batch apply error")
 }
 
 // Reset resets the batch.
 func (b *Batch) Reset() {
 	b.Batch.Reset()
+	b.rangeDeletes = nil
+}
+
+// rangeResolver replays a batch into a new batch and replaces the range
+// deletions by deletions of the single keys in the range. The keys in a range
+// are the keys in the database and the keys that were put by the batch before
+// the range deletion.
+type rangeResolver struct {
+	db     *Database
+	ranges []rangeDelete
+	batch  *leveldb.Batch
+	puts   map[string]struct{}
+	pos    int // Number of replayed records.
+	err    error
+}
+
+// Put replays a put record.
+func (r *rangeResolver) Put(key, value []byte) {
+	r.resolveUntil(r.pos)
+	r.pos++
+	if r.puts == nil {
+		r.puts = make(map[string]struct{})
+	}
+	r.puts[string(key)] = struct{}{}
+	r.batch.Put(key, value)
+}
+
+// Delete replays a delete record.
+func (r *rangeResolver) Delete(key []byte) {
+	r.resolveUntil(r.pos)
+	r.pos++
+	r.batch.Delete(key)
+}
+
+// resolveUntil resolves all range deletions that are to be applied after at
+// most pos records. If pos is negative, all remaining range deletions are
+// resolved. The first error that occurs is stored in r.err.
+func (r *rangeResolver) resolveUntil(pos int) {
+	for r.err == nil && len(r.ranges) != 0 && (pos < 0 || r.ranges[0].pos <= pos) {
+		r.err = r.resolve(r.ranges[0])
+		r.ranges = r.ranges[1:]
+	}
+}
+
+// resolve adds the deletions of the keys in the given range to the batch.
+func (r *rangeResolver) resolve(rd rangeDelete) error {
+	for k := range r.puts {
+		if k >= rd.start && (rd.end == "" || k < rd.end) {
+			r.batch.Delete([]byte(k))
+			delete(r.puts, k)
+		}
+	}
+
+	it := r.db.DB.NewIterator(keyRange(rd.start, rd.end), nil)
+	defer it.Release()
+	for it.Next() {
+		r.batch.Delete(it.Key())
+	}
+	return wrapError(it.Error(), "", "leveldb batch apply error")
 }
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package leveldb
+
+import (
+	"strconv"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// properties are the goleveldb properties that Stats returns. The
+// "leveldb.num-files-at-level<n>" properties are returned as well.
+var properties = []string{
+	"leveldb.stats",
+	"leveldb.compcount",
+	"leveldb.iostats",
+	"leveldb.writedelay",
+	"leveldb.sstables",
+	"leveldb.blockpool",
+	"leveldb.cachedblock",
+	"leveldb.openedtables",
+	"leveldb.alivesnaps",
+	"leveldb.aliveiters",
+}
+
+// numLevels is the number of levels whose file counts Stats returns. It is
+// goleveldb's default.
+const numLevels = 7
+
+// CompactRange compacts the tables of the keys in the range [start, end). It
+// fails with a *sortedkv.ReadOnlyError if the database is read-only.
+func (d *Database) CompactRange(start string, end string) error {
+	if err := d.checkWritable("CompactRange"); err != nil {
+		return err
+	}
+	err := d.DB.CompactRange(*keyRange(start, end))
+	return wrapError(err, "", "Database.CompactRange(start, end) error")
+}
+
+// ApproximateSize returns the approximate size of the tables of the keys in
+// the range [start, end). Writes that are still in the write buffer are not
+// included.
+func (d *Database) ApproximateSize(start string, end string) (int64, error) {
+	r := keyRange(start, end)
+	if r.Limit == nil {
+		// goleveldb treats a nil limit as the smallest key, so the limit is set
+		// to the successor of the last key instead.
+		it := d.DB.NewIterator(nil, nil)
+		if it.Last() {
+			r.Limit = append(append([]byte(nil), it.Key()...), 0)
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return 0, wrapError(err, "", "Database.ApproximateSize(start, end) error")
+		}
+		if r.Limit == nil {
+			return 0, nil
+		}
+	}
+	sizes, err := d.DB.SizeOf([]util.Range{*r})
+	if err != nil {
+		return 0, wrapError(err, "", "Database.ApproximateSize(start, end) error")
+	}
+	return sizes.Sum(), nil
+}
+
+// Stats returns goleveldb's properties, such as "leveldb.stats" and
+// "leveldb.num-files-at-level0".
+func (d *Database) Stats() (map[string]string, error) {
+	stats := make(map[string]string, len(properties)+numLevels)
+	for _, p := range properties {
+		value, err := d.DB.GetProperty(p)
+		if err != nil {
+			return nil, wrapError(err, "", "Database.Stats() error")
+		}
+		stats[p] = value
+	}
+	for level := 0; level < numLevels; level++ {
+		p := "leveldb.num-files-at-level" + strconv.Itoa(level)
+		value, err := d.DB.GetProperty(p)
+		if err != nil {
+			return nil, wrapError(err, "", "Database.Stats() error")
+		}
+		stats[p] = value
+	}
+	return stats, nil
+}
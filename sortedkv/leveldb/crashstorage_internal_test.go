@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package leveldb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+const (
+	crashStorageCurrent    = "CURRENT"
+	crashStorageCurrentTmp = "CURRENT.tmp"
+)
+
+// crashStorage implements storage.Storage on top of a test.CrashFS, so that
+// goleveldb can be tested with test.CrashConsistencyTest. It mirrors the file
+// layout and the directory syncs of goleveldb's file storage.
+type crashStorage struct {
+	fs *test.CrashFS
+
+	mu     sync.Mutex
+	locked bool
+}
+
+type crashStorageLock struct{ s *crashStorage }
+
+// crashStorageFile is a file of a crashStorage. Like in goleveldb's file
+// storage, syncing a manifest also syncs the directory.
+type crashStorageFile struct {
+	*test.CrashFile
+	fs       *test.CrashFS
+	manifest bool
+}
+
+func (f crashStorageFile) Sync() error {
+	if err := f.CrashFile.Sync(); err != nil {
+		return err
+	}
+	if f.manifest {
+		return f.fs.SyncDir(".")
+	}
+	return nil
+}
+
+func (l crashStorageLock) Unlock() {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	l.s.locked = false
+}
+
+func (s *crashStorage) Lock() (storage.Locker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locked {
+		return nil, storage.ErrLocked
+	}
+	s.locked = true
+	return crashStorageLock{s}, nil
+}
+
+func (s *crashStorage) Log(string) {}
+
+// SetMeta atomically replaces the CURRENT file, like goleveldb's file storage.
+func (s *crashStorage) SetMeta(fd storage.FileDesc) error {
+	f, err := s.fs.OpenFile(crashStorageCurrentTmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, fd.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := s.fs.Rename(crashStorageCurrentTmp, crashStorageCurrent); err != nil {
+		return err
+	}
+	return s.fs.SyncDir(".")
+}
+
+func (s *crashStorage) GetMeta() (storage.FileDesc, error) {
+	f, err := s.fs.OpenFile(crashStorageCurrent, os.O_RDONLY, 0)
+	if err != nil {
+		return storage.FileDesc{}, err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return storage.FileDesc{}, err
+	}
+
+	fd, ok := parseFileName(strings.TrimSuffix(string(content), "\n"))
+	if !ok || !strings.HasSuffix(string(content), "\n") {
+		return storage.FileDesc{}, &storage.ErrCorrupted{Err: errors.New("invalid CURRENT file")}
+	}
+	manifest, err := s.fs.OpenFile(fd.String(), os.O_RDONLY, 0)
+	if err != nil {
+		return storage.FileDesc{}, err
+	}
+	return fd, manifest.Close()
+}
+
+func (s *crashStorage) List(ft storage.FileType) ([]storage.FileDesc, error) {
+	names, err := s.fs.Names()
+	if err != nil {
+		return nil, err
+	}
+	var fds []storage.FileDesc
+	for _, name := range names {
+		if fd, ok := parseFileName(name); ok && fd.Type&ft != 0 {
+			fds = append(fds, fd)
+		}
+	}
+	return fds, nil
+}
+
+func (s *crashStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	f, err := s.fs.OpenFile(fd.String(), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *crashStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	f, err := s.fs.OpenFile(fd.String(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return crashStorageFile{CrashFile: f, fs: s.fs, manifest: fd.Type == storage.TypeManifest}, nil
+}
+
+func (s *crashStorage) Remove(fd storage.FileDesc) error {
+	return s.fs.Remove(fd.String())
+}
+
+func (s *crashStorage) Rename(oldfd, newfd storage.FileDesc) error {
+	return s.fs.Rename(oldfd.String(), newfd.String())
+}
+
+func (s *crashStorage) Close() error { return nil }
+
+// parseFileName parses the names generated by storage.FileDesc.String.
+func parseFileName(name string) (fd storage.FileDesc, ok bool) {
+	var tail string
+	if _, err := fmt.Sscanf(name, "%d.%s", &fd.Num, &tail); err == nil {
+		switch tail {
+		case "log":
+			fd.Type = storage.TypeJournal
+		case "ldb":
+			fd.Type = storage.TypeTable
+		case "tmp":
+			fd.Type = storage.TypeTemp
+		default:
+			return fd, false
+		}
+		return fd, true
+	}
+	if n, _ := fmt.Sscanf(name, "MANIFEST-%d%s", &fd.Num, &tail); n == 1 {
+		fd.Type = storage.TypeManifest
+		return fd, true
+	}
+	return fd, false
+}
@@ -7,28 +7,59 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
 
 	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
 )
 
 // Database implements the Database interface and stores the values in memory.
 type Database struct {
 	*leveldb.DB
 	path string
+
+	readOnly bool
+	// writeOptions are passed to all writes. They are nil unless WithSync was
+	// given.
+	writeOptions *opt.WriteOptions
+
+	// mu is held exclusively while a transaction commits and shared by all
+	// other writes, so that commits are atomic with respect to them.
+	mu sync.RWMutex
 }
 
-// LoadDatabase creates a new, empty Database.
-func LoadDatabase(path string) (*Database, error) {
-	db, err := leveldb.OpenFile(path, nil)
+// LoadDatabase opens the database at path. If it does not exist, a new, empty
+// database is created, unless WithReadOnly is given.
+func LoadDatabase(path string, opts ...Option) (*Database, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	db, err := leveldb.OpenFile(path, o.levelDBOptions())
 	if err != nil {
-		return nil, errors.Wrap(err, "Database.LoadDatabase(path) could not open/create file")
+		return nil, wrapError(err, "", "Database.LoadDatabase(path) could not open/create file")
 	}
 
-	return &Database{
-		db,
-		path,
-	}, nil
+	d := &Database{
+		DB:       db,
+		path:     path,
+		readOnly: o.readOnly,
+	}
+	if o.sync {
+		d.writeOptions = &opt.WriteOptions{Sync: true}
+	}
+	return d, nil
+}
+
+// checkWritable returns a *sortedkv.ReadOnlyError for op if the database is
+// read-only.
+func (d *Database) checkWritable(op string) error {
+	if d.readOnly {
+		return &sortedkv.ReadOnlyError{Op: op}
+	}
+	return nil
 }
 
 // interface Reader
@@ -36,19 +67,25 @@ func LoadDatabase(path string) (*Database, error) {
 // Has returns true iff the memorydb contains a key.
 func (d *Database) Has(key string) (bool, error) {
 	has, err := d.DB.Has([]byte(key), nil)
-	return has, errors.Wrap(err, "Database.Has(key) error")
+	return has, wrapError(err, key, "Database.Has(key) error")
 }
 
 // Get returns the value as string for given key if it is present in the store.
 func (d *Database) Get(key string) (string, error) {
 	val, err := d.GetBytes(key)
-	return string(val), err
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
 }
 
 // GetBytes returns the value as []byte for given key if it is present in the store.
 func (d *Database) GetBytes(key string) ([]byte, error) {
 	val, err := d.DB.Get([]byte(key), nil)
-	return val, errors.Wrap(err, "Database.Get(key) error")
+	if err != nil {
+		return nil, wrapError(err, key, "Database.Get(key) error")
+	}
+	return val, nil
 }
 
 // interface Writer
@@ -62,31 +99,60 @@ func (d *Database) Put(key string, value string) error {
 // PutBytes inserts the given value into the key-value store.
 // If the key is already present, it is overwritten and no error is returned.
 func (d *Database) PutBytes(key string, value []byte) error {
-	err := d.DB.Put([]byte(key), value, nil)
-	return errors.Wrap(err, "Database.Put(key, value) error")
+	if err := d.checkWritable("Put"); err != nil {
+		return err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	err := d.DB.Put([]byte(key), value, d.writeOptions)
+	return wrapError(err, key, "Database.Put(key, value) error")
 }
 
 // Delete removes the key from the key-value store.
 // If the key is not present, an error is returned.
 func (d *Database) Delete(key string) error {
+	if err := d.checkWritable("Delete"); err != nil {
+		return err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	has, err := d.DB.Has([]byte(key), nil)
 	if err != nil {
-		return errors.Wrap(err, "Database.Delete(key) error")
+		return wrapError(err, key, "Database.Delete(key) error")
 	}
 
 	if !has {
-		return errors.New("Database.Delete(key) error")
+		return errors.Wrap(&sortedkv.NotFoundError{Key: key}, "Database.Delete(key) error")
+	}
+
+	err = d.DB.Delete([]byte(key), d.writeOptions)
+	return wrapError(err, key, "Database.Delete(key) error")
+}
+
+// DeleteRange atomically deletes all keys in the range [start, end).
+func (d *Database) DeleteRange(start string, end string) error {
+	if err := d.checkWritable("DeleteRange"); err != nil {
+		return err
+	}
+	batch := d.NewBatch().(*Batch)
+	if err := batch.DeleteRange(start, end); err != nil {
+		return err
 	}
+	return batch.Apply()
+}
 
-	err = d.DB.Delete([]byte(key), nil)
-	return errors.Wrap(err, "Database.Delete(key) error")
+// DeletePrefix atomically deletes all keys with the given prefix.
+func (d *Database) DeletePrefix(prefix string) error {
+	return d.DeleteRange(prefix, key.IncPrefix(prefix))
 }
 
 // Batcher interface.
 
 // NewBatch creates a new batch.
 func (d *Database) NewBatch() sortedkv.Batch {
-	return &Batch{&leveldb.Batch{}, d.DB}
+	return &Batch{Batch: &leveldb.Batch{}, db: d}
 }
 
 // Iterateable interface.
@@ -98,6 +164,16 @@ func (d *Database) NewIterator() sortedkv.Iterator {
 
 // NewIteratorWithRange creates a new iterator based on a given range.
 func (d *Database) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return &Iterator{d.DB.NewIterator(keyRange(start, end), nil), sync.Mutex{}}
+}
+
+// NewIteratorWithPrefix creates a new iterator for a given prefix.
+func (d *Database) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return &Iterator{d.DB.NewIterator(prefixRange(prefix), nil), sync.Mutex{}}
+}
+
+// keyRange returns the leveldb range [start, end). Empty bounds are unlimited.
+func keyRange(start string, end string) *util.Range {
 	var Start []byte
 	var End []byte
 
@@ -109,16 +185,16 @@ func (d *Database) NewIteratorWithRange(start string, end string) sortedkv.Itera
 		End = []byte(end)
 	}
 
-	return &Iterator{d.DB.NewIterator(&util.Range{Start: Start, Limit: End}, nil), sync.Mutex{}}
+	return &util.Range{Start: Start, Limit: End}
 }
 
-// NewIteratorWithPrefix creates a new iterator for a given prefix.
-func (d *Database) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+// prefixRange returns the leveldb range of all keys with the given prefix.
+func prefixRange(prefix string) *util.Range {
 	var slice *util.Range
 
 	if len(prefix) != 0 {
 		slice = util.BytesPrefix([]byte(prefix))
 	}
 
-	return &Iterator{d.DB.NewIterator(slice, nil), sync.Mutex{}}
+	return slice
 }
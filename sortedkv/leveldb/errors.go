@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package leveldb
+
+import (
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// wrapError converts a goleveldb error into the corresponding sortedkv error
+// and wraps it with msg. key is the key of the failed operation, if any.
+func wrapError(err error, key string, msg string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, leveldb.ErrNotFound):
+		err = &sortedkv.NotFoundError{Key: key}
+	case errors.Is(err, leveldb.ErrClosed):
+		err = &sortedkv.ClosedError{}
+	case errors.Is(err, leveldb.ErrSnapshotReleased):
+		err = &sortedkv.ClosedError{Resource: "snapshot"}
+	case errors.Is(err, leveldb.ErrIterReleased):
+		err = &sortedkv.ClosedError{Resource: "iterator"}
+	case errors.Is(err, leveldb.ErrReadOnly):
+		err = &sortedkv.ReadOnlyError{}
+	case lerrors.IsCorrupted(err):
+		err = &sortedkv.CorruptedError{Err: err}
+	}
+	return errors.Wrap(err, msg)
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 )
 
-// Iterator provides an iterator over a key range.
+// Iterator provides an iterator over a key range. It implements
+// sortedkv.SeekableIterator.
 type Iterator struct {
 	iterator.Iterator
 	mu sync.Mutex
@@ -27,6 +28,55 @@ func (i *Iterator) Next() bool {
 	return i.Iterator.Next()
 }
 
+// Prev moves the iterator to the previous element.
+func (i *Iterator) Prev() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.Iterator == nil {
+		return false
+	}
+
+	return i.Iterator.Prev()
+}
+
+// First moves the iterator to the first element.
+func (i *Iterator) First() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.Iterator == nil {
+		return false
+	}
+
+	return i.Iterator.First()
+}
+
+// Last moves the iterator to the last element.
+func (i *Iterator) Last() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.Iterator == nil {
+		return false
+	}
+
+	return i.Iterator.Last()
+}
+
+// Seek moves the iterator to the first element whose key is greater than or
+// equal to key.
+func (i *Iterator) Seek(key string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.Iterator == nil {
+		return false
+	}
+
+	return i.Iterator.Seek([]byte(key))
+}
+
 // Key returns the key of the current element.
 func (i *Iterator) Key() string {
 	i.mu.Lock()
@@ -69,5 +119,5 @@ func (i *Iterator) Close() error {
 	err := i.Iterator.Error()
 	i.Iterator.Release()
 	i.Iterator = nil
-	return err
+	return wrapError(err, "", "Iterator.Close() error")
 }
@@ -5,10 +5,17 @@ package leveldb
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"polycry.pt/poly-go/sortedkv"
 	"polycry.pt/poly-go/sortedkv/test"
 )
@@ -22,16 +29,214 @@ func TestBatch(t *testing.T) {
 	})
 }
 
+func TestTableBatch(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericTableBatchTest(t, db)
+	})
+}
+
 func TestDatabase(t *testing.T) {
 	runTestOnTempDatabase(t, func(db *Database) {
 		test.GenericDatabaseTest(t, db)
 	})
 }
 
+func TestDatabase_Close(t *testing.T) {
+	path, err := ioutil.TempDir("", "poly_testdb_")
+	require.Nil(t, err, "Could not create temporary directory for database")
+	defer func() { require.Nil(t, os.RemoveAll(path)) }()
+
+	db, err := LoadDatabase(path)
+	require.Nil(t, err, "Could not load database")
+	test.GenericClosedDatabaseTest(t, db)
+}
+
+func TestLoadDatabase_Corrupted(t *testing.T) {
+	path, err := ioutil.TempDir("", "poly_testdb_")
+	require.Nil(t, err, "Could not create temporary directory for database")
+	defer func() { require.Nil(t, os.RemoveAll(path)) }()
+
+	db, err := LoadDatabase(path)
+	require.Nil(t, err, "Could not load database")
+	require.Nil(t, db.Put("key", "value"))
+	require.Nil(t, db.Close())
+
+	manifests, err := filepath.Glob(filepath.Join(path, "MANIFEST-*"))
+	require.Nil(t, err)
+	require.NotEmpty(t, manifests)
+	for _, manifest := range manifests {
+		require.Nil(t, ioutil.WriteFile(manifest, []byte("corrupted manifest"), 0o600))
+	}
+
+	_, err = LoadDatabase(path)
+	assert.True(t, sortedkv.IsCorrupted(err), "expected CorruptedError, got %v", err)
+}
+
+func TestLoadDatabase_ReadOnly(t *testing.T) {
+	path := t.TempDir()
+	_, err := LoadDatabase(filepath.Join(path, "missing"), WithReadOnly())
+	assert.Error(t, err, "read-only databases must not be created")
+
+	db, err := LoadDatabase(path)
+	require.Nil(t, err, "Could not load database")
+	require.Nil(t, db.Put("key", "value"))
+	require.Nil(t, db.Close())
+
+	db, err = LoadDatabase(path, WithReadOnly())
+	require.Nil(t, err, "Could not load database read-only")
+	defer db.Close()
+	dbtest := test.DatabaseTest{T: t, Database: db}
+	dbtest.MustGetEqual("key", "value")
+
+	mustBeReadOnly := func(op string, err error) {
+		t.Helper()
+		var roErr *sortedkv.ReadOnlyError
+		require.True(t, errors.As(err, &roErr), "%s: expected ReadOnlyError, got %v", op, err)
+		assert.Equal(t, op, roErr.Op)
+	}
+	mustBeReadOnly("Put", db.Put("key", "value2"))
+	mustBeReadOnly("Put", db.PutBytes("key", []byte("value2")))
+	mustBeReadOnly("Delete", db.Delete("key"))
+	mustBeReadOnly("Delete", db.Delete("missing"))
+	mustBeReadOnly("DeleteRange", db.DeleteRange("", ""))
+	mustBeReadOnly("DeleteRange", db.DeletePrefix("k"))
+	mustBeReadOnly("CompactRange", db.CompactRange("", ""))
+	batch := db.NewBatch()
+	require.Nil(t, batch.Put("key", "value2"))
+	mustBeReadOnly("Apply", batch.Apply())
+	mustBeReadOnly("Apply", batch.(*Batch).ApplySync())
+	tx, err := db.NewTransaction()
+	require.Nil(t, err)
+	require.Nil(t, tx.Put("key", "value2"))
+	mustBeReadOnly("Commit", tx.Commit())
+	dbtest.MustGetEqual("key", "value")
+}
+
+func TestLoadDatabase_Sync(t *testing.T) {
+	load := func() *Database {
+		db, err := LoadDatabase(t.TempDir(), WithSync(), WithCacheSize(1<<20))
+		require.Nil(t, err, "Could not load database")
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	test.GenericDatabaseTest(t, load())
+	test.GenericBatchTest(t, load())
+	test.GenericTransactionTest(t, load())
+
+	db := load()
+	batch := db.NewBatch().(*Batch)
+	require.Nil(t, batch.Put("synced", "value"))
+	require.Nil(t, batch.DeletePrefix("k"))
+	require.Nil(t, batch.ApplySync())
+	(&test.DatabaseTest{T: t, Database: db}).MustGetEqual("synced", "value")
+}
+
+func TestLoadDatabase_Compression(t *testing.T) {
+	// dirSize returns the size of the tables after writing compressible data.
+	dirSize := func(opts ...Option) int64 {
+		path := t.TempDir()
+		db, err := LoadDatabase(path, opts...)
+		require.Nil(t, err, "Could not load database")
+		value := strings.Repeat("compressible ", 1<<10)
+		for i := 0; i < 64; i++ {
+			require.Nil(t, db.Put(strconv.Itoa(i), value))
+		}
+		require.Nil(t, db.DB.CompactRange(util.Range{}))
+		require.Nil(t, db.Close())
+
+		tables, err := filepath.Glob(filepath.Join(path, "*.ldb"))
+		require.Nil(t, err)
+		var size int64
+		for _, table := range tables {
+			info, err := os.Stat(table)
+			require.Nil(t, err)
+			size += info.Size()
+		}
+		return size
+	}
+
+	compressed, uncompressed := dirSize(), dirSize(WithoutCompression())
+	assert.Less(t, 10*compressed, uncompressed)
+}
+
 func TestIterator(t *testing.T) {
 	runTestOnTempDatabase(t, func(db *Database) {
 		test.GenericIteratorTest(t, db)
 	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericSeekableIteratorTest(t, db)
+	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericSeekableIteratorTest(t, sortedkv.NewTable(db, "table"))
+	})
+}
+
+func TestList(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericListTest(t, db)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericSnapshotTest(t, db)
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericTransactionTest(t, db)
+	})
+}
+
+func TestCompact(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericCompactTest(t, db)
+	})
+}
+
+func TestRangeDelete(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericRangeDeleteTest(t, db)
+	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		dbtest := test.DatabaseTest{T: t, Database: db}
+		dbtest.Put("tabl", "outside")
+		dbtest.Put("tablf", "outside")
+		test.GenericRangeDeleteTest(t, sortedkv.NewTable(db, "table"))
+		dbtest.MustGetEqual("tabl", "outside")
+		dbtest.MustGetEqual("tablf", "outside")
+	})
+}
+
+func TestWatch(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericWatchTest(t, sortedkv.NewWatchable(db))
+	})
+}
+
+func TestMergeIterator(t *testing.T) {
+	test.GenericMergeIteratorTest(t, newTempDatabase(t))
+}
+
+func TestRestrict(t *testing.T) {
+	test.GenericRestrictTest(t, newTempDatabase(t))
+}
+
+func TestReadOnly(t *testing.T) {
+	test.GenericReadOnlyTest(t, newTempDatabase(t))
+}
+
+func TestCrashConsistency(t *testing.T) {
+	test.CrashConsistencyTest(t, func(fs *test.CrashFS) (sortedkv.Database, error) {
+		// A small write buffer makes the workload trigger compactions. Writes
+		// are synced, since the crash drops unsynced data.
+		db, err := leveldb.Open(&crashStorage{fs: fs}, &opt.Options{WriteBuffer: 16 << 10})
+		if err != nil {
+			return nil, err
+		}
+		return &Database{DB: db, writeOptions: &opt.WriteOptions{Sync: true}}, nil
+	})
 }
 
 func runTestOnTempDatabase(t *testing.T, tester func(*Database)) {
@@ -48,3 +253,20 @@ func runTestOnTempDatabase(t *testing.T, tester func(*Database)) {
 
 	tester(db)
 }
+
+// newTempDatabase returns a function that creates a new database in a
+// temporary directory on every call. The databases are closed when the test
+// finishes, unless the test closed them already.
+func newTempDatabase(t *testing.T) func() sortedkv.Database {
+	t.Helper()
+	return func() sortedkv.Database {
+		db, err := LoadDatabase(t.TempDir())
+		require.Nil(t, err, "Could not load database")
+		t.Cleanup(func() {
+			if err := db.DB.Close(); !errors.Is(err, leveldb.ErrClosed) {
+				assert.Nil(t, err, "Could not close database")
+			}
+		})
+		return db
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package leveldb
+
+import "github.com/syndtr/goleveldb/leveldb/opt"
+
+type (
+	// Option configures how a Database is opened. Options are passed to
+	// LoadDatabase.
+	Option func(*options)
+
+	options struct {
+		readOnly      bool
+		sync          bool
+		cacheSize     int
+		noCompression bool
+	}
+)
+
+// WithReadOnly opens the database read-only, for example for inspection. All
+// writes fail with a *sortedkv.ReadOnlyError. The database must exist.
+func WithReadOnly() Option {
+	return func(o *options) { o.readOnly = true }
+}
+
+// WithSync makes all writes durable: they only return after the written data
+// was synced to disk. Without it, the most recent writes may be lost if the
+// machine crashes, but not if only the process crashes. Single batches can be
+// synced with Batch.ApplySync instead.
+func WithSync() Option {
+	return func(o *options) { o.sync = true }
+}
+
+// WithCacheSize sets the size in bytes of the cache for uncompressed blocks.
+// The default is 8 MiB.
+func WithCacheSize(size int) Option {
+	return func(o *options) { o.cacheSize = size }
+}
+
+// WithoutCompression disables the snappy compression of blocks.
+func WithoutCompression() Option {
+	return func(o *options) { o.noCompression = true }
+}
+
+// levelDBOptions returns the goleveldb options.
+func (o *options) levelDBOptions() *opt.Options {
+	opts := &opt.Options{
+		ReadOnly:           o.readOnly,
+		ErrorIfMissing:     o.readOnly,
+		BlockCacheCapacity: o.cacheSize,
+	}
+	if o.noCompression {
+		opts.Compression = opt.NoCompression
+	}
+	return opts
+}
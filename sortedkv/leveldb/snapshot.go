@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package leveldb
+
+import (
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// Snapshot is a read-only, point-in-time view of a Database.
+type Snapshot struct {
+	*leveldb.Snapshot
+}
+
+// NewSnapshot creates a snapshot of the current state of the database.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	s, err := d.DB.GetSnapshot()
+	if err != nil {
+		return nil, wrapError(err, "", "Database.NewSnapshot() error")
+	}
+	return &Snapshot{s}, nil
+}
+
+// Has returns true iff the snapshot contains a key.
+func (s *Snapshot) Has(key string) (bool, error) {
+	has, err := s.Snapshot.Has([]byte(key), nil)
+	return has, wrapError(err, key, "Snapshot.Has(key) error")
+}
+
+// Get returns the value as string for given key if it is present in the snapshot.
+func (s *Snapshot) Get(key string) (string, error) {
+	val, err := s.GetBytes(key)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+// GetBytes returns the value as []byte for given key if it is present in the snapshot.
+func (s *Snapshot) GetBytes(key string) ([]byte, error) {
+	val, err := s.Snapshot.Get([]byte(key), nil)
+	if err != nil {
+		return nil, wrapError(err, key, "Snapshot.Get(key) error")
+	}
+	return val, nil
+}
+
+// NewIterator creates a new iterator.
+func (s *Snapshot) NewIterator() sortedkv.Iterator {
+	return &Iterator{s.Snapshot.NewIterator(nil, nil), sync.Mutex{}}
+}
+
+// NewIteratorWithRange creates a new iterator based on a given range.
+func (s *Snapshot) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return &Iterator{s.Snapshot.NewIterator(keyRange(start, end), nil), sync.Mutex{}}
+}
+
+// NewIteratorWithPrefix creates a new iterator for a given prefix.
+func (s *Snapshot) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return &Iterator{s.Snapshot.NewIterator(prefixRange(prefix), nil), sync.Mutex{}}
+}
+
+// Close releases the snapshot.
+func (s *Snapshot) Close() error {
+	s.Snapshot.Release()
+	return nil
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package leveldb
+
+import (
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/internal/txn"
+)
+
+// NewTransaction creates a new optimistic transaction on the database.
+func (d *Database) NewTransaction() (sortedkv.Transaction, error) {
+	return txn.New(d.lookup, d, d.commit), nil
+}
+
+// lookup returns the value of a key and whether it is present.
+func (d *Database) lookup(key string) (string, bool, error) {
+	val, err := d.DB.Get([]byte(key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, wrapError(err, key, "Database.Get(key) error")
+	}
+	return string(val), true, nil
+}
+
+// commit atomically validates and applies a transaction.
+func (d *Database) commit(tx *txn.Transaction) error {
+	if err := d.checkWritable("Commit"); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := tx.Validate(d.lookup); err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	for _, w := range tx.Writes() {
+		if w.Delete {
+			batch.Delete([]byte(w.Key))
+		} else {
+			batch.Put([]byte(w.Key), []byte(w.Value))
+		}
+	}
+	return wrapError(d.DB.Write(batch, d.writeOptions), "", "leveldb transaction commit error")
+}
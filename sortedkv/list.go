@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+type (
+	// KeyValue is a key/value pair.
+	KeyValue struct {
+		Key   string
+		Value string
+	}
+
+	// Page is a page of key/value pairs that List returns.
+	Page struct {
+		// Entries are the key/value pairs of the page in ascending key order.
+		Entries []KeyValue
+		// Next is the cursor of the page after this page, or "" if this page
+		// is the last page.
+		Next string
+		// Prev is the cursor of the page before this page, or "" if this page
+		// is the first page.
+		Prev string
+	}
+)
+
+// Kinds of cursors. A cursor consists of its kind, followed by a key relative
+// to the listed prefix.
+const (
+	cursorFrom   = 'f' // Page of the keys from the cursor's key onwards.
+	cursorBefore = 'b' // Page of the keys before the cursor's key.
+	cursorLast   = 'l' // Last page. It has no key.
+)
+
+// FirstPage is the cursor of the first page.
+const FirstPage = ""
+
+// LastPage is the cursor of the last page.
+var LastPage = encodeCursor(cursorLast, "")
+
+// List returns a page of up to limit key/value pairs with the given prefix.
+// The cursor selects the page. It is FirstPage, LastPage or the Next or Prev
+// cursor of a page that was returned by List for the same prefix. Cursors are
+// opaque and URL-safe.
+//
+// Cursors point to keys, not to positions, so pages stay consistent under
+// concurrent writes: A page never contains keys of its previous or next
+// pages, even if keys were inserted or deleted in between. Pages may be
+// shorter than limit, or empty, if keys were deleted concurrently.
+//
+// If the iterators of the iterable are SeekableIterators, List seeks to the
+// page instead of scanning the keys before it.
+func List(iterable Iterable, prefix string, cursor string, limit int) (*Page, error) {
+	if limit <= 0 {
+		return nil, errors.Errorf("non-positive limit %d", limit)
+	}
+	kind, from, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	it := iterable.NewIteratorWithPrefix(prefix)
+	defer it.Close()
+
+	// One more entry than the limit tells whether there are more pages.
+	var entries []KeyValue
+	if kind == cursorFrom {
+		entries = listForward(it, prefix+from, limit+1)
+	} else {
+		entries = listBackward(it, prefix+from, kind == cursorLast, limit+1)
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+	return newPage(kind, from, prefix, entries, limit), nil
+}
+
+// newPage creates the page of the entries that were read for a cursor. If
+// there are more entries than limit, the page has more keys in the direction
+// of the cursor.
+func newPage(kind byte, from, prefix string, entries []KeyValue, limit int) *Page {
+	more := len(entries) > limit
+	page := &Page{Entries: entries}
+	if kind == cursorFrom {
+		if more {
+			page.Entries = entries[:limit]
+			page.Next = encodeCursor(cursorFrom, key.Next(entries[limit-1].Key[len(prefix):]))
+		}
+		if from != "" {
+			page.Prev = encodeCursor(cursorBefore, from)
+		}
+		return page
+	}
+
+	if more {
+		page.Entries = entries[len(entries)-limit:]
+		page.Prev = encodeCursor(cursorBefore, page.Entries[0].Key[len(prefix):])
+	}
+	if kind == cursorBefore {
+		page.Next = encodeCursor(cursorFrom, from)
+	}
+	return page
+}
+
+// listForward returns up to n entries of the iterator, starting at the key
+// from.
+func listForward(it Iterator, from string, n int) []KeyValue {
+	var entries []KeyValue
+	if sit, ok := it.(SeekableIterator); ok {
+		for valid := sit.Seek(from); valid && len(entries) < n; valid = sit.Next() {
+			entries = append(entries, KeyValue{Key: sit.Key(), Value: sit.Value()})
+		}
+		return entries
+	}
+
+	for len(entries) < n && it.Next() {
+		if it.Key() >= from {
+			entries = append(entries, KeyValue{Key: it.Key(), Value: it.Value()})
+		}
+	}
+	return entries
+}
+
+// listBackward returns the last up to n entries of the iterator before the
+// key before in ascending order. If toEnd is true, before is ignored and the
+// last entries of the iterator are returned.
+func listBackward(it Iterator, before string, toEnd bool, n int) []KeyValue {
+	if sit, ok := it.(SeekableIterator); ok {
+		var valid bool
+		if toEnd || !sit.Seek(before) {
+			valid = sit.Last()
+		} else {
+			valid = sit.Prev()
+		}
+
+		var entries []KeyValue
+		for ; valid && len(entries) < n; valid = sit.Prev() {
+			entries = append(entries, KeyValue{Key: sit.Key(), Value: sit.Value()})
+		}
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		return entries
+	}
+
+	// Without seeking, the last n entries are kept while scanning.
+	var entries []KeyValue
+	for it.Next() && (toEnd || it.Key() < before) {
+		if len(entries) == n {
+			entries = entries[1:]
+		}
+		entries = append(entries, KeyValue{Key: it.Key(), Value: it.Value()})
+	}
+	return entries
+}
+
+func encodeCursor(kind byte, rel string) string {
+	return base64.RawURLEncoding.EncodeToString(append([]byte{kind}, rel...))
+}
+
+func decodeCursor(cursor string) (kind byte, rel string, err error) {
+	if cursor == FirstPage {
+		return cursorFrom, "", nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) == 0 {
+		return 0, "", errors.New("invalid cursor")
+	}
+	switch kind, rel = data[0], string(data[1:]); {
+	case kind == cursorFrom || kind == cursorBefore:
+	case kind == cursorLast && rel == "":
+	default:
+		return 0, "", errors.New("invalid cursor")
+	}
+	return kind, rel, nil
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+// Batch represents a batch and implements the batch interface. A batch is
+// written to the log as a single record.
+type Batch struct {
+	db  *Database
+	ops []batchOp
+}
+
+type (
+	// batchOp is a buffered operation of a batch.
+	batchOp struct {
+		kind  batchOpKind
+		key   string
+		value string
+		end   string // End of the range of a range deletion.
+	}
+
+	batchOpKind int
+)
+
+const (
+	batchPut batchOpKind = iota
+	batchDelete
+	batchDeleteRange
+)
+
+// Put puts a new value in the batch.
+func (b *Batch) Put(key string, value string) error {
+	b.ops = append(b.ops, batchOp{kind: batchPut, key: key, value: value})
+	return nil
+}
+
+// PutBytes puts a new byte slice into the batch.
+func (b *Batch) PutBytes(key string, value []byte) error {
+	return b.Put(key, string(value))
+}
+
+// Delete deletes a value from the batch.
+func (b *Batch) Delete(key string) error {
+	b.ops = append(b.ops, batchOp{kind: batchDelete, key: key})
+	return nil
+}
+
+// DeleteRange deletes all keys in the range [start, end) from the batch. The
+// keys in the range are determined when the batch is applied.
+func (b *Batch) DeleteRange(start string, end string) error {
+	b.ops = append(b.ops, batchOp{kind: batchDeleteRange, key: start, end: end})
+	return nil
+}
+
+// DeletePrefix deletes all keys with the given prefix from the batch.
+func (b *Batch) DeletePrefix(prefix string) error {
+	return b.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Apply applies the batch to the database atomically.
+func (b *Batch) Apply() error {
+	return b.db.applyBatch(b.ops)
+}
+
+// Reset resets the batch.
+func (b *Batch) Reset() {
+	b.ops = nil
+}
+
+// resolve converts batch operations into log operations by replacing range
+// deletions with deletions of the keys in the range. The keys in a range are
+// the keys in the index and the keys that were put by the batch before the
+// range deletion. d.mu must be held.
+func (d *Database) resolve(bops []batchOp) ([]op, error) {
+	ops := make([]op, 0, len(bops))
+	puts := make(map[string]struct{})
+	for _, bo := range bops {
+		switch bo.kind {
+		case batchPut:
+			ops = append(ops, op{kind: opPut, key: bo.key, value: bo.value})
+			puts[bo.key] = struct{}{}
+		case batchDelete:
+			ops = append(ops, op{kind: opDelete, key: bo.key})
+		case batchDeleteRange:
+			for k := range puts {
+				if k >= bo.key && (bo.end == "" || k < bo.end) {
+					ops = append(ops, op{kind: opDelete, key: k})
+					delete(puts, k)
+				}
+			}
+			it := d.mem.NewIteratorWithRange(bo.key, bo.end)
+			for it.Next() {
+				ops = append(ops, op{kind: opDelete, key: it.Key()})
+			}
+			if err := it.Close(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ops, nil
+}
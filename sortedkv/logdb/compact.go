@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// compactRecordSize is the approximate maximal size of the records that are
+// written by compaction.
+const compactRecordSize = 1 << 20
+
+// shouldCompact returns whether the log should be compacted automatically.
+// d.mu must be held.
+func (d *Database) shouldCompact() bool {
+	return d.opts.compactionRatio >= 1 &&
+		d.size >= d.opts.compactionMinSize &&
+		float64(d.size) >= d.opts.compactionRatio*float64(d.liveSize)
+}
+
+// compact writes the live data into a new log and replaces the current log
+// with it. The directory of the log is synced after the replacement, so that
+// the replacement is durable. If it fails before the new log replaced the
+// current log, the current log stays intact. d.mu must be held.
+func (d *Database) compact() error {
+	tmp := d.path + compactSuffix
+	size, err := d.writeCompacted(tmp)
+	if err != nil {
+		_ = d.opts.fs.Remove(tmp)
+		return err
+	}
+	if err := d.opts.fs.Rename(tmp, d.path); err != nil {
+		_ = d.opts.fs.Remove(tmp)
+		return errors.WithMessage(err, "replacing log")
+	}
+
+	// The current file handle refers to the replaced log.
+	_ = d.file.Close()
+	file, err := d.opts.fs.OpenFile(d.path, os.O_RDWR, 0)
+	if err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		d.err = errors.WithMessage(err, "logdb: reopening log after compaction")
+		return d.err
+	}
+	d.file = file
+	d.size = size
+	return errors.WithMessage(d.opts.fs.SyncDir(filepath.Dir(d.path)), "syncing log directory")
+}
+
+// writeCompacted writes the live data into a new log at path and returns its
+// size.
+func (d *Database) writeCompacted(path string) (size int64, err error) {
+	file, err := d.opts.fs.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600) // nolint: gomnd
+	if err != nil {
+		return 0, errors.WithMessage(err, "creating compaction file")
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil && cerr != nil {
+			err = errors.WithMessage(cerr, "closing compaction file")
+		}
+	}()
+
+	write := func(data []byte) error {
+		n, err := file.Write(data)
+		size += int64(n)
+		return errors.WithMessage(err, "writing compaction file")
+	}
+	if err := write([]byte(logMagic)); err != nil {
+		return 0, err
+	}
+
+	it := d.mem.NewIterator()
+	var ops []op
+	var opsSize int64
+	for it.Next() {
+		ops = append(ops, op{kind: opPut, key: it.Key(), value: it.Value()})
+		if opsSize += entrySize(it.Key(), it.Value()); opsSize >= compactRecordSize {
+			if err := write(encodeRecord(ops)); err != nil {
+				it.Close()
+				return 0, err
+			}
+			ops, opsSize = ops[:0], 0
+		}
+	}
+	if err := it.Close(); err != nil {
+		return 0, err
+	}
+	if len(ops) != 0 {
+		if err := write(encodeRecord(ops)); err != nil {
+			return 0, err
+		}
+	}
+	return size, errors.WithMessage(file.Sync(), "syncing compaction file")
+}
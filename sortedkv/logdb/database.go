@@ -0,0 +1,363 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+)
+
+// compactSuffix is appended to the path of the log to get the path of the
+// file that compaction writes to.
+const compactSuffix = ".compact"
+
+// Database implements the Database interface and stores the values in an
+// append-only log. Reads are served from an in-memory index. The database is
+// thread-safe.
+type Database struct {
+	mem  *memorydb.Database // In-memory index of the log.
+	opts options
+	path string
+
+	mu       sync.Mutex // Serializes writes and compaction.
+	file     File
+	size     int64 // Size of the log.
+	liveSize int64 // Estimated size of the log after compaction.
+	closed   bool
+	err      error // Set when the log could not be restored after a failure.
+}
+
+// Open opens the log at path or creates it if it does not exist.
+func Open(path string, opts ...Option) (*Database, error) {
+	d := &Database{
+		mem:      memorydb.NewDatabase().(*memorydb.Database),
+		opts:     defaultOptions(),
+		path:     path,
+		liveSize: int64(len(logMagic)),
+	}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+
+	// A leftover file of an interrupted compaction is incomplete.
+	if err := d.opts.fs.Remove(path + compactSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(err, "removing leftover compaction file")
+	}
+
+	file, err := d.opts.fs.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600) // nolint: gomnd
+	if err != nil {
+		return nil, errors.WithMessage(err, "opening log")
+	}
+	if err := d.load(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	d.file = file
+	return d, nil
+}
+
+// load replays the log in file into the index and prepares the file for
+// appending.
+func (d *Database) load(file File) error {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.WithMessage(err, "seeking log")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.WithMessage(err, "seeking log")
+	}
+
+	valid, err := replayLog(file, size, d.apply)
+	if err != nil {
+		return err
+	}
+
+	if valid < size {
+		// Discard the torn end of the log.
+		if err := file.Truncate(valid); err != nil {
+			return errors.WithMessage(err, "truncating log")
+		}
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		return errors.WithMessage(err, "seeking log")
+	}
+	if valid == 0 {
+		// A new log is synced with its directory, so that it is not lost
+		// together with the first synced writes.
+		if _, err := file.Write([]byte(logMagic)); err != nil {
+			return errors.WithMessage(err, "writing log header")
+		}
+		if err := file.Sync(); err != nil {
+			return errors.WithMessage(err, "syncing log")
+		}
+		if err := d.opts.fs.SyncDir(filepath.Dir(d.path)); err != nil {
+			return errors.WithMessage(err, "syncing log directory")
+		}
+		valid = int64(len(logMagic))
+	} else if valid != size && d.opts.sync {
+		if err := file.Sync(); err != nil {
+			return errors.WithMessage(err, "syncing log")
+		}
+	}
+	d.size = valid
+	return nil
+}
+
+// Reader interface.
+
+// Has returns true if the database contains a key.
+func (d *Database) Has(key string) (bool, error) {
+	return d.mem.Has(key)
+}
+
+// Get returns the value as string for given key if it is present in the store.
+func (d *Database) Get(key string) (string, error) {
+	return d.mem.Get(key)
+}
+
+// GetBytes returns the value as []byte for given key if it is present in the store.
+func (d *Database) GetBytes(key string) ([]byte, error) {
+	return d.mem.GetBytes(key)
+}
+
+// Writer interface.
+
+// Put inserts the given value into the key-value store.
+// If the key is already present, it is overwritten and no error is returned.
+func (d *Database) Put(key string, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	return d.write([]op{{kind: opPut, key: key, value: value}})
+}
+
+// PutBytes inserts the given value into the key-value store.
+// If the key is already present, it is overwritten and no error is returned.
+func (d *Database) PutBytes(key string, value []byte) error {
+	return d.Put(key, string(value))
+}
+
+// Delete removes the key from the key-value store.
+// If the key is not present, an error is returned.
+func (d *Database) Delete(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	if has, err := d.mem.Has(key); err != nil {
+		return err
+	} else if !has {
+		return &sortedkv.NotFoundError{Key: key}
+	}
+	return d.write([]op{{kind: opDelete, key: key}})
+}
+
+// DeleteRange atomically deletes all keys in the range [start, end).
+func (d *Database) DeleteRange(start string, end string) error {
+	return d.applyBatch([]batchOp{{kind: batchDeleteRange, key: start, end: end}})
+}
+
+// DeletePrefix atomically deletes all keys with the given prefix.
+func (d *Database) DeletePrefix(prefix string) error {
+	return d.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Batcher interface.
+
+// NewBatch creates a new batch.
+func (d *Database) NewBatch() sortedkv.Batch {
+	return &Batch{db: d}
+}
+
+// Iterable interface.
+
+// NewIterator creates a new iterator.
+func (d *Database) NewIterator() sortedkv.Iterator {
+	return d.mem.NewIterator()
+}
+
+// NewIteratorWithRange creates a new iterator based on a given range.
+func (d *Database) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return d.mem.NewIteratorWithRange(start, end)
+}
+
+// NewIteratorWithPrefix creates a new iterator for a given prefix.
+func (d *Database) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return d.mem.NewIteratorWithPrefix(prefix)
+}
+
+// NewSnapshot creates a snapshot of the current state of the database.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	return d.mem.NewSnapshot()
+}
+
+// Closer interface.
+
+// Close closes the log. All further operations fail with a
+// *sortedkv.ClosedError.
+func (d *Database) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	d.closed = true
+	err := d.file.Close()
+	if cerr := d.mem.Close(); err == nil {
+		err = cerr
+	}
+	return errors.WithMessage(err, "closing log")
+}
+
+// Compact rewrites the log so that it only contains the live data.
+func (d *Database) Compact() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	return d.compact()
+}
+
+// CompactRange compacts the whole log, since the log is not partitioned by
+// key.
+func (d *Database) CompactRange(start string, end string) error {
+	return d.Compact()
+}
+
+// ApproximateSize returns the total size of the keys and values in the range
+// [start, end). Deleted and overwritten entries that are still in the log are
+// not included.
+func (d *Database) ApproximateSize(start string, end string) (int64, error) {
+	return d.mem.ApproximateSize(start, end)
+}
+
+// Stats returns the size of the log as "logdb.size", its estimated size after
+// compaction as "logdb.livesize" and the statistics of the in-memory index.
+func (d *Database) Stats() (map[string]string, error) {
+	stats, err := d.mem.Stats()
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats["logdb.size"] = strconv.FormatInt(d.size, 10)
+	stats["logdb.livesize"] = strconv.FormatInt(d.liveSize, 10)
+	return stats, nil
+}
+
+// checkWritable returns an error if the database cannot be written to. d.mu
+// must be held.
+func (d *Database) checkWritable() error {
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	return d.err
+}
+
+// applyBatch resolves and writes the given batch operations atomically.
+func (d *Database) applyBatch(bops []batchOp) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	ops, err := d.resolve(bops)
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	return d.write(ops)
+}
+
+// write appends the operations to the log as one record and applies them to
+// the index. d.mu must be held.
+func (d *Database) write(ops []op) error {
+	rec := encodeRecord(ops)
+	_, err := d.file.Write(rec)
+	if err == nil && d.opts.sync {
+		err = d.file.Sync()
+	}
+	if err != nil {
+		d.rollback()
+		return errors.WithMessage(err, "writing log")
+	}
+	d.size += int64(len(rec))
+	d.apply(ops)
+
+	if d.shouldCompact() {
+		// A failed compaction leaves the log intact and is retried on the next
+		// write, so the error is not reported to the writer.
+		_ = d.compact()
+	}
+	return nil
+}
+
+// rollback removes a partially written record from the end of the log. If
+// that fails, the database is put into a failed state. d.mu must be held.
+func (d *Database) rollback() {
+	err := d.file.Truncate(d.size)
+	if err == nil {
+		_, err = d.file.Seek(d.size, io.SeekStart)
+	}
+	if err != nil {
+		d.err = errors.WithMessage(err, "logdb: restoring log after failed write")
+	}
+}
+
+// apply applies the operations to the index atomically and updates the live
+// data size.
+func (d *Database) apply(ops []op) {
+	pending := make(map[string]*string)
+	lookup := func(key string) (string, bool) {
+		if value, ok := pending[key]; ok {
+			if value == nil {
+				return "", false
+			}
+			return *value, true
+		}
+		value, err := d.mem.Get(key)
+		return value, err == nil
+	}
+
+	batch := d.mem.NewBatch()
+	for _, o := range ops {
+		if old, ok := lookup(o.key); ok {
+			d.liveSize -= entrySize(o.key, old)
+		}
+		switch o.kind {
+		case opPut:
+			value := o.value
+			pending[o.key] = &value
+			d.liveSize += entrySize(o.key, o.value)
+			_ = batch.Put(o.key, o.value)
+		case opDelete:
+			pending[o.key] = nil
+			_ = batch.Delete(o.key)
+		}
+	}
+	// The index is only closed together with the database.
+	_ = batch.Apply()
+}
+
+// entrySize returns the size of a put operation in the log.
+func entrySize(key, value string) int64 {
+	return int64(len(appendUvarint(nil, uint64(len(key))))+len(key)+
+		len(appendUvarint(nil, uint64(len(value))))+len(value)) + 1
+}
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logdb implements the key-value database interface as a single-file,
+// append-only log. It is written in pure Go and has no dependencies besides
+// the standard library.
+//
+// Every write is appended to the log as one CRC-checked record, so batches are
+// applied atomically. When a database is opened, the log is replayed into an
+// in-memory ordered index that holds all keys and values. A torn record at the
+// end of the log, as left behind by a crash during a write, is discarded. Any
+// other damage of the log is reported as a *sortedkv.CorruptedError.
+//
+// Overwritten and deleted entries are removed from the log by compaction,
+// which rewrites the log into a new file and atomically replaces the old one.
+// Compaction runs automatically when the log grows too large compared to the
+// live data, see WithCompactionThreshold, or manually via Compact.
+//
+// Since the whole data set is held in memory, the package is suited for small
+// to medium-sized databases.
+package logdb // import "polycry.pt/poly-go/sortedkv/logdb"
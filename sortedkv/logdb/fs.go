@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"io"
+	"os"
+)
+
+type (
+	// FS is the file system that a Database stores its log in.
+	FS interface {
+		// OpenFile opens the named file like os.OpenFile.
+		OpenFile(name string, flag int, perm os.FileMode) (File, error)
+		// Rename renames a file like os.Rename.
+		Rename(oldpath, newpath string) error
+		// Remove removes a file like os.Remove.
+		Remove(name string) error
+		// SyncDir commits the entries of the named directory, like created and
+		// renamed files, to stable storage.
+		SyncDir(name string) error
+	}
+
+	// File is a file of an FS.
+	File interface {
+		io.Reader
+		io.Writer
+		io.Seeker
+		io.Closer
+		// Sync commits the contents of the file to stable storage.
+		Sync() error
+		// Truncate changes the size of the file.
+		Truncate(size int64) error
+	}
+
+	// osFS is the FS of the operating system.
+	osFS struct{}
+)
+
+// OpenFile calls os.OpenFile.
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+// Rename calls os.Rename.
+func (osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove calls os.Remove.
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// SyncDir opens the directory and syncs it.
+func (osFS) SyncDir(name string) error {
+	dir, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	if err := dir.Sync(); err != nil {
+		dir.Close()
+		return err
+	}
+	return dir.Close()
+}
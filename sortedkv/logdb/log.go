@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// The log starts with logMagic, followed by a sequence of records. A record
+// consists of a header, which holds the CRC-32C checksum and the length of the
+// payload and the CRC-32C checksum of the length as little-endian uint32, and
+// the payload. The checksum of the length tells a torn record apart from a
+// corrupted length. The payload is the number
+// of operations as uvarint, followed by the operations. An operation is its
+// kind as a byte, the length of the key as uvarint, the key and, for puts, the
+// length of the value as uvarint and the value.
+const (
+	logMagic         = "PolyLog\x02"
+	recordHeaderSize = 12
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type (
+	// op is a single write operation.
+	op struct {
+		kind  opKind
+		key   string
+		value string
+	}
+
+	opKind byte
+)
+
+const (
+	opPut opKind = iota
+	opDelete
+)
+
+// encodeRecord encodes the operations as one record.
+func encodeRecord(ops []op) []byte {
+	size := recordHeaderSize + binary.MaxVarintLen64
+	for _, o := range ops {
+		size += 1 + 2*binary.MaxVarintLen64 + len(o.key) + len(o.value)
+	}
+
+	rec := make([]byte, recordHeaderSize, size)
+	rec = appendUvarint(rec, uint64(len(ops)))
+	for _, o := range ops {
+		rec = append(rec, byte(o.kind))
+		rec = appendUvarint(rec, uint64(len(o.key)))
+		rec = append(rec, o.key...)
+		if o.kind == opPut {
+			rec = appendUvarint(rec, uint64(len(o.value)))
+			rec = append(rec, o.value...)
+		}
+	}
+
+	payload := rec[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(rec[0:4], crc32.Checksum(payload, crcTable))
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(rec[8:12], crc32.Checksum(rec[4:8], crcTable))
+	return rec
+}
+
+// decodePayload decodes the operations of a record's payload.
+func decodePayload(payload []byte) ([]op, error) {
+	n, err := readUvarint(&payload)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(payload)) {
+		return nil, errors.New("invalid operation count")
+	}
+
+	ops := make([]op, n)
+	for i := range ops {
+		if len(payload) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		ops[i].kind, payload = opKind(payload[0]), payload[1:]
+		if ops[i].key, err = readString(&payload); err != nil {
+			return nil, err
+		}
+		switch ops[i].kind {
+		case opPut:
+			if ops[i].value, err = readString(&payload); err != nil {
+				return nil, err
+			}
+		case opDelete:
+		default:
+			return nil, errors.Errorf("invalid operation kind %d", ops[i].kind)
+		}
+	}
+	if len(payload) != 0 {
+		return nil, errors.New("trailing bytes")
+	}
+	return ops, nil
+}
+
+// replayLog reads the log of the given size from r and calls apply with the
+// operations of every record. It returns the size of the valid part of the
+// log. A torn record or header at the end of the log is not part of the valid
+// part, but is no error. A record is only torn if its header is incomplete,
+// or if its header is intact and its payload is incomplete or, as the last
+// record, fails its checksum. Any other invalid data results in a
+// *sortedkv.CorruptedError.
+func replayLog(r io.Reader, size int64, apply func([]op)) (valid int64, err error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(logMagic))
+	n, err := io.ReadFull(br, magic)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, errors.WithMessage(err, "reading log")
+	}
+	if n < len(logMagic) && strings.HasPrefix(logMagic, string(magic[:n])) {
+		return 0, nil // Torn header.
+	} else if string(magic) != logMagic {
+		return 0, corrupted(0, "invalid magic")
+	}
+
+	valid = int64(len(logMagic))
+	header := make([]byte, recordHeaderSize)
+	for valid < size {
+		if size-valid < recordHeaderSize {
+			return valid, nil // Torn header.
+		}
+		if _, err := io.ReadFull(br, header); err != nil {
+			return 0, errors.WithMessage(err, "reading log")
+		}
+		if crc32.Checksum(header[4:8], crcTable) != binary.LittleEndian.Uint32(header[8:12]) {
+			return 0, corrupted(valid, "header checksum mismatch")
+		}
+		checksum := binary.LittleEndian.Uint32(header[0:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:8]))
+		end := valid + recordHeaderSize + length
+		if end > size {
+			return valid, nil // Torn record. Its length is intact.
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return 0, errors.WithMessage(err, "reading log")
+		}
+		if crc32.Checksum(payload, crcTable) != checksum {
+			if end == size {
+				return valid, nil // Torn record.
+			}
+			return 0, corrupted(valid, "checksum mismatch")
+		}
+		ops, err := decodePayload(payload)
+		if err != nil {
+			return 0, corrupted(valid, err.Error())
+		}
+
+		apply(ops)
+		valid = end
+	}
+	return valid, nil
+}
+
+func corrupted(offset int64, reason string) error {
+	return &sortedkv.CorruptedError{Err: errors.Errorf("logdb: invalid record at offset %d: %s", offset, reason)}
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
+
+func readUvarint(buf *[]byte) (uint64, error) {
+	x, n := binary.Uvarint(*buf)
+	if n <= 0 {
+		return 0, errors.New("invalid uvarint")
+	}
+	*buf = (*buf)[n:]
+	return x, nil
+}
+
+func readString(buf *[]byte) (string, error) {
+	n, err := readUvarint(buf)
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(len(*buf)) {
+		return "", io.ErrUnexpectedEOF
+	}
+	s := string((*buf)[:n])
+	*buf = (*buf)[n:]
+	return s, nil
+}
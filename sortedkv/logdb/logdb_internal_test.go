@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestBatch(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericBatchTest(t, db)
+	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericBatchTest(t, sortedkv.NewTable(db, "table"))
+	})
+}
+
+func TestDatabase(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericDatabaseTest(t, db)
+	})
+}
+
+func TestDatabase_Close(t *testing.T) {
+	path := tempLogPath(t)
+	db, err := Open(path)
+	require.Nil(t, err, "Could not open database")
+	test.GenericClosedDatabaseTest(t, db)
+}
+
+func TestIterator(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericIteratorTest(t, db)
+	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericSeekableIteratorTest(t, db)
+	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericSeekableIteratorTest(t, sortedkv.NewTable(db, "table"))
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericSnapshotTest(t, db)
+	})
+}
+
+func TestTable(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericTableTest(t, db)
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericTransactionTest(t, db)
+	})
+}
+
+func TestCompact(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericCompactTest(t, db)
+	})
+}
+
+func TestRangeDelete(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericRangeDeleteTest(t, db)
+	})
+	runTestOnTempDatabase(t, func(db *Database) {
+		dbtest := test.DatabaseTest{T: t, Database: db}
+		dbtest.Put("tabl", "outside")
+		dbtest.Put("tablf", "outside")
+		test.GenericRangeDeleteTest(t, sortedkv.NewTable(db, "table"))
+		dbtest.MustGetEqual("tabl", "outside")
+		dbtest.MustGetEqual("tablf", "outside")
+	})
+}
+
+func TestWatch(t *testing.T) {
+	runTestOnTempDatabase(t, func(db *Database) {
+		test.GenericWatchTest(t, sortedkv.NewWatchable(db))
+	})
+}
+
+func TestOpen_Reopen(t *testing.T) {
+	path := tempLogPath(t)
+	db, err := Open(path)
+	require.Nil(t, err)
+	require.Nil(t, db.Put("a", "1"))
+	require.Nil(t, db.Put("b", "2"))
+	require.Nil(t, db.Put("c", "3"))
+	require.Nil(t, db.Delete("b"))
+	batch := db.NewBatch()
+	require.Nil(t, batch.Put("d", "4"))
+	require.Nil(t, batch.Put("a", "1'"))
+	require.Nil(t, batch.Apply())
+	require.Nil(t, db.DeleteRange("c", "d"))
+	require.Nil(t, db.Close())
+
+	db = mustOpen(t, path)
+	assert.Equal(t, map[string]string{"a": "1'", "d": "4"}, test.ReadAll(t, db))
+}
+
+func TestOpen_TornRecord(t *testing.T) {
+	path := tempLogPath(t)
+	db := mustOpen(t, path)
+	require.Nil(t, db.Put("a", "1"))
+	require.Nil(t, db.Close())
+
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	validSize := info.Size()
+
+	// Append every proper prefix of a record, as left behind by a crash
+	// during the write.
+	rec := encodeRecord([]op{{kind: opPut, key: "b", value: "2"}})
+	for n := 1; n < len(rec); n++ {
+		appendToFile(t, path, rec[:n])
+
+		db = mustOpen(t, path)
+		assert.Equal(t, map[string]string{"a": "1"}, test.ReadAll(t, db))
+		require.Nil(t, db.Close())
+
+		info, err := os.Stat(path)
+		require.Nil(t, err)
+		assert.Equal(t, validSize, info.Size(), "torn record must be truncated")
+	}
+
+	db = mustOpen(t, path)
+	require.Nil(t, db.Put("c", "3"))
+	require.Nil(t, db.Close())
+	db = mustOpen(t, path)
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, test.ReadAll(t, db))
+}
+
+func TestOpen_CorruptedLength(t *testing.T) {
+	path := tempLogPath(t)
+	db := mustOpen(t, path)
+	require.Nil(t, db.Put("a", "1"))
+	require.Nil(t, db.Put("b", "2"))
+	require.Nil(t, db.Put("c", "3"))
+	require.Nil(t, db.Close())
+
+	// Corrupt the high byte of the length of the second record, so that the
+	// record seems to extend beyond the end of the log.
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	recSize := len(encodeRecord([]op{{kind: opPut, key: "a", value: "1"}}))
+	data[len(logMagic)+recSize+7] = 0xff
+	require.Nil(t, ioutil.WriteFile(path, data, 0o600))
+
+	_, err = Open(path)
+	assert.True(t, sortedkv.IsCorrupted(err), "expected CorruptedError, got %v", err)
+	assert.Equal(t, int64(len(data)), fileSize(t, path), "corrupted log must not be truncated")
+}
+
+func TestOpen_Corrupted(t *testing.T) {
+	path := tempLogPath(t)
+	db := mustOpen(t, path)
+	require.Nil(t, db.Put("key", "value"))
+	require.Nil(t, db.Put("key2", "value2"))
+	require.Nil(t, db.Close())
+
+	// Flip a byte in the value of the first record.
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	offset := len(logMagic) + recordHeaderSize + 7
+	require.Equal(t, byte('v'), data[offset])
+	data[offset] ^= 0xff
+	require.Nil(t, ioutil.WriteFile(path, data, 0o600))
+
+	_, err = Open(path)
+	assert.True(t, sortedkv.IsCorrupted(err), "expected CorruptedError, got %v", err)
+
+	require.Nil(t, ioutil.WriteFile(path, []byte("not a log file"), 0o600))
+	_, err = Open(path)
+	assert.True(t, sortedkv.IsCorrupted(err), "expected CorruptedError, got %v", err)
+}
+
+func TestDatabase_Compact(t *testing.T) {
+	path := tempLogPath(t)
+	db := mustOpen(t, path, WithCompactionThreshold(0, 0))
+	expected := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i % 10)
+		value := strconv.Itoa(i)
+		require.Nil(t, db.Put(key, value))
+		expected[key] = value
+	}
+	require.Nil(t, db.Delete("0"))
+	delete(expected, "0")
+
+	before := fileSize(t, path)
+	require.Nil(t, db.Compact())
+	after := fileSize(t, path)
+	assert.Less(t, after, before/5, "compaction must shrink the log")
+	assert.Equal(t, expected, test.ReadAll(t, db))
+
+	// Writes after compaction go to the new log.
+	require.Nil(t, db.Put("new", "value"))
+	expected["new"] = "value"
+	require.Nil(t, db.Close())
+
+	db = mustOpen(t, path)
+	assert.Equal(t, expected, test.ReadAll(t, db))
+	_, err := os.Stat(path + compactSuffix)
+	assert.True(t, os.IsNotExist(err), "compaction file must be removed")
+}
+
+func TestDatabase_AutoCompact(t *testing.T) {
+	path := tempLogPath(t)
+	db := mustOpen(t, path, WithCompactionThreshold(256, 2))
+	for i := 0; i < 1000; i++ {
+		require.Nil(t, db.Put("key", strconv.Itoa(i)))
+		// The log is compacted as soon as it exceeds twice the live data
+		// size, or the minimal size.
+		require.Less(t, fileSize(t, path), int64(256+64))
+	}
+	require.Nil(t, db.Close())
+
+	db = mustOpen(t, path)
+	assert.Equal(t, map[string]string{"key": "999"}, test.ReadAll(t, db))
+}
+
+func TestOpen_LeftoverCompaction(t *testing.T) {
+	path := tempLogPath(t)
+	db := mustOpen(t, path)
+	require.Nil(t, db.Put("a", "1"))
+	require.Nil(t, db.Close())
+	require.Nil(t, ioutil.WriteFile(path+compactSuffix, []byte("incomplete"), 0o600))
+
+	db = mustOpen(t, path)
+	assert.Equal(t, map[string]string{"a": "1"}, test.ReadAll(t, db))
+	_, err := os.Stat(path + compactSuffix)
+	assert.True(t, os.IsNotExist(err), "leftover compaction file must be removed")
+}
+
+func runTestOnTempDatabase(t *testing.T, tester func(*Database)) {
+	t.Helper()
+	db := mustOpen(t, tempLogPath(t))
+	defer func() { assert.Nil(t, db.Close(), "Could not close database") }()
+
+	tester(db)
+}
+
+func tempLogPath(t *testing.T) string {
+	t.Helper()
+	// Create a temporary directory and delete it when done
+	dir, err := ioutil.TempDir("", "poly_testdb_")
+	require.Nil(t, err, "Could not create temporary directory for database")
+	t.Cleanup(func() { require.Nil(t, os.RemoveAll(dir)) })
+	return filepath.Join(dir, "log")
+}
+
+func mustOpen(t *testing.T, path string, opts ...Option) *Database {
+	t.Helper()
+	db, err := Open(path, opts...)
+	require.Nil(t, err, "Could not open database")
+	return db
+}
+
+func appendToFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.Nil(t, err)
+	_, err = f.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	return info.Size()
+}
+
+func TestCrashConsistency(t *testing.T) {
+	open := func(opts ...Option) test.CrashOpenFunc {
+		return func(fs *test.CrashFS) (sortedkv.Database, error) {
+			return Open("log", append(opts, WithFS(crashFS{fs}))...)
+		}
+	}
+	t.Run("no sync", func(t *testing.T) {
+		test.CrashAtomicityTest(t, open())
+	})
+	t.Run("no sync and compaction", func(t *testing.T) {
+		test.CrashAtomicityTest(t, open(WithCompactionThreshold(4096, 1.5)))
+	})
+	t.Run("sync and compaction", func(t *testing.T) {
+		test.CrashConsistencyTest(t, open(WithSync(true), WithCompactionThreshold(4096, 1.5)))
+	})
+}
+
+// crashFS adapts a test.CrashFS to the FS interface.
+type crashFS struct{ *test.CrashFS }
+
+func (fs crashFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.CrashFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
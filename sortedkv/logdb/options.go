@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+const (
+	// DefaultCompactionMinSize is the default minimal log size in bytes for
+	// automatic compaction.
+	DefaultCompactionMinSize = 1 << 20
+	// DefaultCompactionRatio is the default ratio of log size to live data
+	// size for automatic compaction.
+	DefaultCompactionRatio = 2.0
+)
+
+type (
+	// Option configures a Database. Options are passed to Open.
+	Option func(*options)
+
+	options struct {
+		fs                FS
+		sync              bool
+		compactionMinSize int64
+		compactionRatio   float64
+	}
+)
+
+func defaultOptions() options {
+	return options{
+		fs:                osFS{},
+		compactionMinSize: DefaultCompactionMinSize,
+		compactionRatio:   DefaultCompactionRatio,
+	}
+}
+
+// WithSync sets whether every write is synced to stable storage before it
+// returns. Without syncing, writes survive a crash of the process, but may be
+// lost on a crash of the operating system. Defaults to false.
+func WithSync(sync bool) Option {
+	return func(o *options) { o.sync = sync }
+}
+
+// WithCompactionThreshold sets when the log is compacted automatically: after
+// a write, if the log is at least minSize bytes large and at least ratio times
+// larger than the live data. A ratio smaller than 1 disables automatic
+// compaction. Defaults to DefaultCompactionMinSize and
+// DefaultCompactionRatio.
+func WithCompactionThreshold(minSize int64, ratio float64) Option {
+	return func(o *options) {
+		o.compactionMinSize = minSize
+		o.compactionRatio = ratio
+	}
+}
+
+// WithFS sets the file system that the log is stored in. Defaults to the file
+// system of the operating system.
+func WithFS(fs FS) Option {
+	return func(o *options) { o.fs = fs }
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package logdb
+
+import (
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/internal/txn"
+)
+
+// NewTransaction creates a new optimistic transaction on the database.
+func (d *Database) NewTransaction() (sortedkv.Transaction, error) {
+	return txn.New(d.lookup, d, d.commit), nil
+}
+
+// lookup returns the value of a key and whether it is present.
+func (d *Database) lookup(key string) (string, bool, error) {
+	value, err := d.mem.Get(key)
+	if sortedkv.IsNotFound(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// commit atomically validates a transaction and writes it to the log as one
+// record.
+func (d *Database) commit(tx *txn.Transaction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	// Holding d.mu, the index cannot change during validation.
+	if err := tx.Validate(d.lookup); err != nil {
+		return err
+	}
+
+	writes := tx.Writes()
+	if len(writes) == 0 {
+		return nil
+	}
+	ops := make([]op, len(writes))
+	for i, w := range writes {
+		if w.Delete {
+			ops[i] = op{kind: opDelete, key: w.Key}
+		} else {
+			ops[i] = op{kind: opPut, key: w.Key, value: w.Value}
+		}
+	}
+	return d.write(ops)
+}
@@ -3,20 +3,35 @@
 package memorydb
 
 import (
-	"github.com/pkg/errors"
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
 )
 
 // Batch represents a batch and implements the batch interface.
 type Batch struct {
-	db      *Database
-	writes  map[string]string
-	deletes map[string]struct{}
+	db  *Database
+	ops []batchOp
 }
 
+// batchOp is a buffered operation of a batch.
+type batchOp struct {
+	kind  batchOpKind
+	key   string
+	value string
+	end   string // End of the range of a range deletion.
+}
+
+type batchOpKind int
+
+const (
+	opPut batchOpKind = iota
+	opDelete
+	opDeleteRange
+)
+
 // Put puts a new value in the batch.
 func (b *Batch) Put(key string, value string) error {
-	delete(b.deletes, key)
-	b.writes[key] = value
+	b.ops = append(b.ops, batchOp{kind: opPut, key: key, value: value})
 	return nil
 }
 
@@ -27,24 +42,39 @@ func (b *Batch) PutBytes(key string, value []byte) error {
 
 // Delete deletes a value from the batch.
 func (b *Batch) Delete(key string) error {
-	delete(b.writes, key)
-	b.deletes[key] = struct{}{}
+	b.ops = append(b.ops, batchOp{kind: opDelete, key: key})
 	return nil
 }
 
-// Apply applies the batch to the database.
+// DeleteRange deletes all keys in the range [start, end) from the batch.
+func (b *Batch) DeleteRange(start string, end string) error {
+	b.ops = append(b.ops, batchOp{kind: opDeleteRange, key: start, end: end})
+	return nil
+}
+
+// DeletePrefix deletes all keys with the given prefix from the batch.
+func (b *Batch) DeletePrefix(prefix string) error {
+	return b.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Apply applies the batch to the database atomically. The operations are
+// applied in the order in which they were added to the batch. Deleting keys
+// that are not present in the database is not an error.
 func (b *Batch) Apply() error {
-	for key, value := range b.writes {
-		err := b.db.Put(key, value)
-		if err != nil {
-			return errors.Wrap(err, "failed to put entry")
-		}
-	}
+	b.db.mutex.Lock()
+	defer b.db.mutex.Unlock()
 
-	for key := range b.deletes {
-		err := b.db.Delete(key)
-		if err != nil {
-			return errors.Wrap(err, "failed to delete entry")
+	if b.db.closed {
+		return &sortedkv.ClosedError{}
+	}
+	for _, op := range b.ops {
+		switch op.kind {
+		case opPut:
+			b.db.put(op.key, op.value)
+		case opDelete:
+			b.db.delete(op.key)
+		case opDeleteRange:
+			b.db.deleteRange(op.key, op.end)
 		}
 	}
 	return nil
@@ -52,6 +82,5 @@ func (b *Batch) Apply() error {
 
 // Reset resets the batch.
 func (b *Batch) Reset() {
-	b.writes = make(map[string]string)
-	b.deletes = make(map[string]struct{})
+	b.ops = nil
 }
@@ -18,3 +18,17 @@ func TestBatch(t *testing.T) {
 		test.GenericBatchTest(t, sortedkv.NewTable(NewDatabase(), "table"))
 	})
 }
+
+// plainBatchDB hides that the batches of a database are RangeDeleters.
+type plainBatchDB struct {
+	sortedkv.Database
+}
+
+func (db plainBatchDB) NewBatch() sortedkv.Batch {
+	return struct{ sortedkv.Batch }{db.Database.NewBatch()}
+}
+
+func TestTableBatch(t *testing.T) {
+	test.GenericTableBatchTest(t, NewDatabase())
+	test.GenericTableBatchTest(t, plainBatchDB{NewDatabase()})
+}
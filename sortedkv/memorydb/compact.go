@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"strconv"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// CompactRange does nothing, since the tree never holds deleted entries. It
+// only fails if the database is closed.
+func (d *Database) CompactRange(start string, end string) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	return nil
+}
+
+// ApproximateSize returns the exact total size of the keys and values in the
+// range [start, end). It takes O(log n).
+func (d *Database) ApproximateSize(start string, end string) (int64, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return 0, &sortedkv.ClosedError{}
+	}
+	size := subtreeBytes(d.root) - bytesBefore(d.root, start)
+	if end != "" {
+		size -= subtreeBytes(d.root) - bytesBefore(d.root, end)
+	}
+	if size < 0 { // end < start
+		return 0, nil
+	}
+	return size, nil
+}
+
+// Stats returns the number of entries as "memorydb.keys" and their total size
+// as "memorydb.bytes".
+func (d *Database) Stats() (map[string]string, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return nil, &sortedkv.ClosedError{}
+	}
+	return map[string]string{
+		"memorydb.keys":  strconv.Itoa(d.size),
+		"memorydb.bytes": strconv.FormatInt(subtreeBytes(d.root), 10),
+	}, nil
+}
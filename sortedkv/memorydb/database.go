@@ -10,39 +10,62 @@
 // The NewDatabase() constructor creates a new empty database. The FromData()
 // constructor takes a key-value mapping and uses that as the database's
 // contents.
+//
+// Implementation
+//
+// The entries are stored in a persistent AVL tree, so that lookups, writes and
+// positioning an iterator take O(log n). Writes never modify the tree in
+// place, which lets iterators and snapshots lazily read the tree as of their
+// creation without copying it.
+//
+// Batches
+//
+// Batches are applied atomically. Unlike Database.Delete, a batch that deletes
+// a key that is not present does not fail. A batch cannot know whether a key
+// is present before it is applied, and failing in the middle of Apply would
+// leave a partially applied batch behind. This matches the batches of the
+// leveldb backend, which ignore such deletes as well.
 package memorydb // import "polycry.pt/poly-go/sortedkv/memorydb"
 
 import (
 	"sort"
-	"strings"
 	"sync"
 
 	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
 )
 
 // Database implements the Database interface and stores the values in memory.
 type Database struct {
-	mutex sync.RWMutex
-	data  map[string]string
+	mutex  sync.RWMutex
+	root   *node
+	size   int
+	closed bool
 }
 
 // NewDatabase creates a new, empty Database.
 func NewDatabase() sortedkv.Database {
-	return &Database{
-		data: make(map[string]string),
-	}
+	return &Database{}
 }
 
 // FromData creates a Database from a map of values.
-// The provided data will not be cloned. If data is nil, an empty database is
-// created.
+// The provided data is copied into the database. If data is nil, an empty
+// database is created.
 func FromData(data map[string]string) sortedkv.Database {
-	if data == nil {
-		data = make(map[string]string)
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = data[key]
 	}
 
 	return &Database{
-		data: data,
+		root: fromSorted(keys, values),
+		size: len(keys),
 	}
 }
 
@@ -53,7 +76,10 @@ func (d *Database) Has(key string) (bool, error) {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
-	_, exists := d.data[key]
+	if d.closed {
+		return false, &sortedkv.ClosedError{}
+	}
+	_, exists := get(d.root, key)
 	return exists, nil
 }
 
@@ -62,7 +88,10 @@ func (d *Database) Get(key string) (string, error) {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
-	value, exists := d.data[key]
+	if d.closed {
+		return "", &sortedkv.ClosedError{}
+	}
+	value, exists := get(d.root, key)
 	if !exists {
 		return "", &sortedkv.NotFoundError{Key: key}
 	}
@@ -72,7 +101,10 @@ func (d *Database) Get(key string) (string, error) {
 // GetBytes returns a value to a key in bytes.
 func (d *Database) GetBytes(key string) ([]byte, error) {
 	value, err := d.Get(key)
-	return []byte(value), err
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
 }
 
 // Writer interface.
@@ -82,7 +114,10 @@ func (d *Database) Put(key string, value string) error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	d.data[key] = value
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	d.put(key, value)
 	return nil
 }
 
@@ -96,13 +131,60 @@ func (d *Database) Delete(key string) error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	if _, has := d.data[key]; !has {
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	if !d.delete(key) {
 		return &sortedkv.NotFoundError{Key: key}
 	}
-	delete(d.data, key)
 	return nil
 }
 
+// DeleteRange deletes all keys in the range [start, end) from the database.
+func (d *Database) DeleteRange(start string, end string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	d.deleteRange(start, end)
+	return nil
+}
+
+// DeletePrefix deletes all keys with the given prefix from the database.
+func (d *Database) DeletePrefix(prefix string) error {
+	return d.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// put saves a value under a key. The database must be locked already.
+func (d *Database) put(key string, value string) {
+	var added bool
+	if d.root, added = insert(d.root, key, value); added {
+		d.size++
+	}
+}
+
+// delete deletes a key and returns whether it was present. The database must
+// be locked already.
+func (d *Database) delete(key string) bool {
+	var removed bool
+	if d.root, removed = remove(d.root, key); removed {
+		d.size--
+	}
+	return removed
+}
+
+// deleteRange deletes all keys in the range [start, end). The database must
+// be locked already.
+func (d *Database) deleteRange(start string, end string) {
+	// The iterator walks the old tree, so it is not affected by the removals.
+	it := newIterator(d.root, start, end)
+	for it.Next() {
+		d.delete(it.Key())
+	}
+}
+
 // Batcher interface.
 
 // NewBatch creates a new batch.
@@ -116,20 +198,7 @@ func (d *Database) NewBatch() sortedkv.Batch {
 
 // NewIterator creates a new iterator.
 func (d *Database) NewIterator() sortedkv.Iterator {
-	d.mutex.RLock()
-	defer d.mutex.RUnlock()
-
-	keys := make([]string, 0, len(d.data))
-	for key := range d.data {
-		keys = append(keys, key)
-	}
-
-	sort.Strings(keys)
-
-	return &Iterator{
-		keys:   keys,
-		values: d.readValues(keys),
-	}
+	return d.NewIteratorWithRange("", "")
 }
 
 // NewIteratorWithRange creates a new iterator based on a given range.
@@ -137,65 +206,26 @@ func (d *Database) NewIteratorWithRange(start string, end string) sortedkv.Itera
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
-	var keys []string
-	// No need to check for start == "", as all strings >= "".
-	if end == "" {
-		for key := range d.data {
-			if key >= start {
-				keys = append(keys, key)
-			}
-		}
-	} else {
-		for key := range d.data {
-			if key >= start && key < end {
-				keys = append(keys, key)
-			}
-		}
-	}
-
-	sort.Strings(keys)
-	return &Iterator{
-		keys:   keys,
-		values: d.readValues(keys),
+	if d.closed {
+		return &Iterator{err: &sortedkv.ClosedError{}}
 	}
+	return newIterator(d.root, start, end)
 }
 
 // NewIteratorWithPrefix creates a new iterator for a given prefix.
 func (d *Database) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
-	d.mutex.RLock()
-	defer d.mutex.RUnlock()
-
-	var keys []string
-	for key := range d.data {
-		if strings.HasPrefix(key, prefix) {
-			keys = append(keys, key)
-		}
-	}
-
-	sort.Strings(keys)
-	return &Iterator{
-		keys:   keys,
-		values: d.readValues(keys),
-	}
-}
-
-// readValues reads the values matched to a set of keys from a database.
-// The database must be readlocked already.
-func (d *Database) readValues(keys []string) []string {
-	data := make([]string, 0, len(keys))
-	for key := range keys {
-		data = append(data, d.data[keys[key]])
-	}
-
-	return data
+	return d.NewIteratorWithRange(prefix, key.IncPrefix(prefix))
 }
 
 // Closer interface
 
-// Close clears the database.
+// Close clears the database. All further operations fail with a
+// *sortedkv.ClosedError.
 func (d *Database) Close() error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
-	d.data = nil
+	d.root = nil
+	d.size = 0
+	d.closed = true
 	return nil
 }
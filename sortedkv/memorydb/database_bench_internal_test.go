@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The map* functions reproduce the previous implementation of the database,
+// which stored the entries in a map and collected, sorted and copied all
+// matching entries whenever an iterator was created. They serve as a baseline
+// for the benchmarks.
+
+func mapIteratorWithPrefix(data map[string]string, prefix string) ([]string, []string) {
+	var keys []string
+	for key := range data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, data[key])
+	}
+	return keys, values
+}
+
+var benchSizes = []int{1000, 100000}
+
+// benchData returns n entries, split over 100 prefixes.
+func benchData(n int) map[string]string {
+	data := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		data[fmt.Sprintf("%02d/%08d", i%100, i)] = fmt.Sprintf("value%d", i)
+	}
+	return data
+}
+
+func BenchmarkIteratorWithPrefix(b *testing.B) {
+	for _, n := range benchSizes {
+		data := benchData(n)
+		db := FromData(data)
+
+		b.Run(fmt.Sprintf("tree/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				it := db.NewIteratorWithPrefix("42/")
+				for j := 0; j < 10 && it.Next(); j++ {
+					_ = it.Value()
+				}
+				_ = it.Close()
+			}
+		})
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				keys, values := mapIteratorWithPrefix(data, "42/")
+				for j := 0; j < 10 && j < len(keys); j++ {
+					_ = values[j]
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkIteratorFull(b *testing.B) {
+	for _, n := range benchSizes {
+		data := benchData(n)
+		db := FromData(data)
+
+		b.Run(fmt.Sprintf("tree/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				it := db.NewIterator()
+				for it.Next() {
+					_ = it.Value()
+				}
+				_ = it.Close()
+			}
+		})
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				keys, values := mapIteratorWithPrefix(data, "")
+				for j := range keys {
+					_ = values[j]
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPut(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("tree/%d", n), func(b *testing.B) {
+			db := FromData(benchData(n))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = db.Put(fmt.Sprintf("%02d/%08d", i%100, i%n), "new")
+			}
+		})
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			data := benchData(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				data[fmt.Sprintf("%02d/%08d", i%100, i%n)] = "new"
+			}
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, n := range benchSizes {
+		data := benchData(n)
+		db := FromData(data)
+
+		b.Run(fmt.Sprintf("tree/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = db.Get(fmt.Sprintf("%02d/%08d", i%100, i%n))
+			}
+		})
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = data[fmt.Sprintf("%02d/%08d", i%100, i%n)]
+			}
+		})
+	}
+}
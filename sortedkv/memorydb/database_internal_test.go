@@ -5,6 +5,10 @@ package memorydb
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
 	"polycry.pt/poly-go/sortedkv/test"
 )
 
@@ -35,3 +39,32 @@ func TestDatabase(t *testing.T) {
 	ittest.NextMustEqual("k3", "v3")
 	ittest.MustEnd()
 }
+
+func TestDatabase_Close(t *testing.T) {
+	test.GenericClosedDatabaseTest(t, NewDatabase())
+}
+
+func TestDatabase_Compact(t *testing.T) {
+	test.GenericCompactTest(t, NewDatabase())
+	test.GenericCompactTest(t, sortedkv.NewWatchable(NewDatabase()))
+
+	db := FromData(map[string]string{"a": "12", "b": "345", "c": ""}).(*Database)
+	for _, r := range []struct {
+		start, end string
+		size       int64
+	}{{"", "", 8}, {"a", "b", 3}, {"a1", "c", 4}, {"b", "", 5}, {"c", "a", 0}, {"d", "", 0}} {
+		size, err := db.ApproximateSize(r.start, r.end)
+		require.NoError(t, err)
+		assert.Equal(t, r.size, size, "[%q, %q)", r.start, r.end)
+	}
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"memorydb.keys": "3", "memorydb.bytes": "8"}, stats)
+
+	require.NoError(t, db.Close())
+	assert.True(t, sortedkv.IsClosed(db.CompactRange("", "")))
+	_, err = db.ApproximateSize("", "")
+	assert.True(t, sortedkv.IsClosed(err))
+	_, err = db.Stats()
+	assert.True(t, sortedkv.IsClosed(err))
+}
@@ -2,41 +2,224 @@
 
 package memorydb
 
-// Iterator provides an iterator over a key range.
+// Iterator provides an iterator over a key range. It implements
+// sortedkv.SeekableIterator.
+//
+// The iterator lazily walks the tree of the database at the time the iterator
+// was created. Since the tree is persistent, later writes to the database are
+// not visible to the iterator and nothing needs to be copied upfront.
 type Iterator struct {
-	next   int
-	keys   []string
-	values []string
+	root  *node
+	start string
+	end   string // Empty if unbounded.
+
+	// path is the path from the root to the current node. It is empty if the
+	// iterator is not positioned at an element.
+	path  []*node
+	after bool // Whether the iterator is positioned after the last element.
+	moved bool // Whether the iterator was moved since it was created.
+	err   error
+}
+
+// newIterator creates an iterator over the range [start, end) of the tree
+// rooted at root. An empty end denotes no upper bound.
+func newIterator(root *node, start, end string) *Iterator {
+	return &Iterator{
+		root:  root,
+		start: start,
+		end:   end,
+		path:  make([]*node, 0, height(root)),
+	}
 }
 
-// Next returns true if the iterator has a next element.
+// Next moves the iterator to the next element.
 func (i *Iterator) Next() bool {
-	i.next++
-	return i.next <= len(i.keys)
+	i.moved = true
+	switch {
+	case i.valid():
+		i.successor()
+		return i.check()
+	case i.after:
+		return false
+	default:
+		return i.First()
+	}
+}
+
+// Prev moves the iterator to the previous element.
+func (i *Iterator) Prev() bool {
+	i.moved = true
+	switch {
+	case i.valid():
+		i.predecessor()
+		return i.check()
+	case i.after:
+		return i.Last()
+	default:
+		return false
+	}
+}
+
+// First moves the iterator to the first element.
+func (i *Iterator) First() bool {
+	i.moved = true
+	i.seekGE(i.start)
+	return i.check()
+}
+
+// Last moves the iterator to the last element.
+func (i *Iterator) Last() bool {
+	i.moved = true
+	if i.end == "" {
+		i.seekLast()
+	} else {
+		i.seekLT(i.end)
+	}
+	return i.check()
+}
+
+// Seek moves the iterator to the first element whose key is greater than or
+// equal to key.
+func (i *Iterator) Seek(key string) bool {
+	i.moved = true
+	if key < i.start {
+		key = i.start
+	}
+	i.seekGE(key)
+	return i.check()
+}
+
+// valid returns whether the iterator is positioned at an element.
+func (i *Iterator) valid() bool {
+	return len(i.path) != 0
+}
+
+// current returns the current node. The iterator must be valid.
+func (i *Iterator) current() *node {
+	return i.path[len(i.path)-1]
+}
+
+// check invalidates the iterator if it left its range and returns whether it
+// is positioned at an element.
+func (i *Iterator) check() bool {
+	if !i.valid() {
+		return false
+	}
+	if key := i.current().key; key < i.start {
+		i.path, i.after = i.path[:0], false
+	} else if i.end != "" && key >= i.end {
+		i.path, i.after = i.path[:0], true
+	}
+	return i.valid()
+}
+
+// seekGE positions the iterator at the smallest key greater than or equal to
+// key, or after the last element if there is none.
+func (i *Iterator) seekGE(key string) {
+	i.path, i.after = i.path[:0], true
+	found := 0
+	for n := i.root; n != nil; {
+		i.path = append(i.path, n)
+		if n.key >= key {
+			found = len(i.path)
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	i.path = i.path[:found]
+}
+
+// seekLT positions the iterator at the greatest key less than key, or before
+// the first element if there is none.
+func (i *Iterator) seekLT(key string) {
+	i.path, i.after = i.path[:0], false
+	found := 0
+	for n := i.root; n != nil; {
+		i.path = append(i.path, n)
+		if n.key < key {
+			found = len(i.path)
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	i.path = i.path[:found]
+}
+
+// seekLast positions the iterator at the greatest key.
+func (i *Iterator) seekLast() {
+	i.path, i.after = i.path[:0], false
+	for n := i.root; n != nil; n = n.right {
+		i.path = append(i.path, n)
+	}
+}
+
+// successor moves the iterator to the in-order successor of the current node.
+func (i *Iterator) successor() {
+	if n := i.current().right; n != nil {
+		for ; n != nil; n = n.left {
+			i.path = append(i.path, n)
+		}
+		return
+	}
+	for {
+		child := i.current()
+		i.path = i.path[:len(i.path)-1]
+		if !i.valid() {
+			i.after = true
+			return
+		}
+		if i.current().left == child {
+			return
+		}
+	}
+}
+
+// predecessor moves the iterator to the in-order predecessor of the current
+// node.
+func (i *Iterator) predecessor() {
+	if n := i.current().left; n != nil {
+		for ; n != nil; n = n.right {
+			i.path = append(i.path, n)
+		}
+		return
+	}
+	for {
+		child := i.current()
+		i.path = i.path[:len(i.path)-1]
+		if !i.valid() {
+			i.after = false
+			return
+		}
+		if i.current().right == child {
+			return
+		}
+	}
 }
 
 // Key returns the key of the current element.
 func (i *Iterator) Key() string {
-	if i.next == 0 {
+	if !i.moved {
 		panic("Iterator.Key() accessed before Next() or after Close().")
 	}
 
-	if i.next > len(i.keys) {
+	if !i.valid() {
 		return ""
 	}
-	return i.keys[i.next-1]
+	return i.current().key
 }
 
 // Value returns the value of the current element.
 func (i *Iterator) Value() string {
-	if i.next == 0 {
+	if !i.moved {
 		panic("Iterator.Value() accessed before Next() or after Close().")
 	}
 
-	if i.next > len(i.keys) {
+	if !i.valid() {
 		return ""
 	}
-	return i.values[i.next-1]
+	return i.current().value
 }
 
 // ValueBytes returns the value converted to bytes of the current element.
@@ -44,10 +227,14 @@ func (i *Iterator) ValueBytes() []byte {
 	return []byte(i.Value())
 }
 
-// Close closes this iterator.
+// Close closes this iterator. It returns the error that occurred when the
+// iterator was created, if any.
 func (i *Iterator) Close() error {
-	i.next = 0
-	i.keys = nil
-	i.values = nil
-	return nil
+	err := i.err
+	i.root = nil
+	i.path = nil
+	i.after = false
+	i.moved = false
+	i.err = nil
+	return err
 }
@@ -3,10 +3,15 @@
 package memorydb
 
 import (
+	"sort"
+	"strconv"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"polycry.pt/poly-go/sortedkv"
 	"polycry.pt/poly-go/sortedkv/test"
+	pkgtest "polycry.pt/poly-go/test"
 )
 
 func TestIterator(t *testing.T) {
@@ -17,4 +22,86 @@ func TestIterator(t *testing.T) {
 	t.Run("Table iterator test", func(t *testing.T) {
 		test.GenericIteratorTest(t, sortedkv.NewTable(NewDatabase(), "table"))
 	})
+
+	t.Run("Generic seekable iterator test", func(t *testing.T) {
+		test.GenericSeekableIteratorTest(t, NewDatabase())
+	})
+
+	t.Run("Table seekable iterator test", func(t *testing.T) {
+		test.GenericSeekableIteratorTest(t, sortedkv.NewTable(NewDatabase(), "table"))
+	})
+}
+
+func TestList(t *testing.T) {
+	test.GenericListTest(t, NewDatabase())
+}
+
+func TestIterator_Model(t *testing.T) {
+	rng := pkgtest.Prng(t)
+	data := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		data[strconv.Itoa(rng.Intn(1000))] = strconv.Itoa(i)
+	}
+	db := FromData(data)
+
+	for i := 0; i < 100; i++ {
+		start, end := strconv.Itoa(rng.Intn(1000)), strconv.Itoa(rng.Intn(1000))
+		if rng.Intn(4) == 0 {
+			end = ""
+		}
+		var keys []string
+		for key := range data {
+			if key >= start && (end == "" || key < end) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		it := db.NewIteratorWithRange(start, end).(*Iterator)
+		pos := -1 // Position in keys, len(keys) if after the last element.
+		for j := 0; j < 50; j++ {
+			var ok bool
+			switch rng.Intn(5) {
+			case 0:
+				ok = it.Next()
+				pos = minInt(pos+1, len(keys))
+			case 1:
+				ok = it.Prev()
+				if pos == len(keys) {
+					pos = len(keys) - 1
+				} else {
+					pos = maxInt(pos-1, -1)
+				}
+			case 2:
+				ok, pos = it.First(), 0
+			case 3:
+				ok, pos = it.Last(), len(keys)-1
+			case 4:
+				seek := strconv.Itoa(rng.Intn(1000))
+				ok = it.Seek(seek)
+				pos = sort.SearchStrings(keys, seek)
+			}
+			if pos < 0 || pos >= len(keys) {
+				require.False(t, ok)
+				require.Equal(t, "", it.Key())
+				if pos < 0 {
+					pos = -1
+				} else {
+					pos = len(keys)
+				}
+				continue
+			}
+			require.True(t, ok)
+			require.Equal(t, keys[pos], it.Key())
+			require.Equal(t, data[keys[pos]], it.Value())
+		}
+		require.NoError(t, it.Close())
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
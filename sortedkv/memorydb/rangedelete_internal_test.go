@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestRangeDelete(t *testing.T) {
+	t.Run("Generic range delete test", func(t *testing.T) {
+		test.GenericRangeDeleteTest(t, NewDatabase())
+	})
+
+	t.Run("Table range delete test", func(t *testing.T) {
+		db := NewDatabase()
+		dbtest := test.DatabaseTest{T: t, Database: db}
+		dbtest.Put("a", "outside")
+		dbtest.Put("tabl", "outside")
+		dbtest.Put("tablf", "outside")
+		test.GenericRangeDeleteTest(t, sortedkv.NewTable(db, "table"))
+		dbtest.MustGetEqual("a", "outside")
+		dbtest.MustGetEqual("tabl", "outside")
+		dbtest.MustGetEqual("tablf", "outside")
+	})
+}
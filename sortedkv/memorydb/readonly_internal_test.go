@@ -0,0 +1,14 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestReadOnly(t *testing.T) {
+	test.GenericReadOnlyTest(t, func() sortedkv.Database { return NewDatabase() })
+}
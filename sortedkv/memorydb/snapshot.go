@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import "polycry.pt/poly-go/sortedkv"
+
+// Snapshot is a read-only, point-in-time view of a Database.
+type Snapshot struct {
+	db *Database
+}
+
+// NewSnapshot creates a snapshot of the current contents of the database.
+// Taking a snapshot is O(1) and does not copy any data.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return nil, &sortedkv.ClosedError{}
+	}
+	return &Snapshot{db: &Database{root: d.root, size: d.size}}, nil
+}
+
+// Has returns true if the snapshot contains a key.
+func (s *Snapshot) Has(key string) (bool, error) {
+	return s.db.Has(key)
+}
+
+// Get returns a value to a key.
+func (s *Snapshot) Get(key string) (string, error) {
+	return s.db.Get(key)
+}
+
+// GetBytes returns a value to a key in bytes.
+func (s *Snapshot) GetBytes(key string) ([]byte, error) {
+	return s.db.GetBytes(key)
+}
+
+// NewIterator creates a new iterator.
+func (s *Snapshot) NewIterator() sortedkv.Iterator {
+	return s.db.NewIterator()
+}
+
+// NewIteratorWithRange creates a new iterator based on a given range.
+func (s *Snapshot) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return s.db.NewIteratorWithRange(start, end)
+}
+
+// NewIteratorWithPrefix creates a new iterator for a given prefix.
+func (s *Snapshot) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return s.db.NewIteratorWithPrefix(prefix)
+}
+
+// Close releases the snapshot.
+func (s *Snapshot) Close() error {
+	return s.db.Close()
+}
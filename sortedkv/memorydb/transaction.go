@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/internal/txn"
+)
+
+// NewTransaction creates a new optimistic transaction on the database.
+func (d *Database) NewTransaction() (sortedkv.Transaction, error) {
+	return txn.New(d.lookup, d, d.commit), nil
+}
+
+// lookup returns the value of a key and whether it is present.
+func (d *Database) lookup(key string) (string, bool, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return d.lookupLocked(key)
+}
+
+// lookupLocked is like lookup, but the database must be locked already.
+func (d *Database) lookupLocked(key string) (string, bool, error) {
+	if d.closed {
+		return "", false, &sortedkv.ClosedError{}
+	}
+	value, ok := get(d.root, key)
+	return value, ok, nil
+}
+
+// commit atomically validates and applies a transaction.
+func (d *Database) commit(tx *txn.Transaction) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return &sortedkv.ClosedError{}
+	}
+	if err := tx.Validate(d.lookupLocked); err != nil {
+		return err
+	}
+	for _, w := range tx.Writes() {
+		if w.Delete {
+			d.delete(w.Key)
+		} else {
+			d.put(w.Key, w.Value)
+		}
+	}
+	return nil
+}
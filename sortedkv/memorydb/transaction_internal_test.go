@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestTransaction(t *testing.T) {
+	test.GenericTransactionTest(t, NewDatabase())
+}
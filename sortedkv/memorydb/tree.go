@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+// node is a node of a persistent AVL tree. Nodes are never modified once they
+// are part of a tree. Instead, insertions and removals copy the path from the
+// root to the changed node and return the new root. This makes holding on to
+// an old root a consistent, O(1) snapshot of the tree.
+type node struct {
+	key    string
+	value  string
+	left   *node
+	right  *node
+	height int
+	bytes  int64 // Total size of the keys and values in the subtree.
+}
+
+// newNode creates a new node with the given children.
+func newNode(key, value string, left, right *node) *node {
+	return &node{
+		key:    key,
+		value:  value,
+		left:   left,
+		right:  right,
+		height: maxInt(height(left), height(right)) + 1,
+		bytes:  subtreeBytes(left) + subtreeBytes(right) + int64(len(key)+len(value)),
+	}
+}
+
+// height returns the height of a subtree. The empty tree has height 0.
+func height(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+// subtreeBytes returns the total size of the keys and values of a subtree.
+func subtreeBytes(n *node) int64 {
+	if n == nil {
+		return 0
+	}
+	return n.bytes
+}
+
+// bytesBefore returns the total size of the keys and values of the entries
+// whose keys are less than key in the tree rooted at n.
+func bytesBefore(n *node, key string) int64 {
+	var sum int64
+	for n != nil {
+		if n.key < key {
+			sum += subtreeBytes(n.left) + int64(len(n.key)+len(n.value))
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return sum
+}
+
+// get returns the value of key in the tree rooted at n.
+func get(n *node, key string) (string, bool) {
+	for n != nil {
+		switch {
+		case key < n.key:
+			n = n.left
+		case key > n.key:
+			n = n.right
+		default:
+			return n.value, true
+		}
+	}
+	return "", false
+}
+
+// insert returns the root of a tree that contains all entries of the tree
+// rooted at n and key mapped to value. It reports whether key was new.
+func insert(n *node, key, value string) (*node, bool) {
+	if n == nil {
+		return newNode(key, value, nil, nil), true
+	}
+
+	var added bool
+	switch {
+	case key < n.key:
+		var left *node
+		left, added = insert(n.left, key, value)
+		return balance(n.key, n.value, left, n.right), added
+	case key > n.key:
+		var right *node
+		right, added = insert(n.right, key, value)
+		return balance(n.key, n.value, n.left, right), added
+	default:
+		return newNode(key, value, n.left, n.right), false
+	}
+}
+
+// remove returns the root of a tree that contains all entries of the tree
+// rooted at n except key. It reports whether key was present.
+func remove(n *node, key string) (*node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	var removed bool
+	switch {
+	case key < n.key:
+		var left *node
+		if left, removed = remove(n.left, key); !removed {
+			return n, false
+		}
+		return balance(n.key, n.value, left, n.right), true
+	case key > n.key:
+		var right *node
+		if right, removed = remove(n.right, key); !removed {
+			return n, false
+		}
+		return balance(n.key, n.value, n.left, right), true
+	case n.left == nil:
+		return n.right, true
+	case n.right == nil:
+		return n.left, true
+	default:
+		// Replace n by its successor.
+		succ := n.right
+		for succ.left != nil {
+			succ = succ.left
+		}
+		right, _ := remove(n.right, succ.key)
+		return balance(succ.key, succ.value, n.left, right), true
+	}
+}
+
+// balance creates a node from the given entry and subtrees, whose heights may
+// differ by at most two, and rebalances it.
+func balance(key, value string, left, right *node) *node {
+	switch hl, hr := height(left), height(right); {
+	case hl > hr+1:
+		if height(left.left) >= height(left.right) {
+			return newNode(left.key, left.value,
+				left.left,
+				newNode(key, value, left.right, right))
+		}
+		return newNode(left.right.key, left.right.value,
+			newNode(left.key, left.value, left.left, left.right.left),
+			newNode(key, value, left.right.right, right))
+	case hr > hl+1:
+		if height(right.right) >= height(right.left) {
+			return newNode(right.key, right.value,
+				newNode(key, value, left, right.left),
+				right.right)
+		}
+		return newNode(right.left.key, right.left.value,
+			newNode(key, value, left, right.left.left),
+			newNode(right.key, right.value, right.left.right, right.right))
+	default:
+		return newNode(key, value, left, right)
+	}
+}
+
+// fromSorted builds a balanced tree from sorted, unique keys and their values.
+func fromSorted(keys []string, values []string) *node {
+	if len(keys) == 0 {
+		return nil
+	}
+	mid := len(keys) / 2
+	return newNode(keys[mid], values[mid],
+		fromSorted(keys[:mid], values[:mid]),
+		fromSorted(keys[mid+1:], values[mid+1:]))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+func TestTree(t *testing.T) {
+	rng := pkgtest.Prng(t)
+	model := make(map[string]string)
+	var root *node
+
+	for i := 0; i < 2000; i++ {
+		key := strconv.Itoa(rng.Intn(500))
+		if rng.Intn(3) == 0 {
+			var removed bool
+			root, removed = remove(root, key)
+			_, had := model[key]
+			assert.Equal(t, had, removed, "remove(%q)", key)
+			delete(model, key)
+		} else {
+			value := strconv.Itoa(i)
+			var added bool
+			root, added = insert(root, key, value)
+			_, had := model[key]
+			assert.Equal(t, !had, added, "insert(%q)", key)
+			model[key] = value
+		}
+	}
+
+	requireTreeValid(t, root)
+	keys := make([]string, 0, len(model))
+	for key := range model {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, keys, treeKeys(root))
+	for key, value := range model {
+		v, ok := get(root, key)
+		assert.True(t, ok)
+		assert.Equal(t, value, v)
+	}
+
+	for i := 0; i < 100; i++ {
+		bound := strconv.Itoa(rng.Intn(600))
+		var want int64
+		for key, value := range model {
+			if key < bound {
+				want += int64(len(key) + len(value))
+			}
+		}
+		assert.Equal(t, want, bytesBefore(root, bound), "bytesBefore(%q)", bound)
+	}
+}
+
+func TestTree_Persistent(t *testing.T) {
+	var old *node
+	for i := 0; i < 100; i++ {
+		old, _ = insert(old, strconv.Itoa(i), "old")
+	}
+	oldKeys := treeKeys(old)
+
+	root := old
+	for i := 0; i < 100; i += 2 {
+		root, _ = remove(root, strconv.Itoa(i))
+	}
+	root, _ = insert(root, "new", "new")
+
+	assert.Equal(t, oldKeys, treeKeys(old), "old tree must not change")
+	requireTreeValid(t, old)
+	requireTreeValid(t, root)
+	assert.Len(t, treeKeys(root), 51)
+}
+
+func TestFromSorted(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e", "f", "g"}
+	root := fromSorted(keys, keys)
+	requireTreeValid(t, root)
+	assert.Equal(t, keys, treeKeys(root))
+}
+
+// requireTreeValid checks the ordering, height and balance invariants.
+func requireTreeValid(t *testing.T, n *node) {
+	t.Helper()
+	if n == nil {
+		return
+	}
+	require.Equal(t, maxInt(height(n.left), height(n.right))+1, n.height)
+	require.Equal(t, subtreeBytes(n.left)+subtreeBytes(n.right)+int64(len(n.key)+len(n.value)), n.bytes)
+	require.LessOrEqual(t, height(n.left)-height(n.right), 1, "unbalanced at %q", n.key)
+	require.LessOrEqual(t, height(n.right)-height(n.left), 1, "unbalanced at %q", n.key)
+	if n.left != nil {
+		require.Less(t, n.left.key, n.key)
+	}
+	if n.right != nil {
+		require.Greater(t, n.right.key, n.key)
+	}
+	requireTreeValid(t, n.left)
+	requireTreeValid(t, n.right)
+}
+
+// treeKeys returns the keys of a tree in order.
+func treeKeys(n *node) []string {
+	if n == nil {
+		return []string{}
+	}
+	return append(append(treeKeys(n.left), n.key), treeKeys(n.right)...)
+}
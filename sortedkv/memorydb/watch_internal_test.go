@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memorydb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestWatch(t *testing.T) {
+	test.GenericWatchTest(t, sortedkv.NewWatchable(NewDatabase()))
+}
+
+func TestWatch_Transparent(t *testing.T) {
+	test.GenericDatabaseTest(t, sortedkv.NewWatchable(NewDatabase()))
+	test.GenericBatchTest(t, sortedkv.NewWatchable(NewDatabase()))
+	test.GenericSnapshotTest(t, sortedkv.NewWatchable(NewDatabase()))
+	test.GenericTransactionTest(t, sortedkv.NewWatchable(NewDatabase()))
+	test.GenericRangeDeleteTest(t, sortedkv.NewWatchable(NewDatabase()))
+	test.GenericClosedDatabaseTest(t, sortedkv.NewWatchable(NewDatabase()))
+}
+
+func TestWatch_Close(t *testing.T) {
+	db := sortedkv.NewWatchable(NewDatabase())
+	events, err := db.Watch(context.Background(), "")
+	require.NoError(t, err)
+	tableEvents, err := sortedkv.NewTable(db, "table").(sortedkv.Watcher).Watch(context.Background(), "")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Put("key", "value"))
+	require.NoError(t, db.Close())
+
+	assert.Equal(t, sortedkv.Event{Type: sortedkv.EventPut, Key: "key", Value: "value"}, <-events)
+	_, ok := <-events
+	assert.False(t, ok, "events must be closed")
+	_, ok = <-tableEvents
+	assert.False(t, ok, "table events must be closed")
+
+	_, err = db.Watch(context.Background(), "")
+	assert.True(t, sortedkv.IsClosed(err), "expected ClosedError, got %v", err)
+}
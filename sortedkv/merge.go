@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import "container/heap"
+
+// Precedence decides which entries a MergeIterator yields when several of its
+// iterators contain the same key.
+type Precedence int
+
+const (
+	// FirstWins yields only the entry of the iterator that was passed first.
+	FirstWins Precedence = iota
+	// LastWins yields only the entry of the iterator that was passed last.
+	LastWins
+	// KeepAll yields the entries of all iterators, in the order in which the
+	// iterators were passed.
+	KeepAll
+)
+
+type (
+	// MergeIterator merges several iterators into one Iterator in ascending
+	// key order. Duplicate keys are resolved by its Precedence.
+	MergeIterator struct {
+		precedence Precedence
+		its        []Iterator
+		heads      mergeHeap // Iterators that are positioned at an entry.
+		cur        int       // Index of the current iterator, or -1.
+		started    bool
+		closed     bool
+	}
+
+	// mergeHeap is a min-heap of the indices of iterators, ordered by their
+	// current key and then by the precedence.
+	mergeHeap struct {
+		its     []Iterator
+		indices []int
+		lastWin bool
+	}
+)
+
+// NewMergeIterator creates an iterator that yields the entries of all its in
+// ascending key order. The merge iterator takes ownership of its and closes
+// them when it is closed.
+func NewMergeIterator(precedence Precedence, its ...Iterator) *MergeIterator {
+	return &MergeIterator{
+		precedence: precedence,
+		its:        its,
+		heads: mergeHeap{
+			its:     its,
+			lastWin: precedence == LastWins,
+		},
+		cur: -1,
+	}
+}
+
+// Next moves the iterator to the next key/value pair.
+func (m *MergeIterator) Next() bool {
+	if m.closed {
+		return false
+	}
+	if !m.started {
+		m.started = true
+		for i := range m.its {
+			m.advance(i)
+		}
+	} else if m.cur >= 0 {
+		m.advance(m.cur)
+	}
+
+	if m.heads.Len() == 0 {
+		m.cur = -1
+		return false
+	}
+	m.cur = heap.Pop(&m.heads).(int)
+	if m.precedence != KeepAll {
+		// Skip the shadowed entries of the other iterators.
+		key := m.its[m.cur].Key()
+		for m.heads.Len() > 0 && m.its[m.heads.indices[0]].Key() == key {
+			m.advance(heap.Pop(&m.heads).(int))
+		}
+	}
+	return true
+}
+
+// advance moves the i-th iterator to its next entry and adds it to the heap
+// if it has one.
+func (m *MergeIterator) advance(i int) {
+	if m.its[i].Next() {
+		heap.Push(&m.heads, i)
+	}
+}
+
+// Source returns the index of the iterator that provides the current key/value
+// pair, or -1 if done.
+func (m *MergeIterator) Source() int {
+	return m.cur
+}
+
+// Key returns the key of the current key/value pair, or "" if done.
+func (m *MergeIterator) Key() string {
+	if m.cur < 0 {
+		return ""
+	}
+	return m.its[m.cur].Key()
+}
+
+// Value returns the value of the current key/value pair, or "" if done.
+func (m *MergeIterator) Value() string {
+	if m.cur < 0 {
+		return ""
+	}
+	return m.its[m.cur].Value()
+}
+
+// ValueBytes returns the value of the current key/value pair, or nil if done.
+func (m *MergeIterator) ValueBytes() []byte {
+	if m.cur < 0 {
+		return nil
+	}
+	return m.its[m.cur].ValueBytes()
+}
+
+// Close closes all merged iterators and returns the first of their errors.
+func (m *MergeIterator) Close() error {
+	var err error
+	for _, it := range m.its {
+		if cerr := it.Close(); err == nil {
+			err = cerr
+		}
+	}
+	m.closed = true
+	m.cur = -1
+	m.heads.indices = nil
+	return err
+}
+
+func (h *mergeHeap) Len() int { return len(h.indices) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.indices[i], h.indices[j]
+	if ka, kb := h.its[a].Key(), h.its[b].Key(); ka != kb {
+		return ka < kb
+	}
+	if h.lastWin {
+		return a > b
+	}
+	return a < b
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.indices[i], h.indices[j] = h.indices[j], h.indices[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.indices = append(h.indices, x.(int)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	i := h.indices[len(h.indices)-1]
+	h.indices = h.indices[:len(h.indices)-1]
+	return i
+}
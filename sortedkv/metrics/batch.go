@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// batch records the application of an underlying batch.
+	batch struct {
+		b      sortedkv.Batch
+		m      *meter
+		writes writeSet
+	}
+
+	// writeSet counts buffered writes per table.
+	writeSet struct {
+		tables []string // In the order of their first write.
+		events map[string]*Event
+	}
+)
+
+// Put puts a value into the batch.
+func (b *batch) Put(key string, value string) error {
+	b.writes.add(b.m.table(key), 1, len(key)+len(value))
+	return b.b.Put(key, value)
+}
+
+// PutBytes puts a value into the batch.
+func (b *batch) PutBytes(key string, value []byte) error {
+	b.writes.add(b.m.table(key), 1, len(key)+len(value))
+	return b.b.PutBytes(key, value)
+}
+
+// Delete deletes a key in the batch.
+func (b *batch) Delete(key string) error {
+	b.writes.add(b.m.table(key), 1, len(key))
+	return b.b.Delete(key)
+}
+
+// DeleteRange deletes all keys in the range [start, end) in the batch. It is
+// attributed to the table of start and counts no keys. It fails if the
+// underlying batch is not a sortedkv.RangeDeleter.
+func (b *batch) DeleteRange(start string, end string) error {
+	rd, ok := b.b.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeleteRange"}
+	}
+	b.writes.add(b.m.table(start), 0, len(start)+len(end))
+	return rd.DeleteRange(start, end)
+}
+
+// DeletePrefix deletes all keys with the given prefix in the batch. It is
+// attributed to the table of the prefix and counts no keys. It fails if the
+// underlying batch is not a sortedkv.RangeDeleter.
+func (b *batch) DeletePrefix(prefix string) error {
+	rd, ok := b.b.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeletePrefix"}
+	}
+	b.writes.add(b.m.table(prefix), 0, len(prefix))
+	return rd.DeletePrefix(prefix)
+}
+
+// Apply applies the underlying batch. It records an event for every table
+// that the batch writes to.
+func (b *batch) Apply() error {
+	start := time.Now()
+	err := b.b.Apply()
+	b.writes.record(b.m, OpBatch, start, err)
+	return err
+}
+
+// Reset resets the underlying batch.
+func (b *batch) Reset() {
+	b.b.Reset()
+	b.writes = writeSet{}
+}
+
+// add counts a write of the given number of keys and size to a table.
+func (w *writeSet) add(table string, keys, size int) {
+	if w.events == nil {
+		w.events = make(map[string]*Event)
+	}
+	e, ok := w.events[table]
+	if !ok {
+		e = &Event{Table: table}
+		w.events[table] = e
+		w.tables = append(w.tables, table)
+	}
+	e.Keys += keys
+	e.BytesWritten += size
+}
+
+// record records an event for every table that was written to, or a single
+// event for the table "" if nothing was written.
+func (w *writeSet) record(m *meter, op Op, start time.Time, err error) {
+	if len(w.tables) == 0 {
+		m.record(Event{Op: op, Start: start, Err: err})
+		return
+	}
+	for _, table := range w.tables {
+		e := *w.events[table]
+		e.Op, e.Start, e.Err = op, start, err
+		m.record(e)
+	}
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// Database records the operations on an underlying database. It implements
+	// sortedkv.Database, sortedkv.RangeDeleter, sortedkv.Snapshotter,
+	// sortedkv.Transactor, sortedkv.Watcher, sortedkv.Compacter and
+	// sortedkv.Stater. The optional operations fail
+	// with a *sortedkv.NotSupportedError if the underlying database does not
+	// support them.
+	Database struct {
+		reader
+		db sortedkv.Database
+	}
+
+	// Option configures a Database. Options are passed to New.
+	Option func(*meter)
+
+	// meter attributes events to tables and passes them to the recorder.
+	meter struct {
+		rec    Recorder
+		tables []string // Sorted longest first.
+	}
+)
+
+// WithTables sets the table prefixes that events are attributed to.
+func WithTables(prefixes ...string) Option {
+	return func(m *meter) { m.tables = append(m.tables, prefixes...) }
+}
+
+// New wraps db so that all operations are recorded by rec.
+func New(db sortedkv.Database, rec Recorder, opts ...Option) *Database {
+	m := &meter{rec: rec}
+	for _, opt := range opts {
+		opt(m)
+	}
+	sort.SliceStable(m.tables, func(i, j int) bool { return len(m.tables[i]) > len(m.tables[j]) })
+	return &Database{reader: reader{r: db, it: db, m: m}, db: db}
+}
+
+// table returns the table of a key.
+func (m *meter) table(key string) string {
+	for _, t := range m.tables {
+		if strings.HasPrefix(key, t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// record completes the duration of an event that started at e.Start and
+// records it.
+func (m *meter) record(e Event) {
+	e.Duration = time.Since(e.Start)
+	m.rec.Record(e)
+}
+
+// Put saves a value under a key.
+func (d *Database) Put(key string, value string) error {
+	start := time.Now()
+	err := d.db.Put(key, value)
+	d.m.record(Event{Op: OpPut, Table: d.m.table(key), Start: start, Keys: 1, BytesWritten: len(key) + len(value), Err: err})
+	return err
+}
+
+// PutBytes saves a value under a key.
+func (d *Database) PutBytes(key string, value []byte) error {
+	start := time.Now()
+	err := d.db.PutBytes(key, value)
+	d.m.record(Event{Op: OpPut, Table: d.m.table(key), Start: start, Keys: 1, BytesWritten: len(key) + len(value), Err: err})
+	return err
+}
+
+// Delete deletes a key.
+func (d *Database) Delete(key string) error {
+	start := time.Now()
+	err := d.db.Delete(key)
+	d.m.record(Event{Op: OpDelete, Table: d.m.table(key), Start: start, Keys: 1, BytesWritten: len(key), Err: err})
+	return err
+}
+
+// DeleteRange deletes all keys in the range [start, end). It is attributed
+// to the table of start, even if the range spans several tables, and counts
+// no keys, since their number is unknown.
+func (d *Database) DeleteRange(start string, end string) error {
+	db, ok := d.db.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeleteRange"}
+	}
+	t := time.Now()
+	err := db.DeleteRange(start, end)
+	d.m.record(Event{Op: OpDeleteRange, Table: d.m.table(start), Start: t, BytesWritten: len(start) + len(end), Err: err})
+	return err
+}
+
+// DeletePrefix deletes all keys with the given prefix. It is attributed to the
+// table of the prefix and counts no keys.
+func (d *Database) DeletePrefix(prefix string) error {
+	db, ok := d.db.(sortedkv.RangeDeleter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "DeletePrefix"}
+	}
+	start := time.Now()
+	err := db.DeletePrefix(prefix)
+	d.m.record(Event{Op: OpDeleteRange, Table: d.m.table(prefix), Start: start, BytesWritten: len(prefix), Err: err})
+	return err
+}
+
+// NewBatch creates a batch whose application is recorded.
+func (d *Database) NewBatch() sortedkv.Batch {
+	return &batch{b: d.db.NewBatch(), m: d.m}
+}
+
+// NewSnapshot creates a snapshot whose reads are recorded.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	db, ok := d.db.(sortedkv.Snapshotter)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewSnapshot"}
+	}
+	start := time.Now()
+	s, err := db.NewSnapshot()
+	d.m.record(Event{Op: OpSnapshot, Start: start, Err: err})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{reader: reader{r: s, it: s, m: d.m}, s: s}, nil
+}
+
+// NewTransaction creates a transaction whose operations are recorded.
+func (d *Database) NewTransaction() (sortedkv.Transaction, error) {
+	db, ok := d.db.(sortedkv.Transactor)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewTransaction"}
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{reader: reader{r: tx, it: tx, m: d.m}, tx: tx}, nil
+}
+
+// Watch calls Watch on the underlying database. Watching is not recorded.
+func (d *Database) Watch(ctx context.Context, prefix string) (<-chan sortedkv.Event, error) {
+	db, ok := d.db.(sortedkv.Watcher)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "Watch"}
+	}
+	return db.Watch(ctx, prefix)
+}
+
+// CompactRange calls CompactRange on the underlying database. Compaction is
+// not recorded. It fails if the underlying database is not a
+// sortedkv.Compacter.
+func (d *Database) CompactRange(start string, end string) error {
+	db, ok := d.db.(sortedkv.Compacter)
+	if !ok {
+		return &sortedkv.NotSupportedError{Op: "CompactRange"}
+	}
+	return db.CompactRange(start, end)
+}
+
+// ApproximateSize calls ApproximateSize on the underlying database. It is not
+// recorded. It fails if the underlying database is not a sortedkv.Stater.
+func (d *Database) ApproximateSize(start string, end string) (int64, error) {
+	db, ok := d.db.(sortedkv.Stater)
+	if !ok {
+		return 0, &sortedkv.NotSupportedError{Op: "ApproximateSize"}
+	}
+	return db.ApproximateSize(start, end)
+}
+
+// Stats calls Stats on the underlying database. It is not recorded. It fails
+// if the underlying database is not a sortedkv.Stater.
+func (d *Database) Stats() (map[string]string, error) {
+	db, ok := d.db.(sortedkv.Stater)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "Stats"}
+	}
+	return db.Stats()
+}
+
+// Close closes the underlying database.
+func (d *Database) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics provides a decorator for sortedkv databases that records
+// every operation.
+//
+// A Database wraps another database and passes an Event for every Reader,
+// Writer, Batch, Iterator and Transaction operation to a Recorder. Events carry
+// the start time and duration of the operation, so that a Recorder can export
+// them as metrics as well as trace spans. Memory is a Recorder that aggregates
+// the events in memory, which is mostly useful in tests.
+//
+// Tables
+//
+// Events are attributed to the table of the accessed key, which is the
+// longest of the prefixes passed via WithTables. Keys outside of all tables
+// belong to the table "". Range deletions are attributed to the table of
+// their start key or prefix, even if the range spans several tables.
+//
+// Since the keys of a sortedkv.NewTable on top of a Database are prefixed,
+// wrapping a database once and creating the tables on top of the wrapper
+// attributes their operations correctly:
+//
+//	db := metrics.New(backend, recorder, metrics.WithTables("users.", "orders."))
+//	users := sortedkv.NewTable(db, "users.")
+package metrics // import "polycry.pt/poly-go/sortedkv/metrics"
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// iterator records the lifetime of an iterator when it is closed.
+	iterator struct {
+		sortedkv.Iterator
+		m      *meter
+		event  Event
+		closed bool
+	}
+
+	// seekableIterator is an iterator over a seekable iterator.
+	seekableIterator struct {
+		*iterator
+		seekable sortedkv.SeekableIterator
+	}
+)
+
+// newIterator wraps it. The iterator is attributed to the table of start.
+// The returned iterator is seekable if it is.
+func newIterator(it sortedkv.Iterator, m *meter, start string) sortedkv.Iterator {
+	mit := &iterator{
+		Iterator: it,
+		m:        m,
+		event:    Event{Op: OpIterator, Table: m.table(start), Start: time.Now()},
+	}
+	if seekable, ok := it.(sortedkv.SeekableIterator); ok {
+		return &seekableIterator{iterator: mit, seekable: seekable}
+	}
+	return mit
+}
+
+// Next moves the iterator to the next entry.
+func (i *iterator) Next() bool {
+	return i.visit(i.Iterator.Next())
+}
+
+// visit counts the current entry if ok is true.
+func (i *iterator) visit(ok bool) bool {
+	if ok {
+		i.event.Keys++
+		i.event.BytesRead += len(i.Iterator.Key()) + len(i.Iterator.ValueBytes())
+	}
+	return ok
+}
+
+// Close closes the underlying iterator. The first call records the
+// iterator.
+func (i *iterator) Close() error {
+	err := i.Iterator.Close()
+	if !i.closed {
+		i.closed = true
+		i.event.Err = err
+		i.m.record(i.event)
+	}
+	return err
+}
+
+// Seek moves the iterator to the first entry whose key is greater than or
+// equal to key.
+func (i *seekableIterator) Seek(key string) bool {
+	return i.visit(i.seekable.Seek(key))
+}
+
+// Prev moves the iterator to the previous entry.
+func (i *seekableIterator) Prev() bool {
+	return i.visit(i.seekable.Prev())
+}
+
+// First moves the iterator to the first entry.
+func (i *seekableIterator) First() bool {
+	return i.visit(i.seekable.First())
+}
+
+// Last moves the iterator to the last entry.
+func (i *seekableIterator) Last() bool {
+	return i.visit(i.seekable.Last())
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// Memory is a Recorder that keeps all events and aggregates them per table
+	// and operation. Since it never forgets events, it is mostly useful in
+	// tests.
+	Memory struct {
+		mu     sync.Mutex
+		events []Event
+		stats  map[statsKey]*Stats
+	}
+
+	// Stats are aggregated events.
+	Stats struct {
+		Count    int           // Number of events.
+		Errors   int           // Number of failed operations, not counting NotFound.
+		NotFound int           // Number of operations that failed with NotFound.
+		Keys     int           // Sum of Event.Keys.
+		Duration time.Duration // Sum of Event.Duration.
+
+		BytesRead    int64
+		BytesWritten int64
+	}
+
+	statsKey struct {
+		table string
+		op    Op
+	}
+)
+
+// NewMemory creates an empty Memory recorder.
+func NewMemory() *Memory {
+	return &Memory{stats: make(map[statsKey]*Stats)}
+}
+
+// Record records an event.
+func (m *Memory) Record(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, e)
+	s, ok := m.stats[statsKey{e.Table, e.Op}]
+	if !ok {
+		s = new(Stats)
+		m.stats[statsKey{e.Table, e.Op}] = s
+	}
+	s.add(e)
+}
+
+// Events returns all recorded events in the order in which they were
+// recorded.
+func (m *Memory) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Event(nil), m.events...)
+}
+
+// Stats returns the aggregated events of an operation on a table.
+func (m *Memory) Stats(table string, op Op) Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.stats[statsKey{table, op}]; ok {
+		return *s
+	}
+	return Stats{}
+}
+
+// Total returns the aggregated events of an operation on all tables.
+func (m *Memory) Total(op Op) Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total Stats
+	for k, s := range m.stats {
+		if k.op == op {
+			total.merge(*s)
+		}
+	}
+	return total
+}
+
+// Tables returns the sorted tables of all recorded events.
+func (m *Memory) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool)
+	var tables []string
+	for k := range m.stats {
+		if !seen[k.table] {
+			seen[k.table] = true
+			tables = append(tables, k.table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// Reset forgets all recorded events.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = nil
+	m.stats = make(map[statsKey]*Stats)
+}
+
+// ErrorRate returns the fraction of failed operations, not counting NotFound.
+func (s Stats) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Count)
+}
+
+func (s *Stats) add(e Event) {
+	s.Count++
+	if sortedkv.IsNotFound(e.Err) {
+		s.NotFound++
+	} else if e.Err != nil {
+		s.Errors++
+	}
+	s.Keys += e.Keys
+	s.Duration += e.Duration
+	s.BytesRead += int64(e.BytesRead)
+	s.BytesWritten += int64(e.BytesWritten)
+}
+
+func (s *Stats) merge(o Stats) {
+	s.Count += o.Count
+	s.Errors += o.Errors
+	s.NotFound += o.NotFound
+	s.Keys += o.Keys
+	s.Duration += o.Duration
+	s.BytesRead += o.BytesRead
+	s.BytesWritten += o.BytesWritten
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func newDatabase() (*Database, *Memory) {
+	rec := NewMemory()
+	return New(memorydb.NewDatabase(), rec, WithTables("Table.", "Table.Inner.", "table")), rec
+}
+
+func TestGeneric(t *testing.T) {
+	test.GenericDecoratorTest(t, func() sortedkv.Database {
+		db, _ := newDatabase()
+		return db
+	})
+}
+
+func TestCompact(t *testing.T) {
+	db, _ := newDatabase()
+	test.GenericCompactTest(t, db)
+}
+
+func TestWatch(t *testing.T) {
+	db, _ := newDatabase()
+	_, err := db.Watch(context.Background(), "")
+	assert.True(t, sortedkv.IsNotSupported(err))
+
+	rec := NewMemory()
+	test.GenericWatchTest(t, sortedkv.NewWatchable(New(memorydb.NewDatabase(), rec)))
+	assert.NotZero(t, rec.Total(OpPut).Count)
+
+	db = New(sortedkv.NewWatchable(memorydb.NewDatabase()), rec)
+	events, err := db.Watch(context.Background(), "")
+	require.NoError(t, err)
+	require.NoError(t, db.Put("k", "v"))
+	assert.Equal(t, sortedkv.Event{Type: sortedkv.EventPut, Key: "k", Value: "v"}, <-events)
+}
+
+func TestDatabase_Record(t *testing.T) {
+	db, rec := newDatabase()
+	users := sortedkv.NewTable(db, "Table.")
+	inner := sortedkv.NewTable(users, "Inner.")
+
+	require.NoError(t, users.Put("a", "123"))
+	require.NoError(t, inner.PutBytes("b", []byte("45")))
+	require.NoError(t, db.Put("other", "6"))
+	v, err := users.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "123", v)
+	_, err = users.Get("missing")
+	assert.True(t, sortedkv.IsNotFound(err))
+	assert.Error(t, users.Delete("missing"))
+	require.NoError(t, inner.Delete("b"))
+	has, err := db.Has("other")
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	assert.Equal(t, []string{"", "Table.", "Table.Inner."}, rec.Tables())
+	assert.Equal(t, Stats{Count: 1, Keys: 1, BytesWritten: int64(len("Table.a123"))},
+		withoutDuration(rec.Stats("Table.", OpPut)))
+	assert.Equal(t, Stats{Count: 1, Keys: 1, BytesWritten: int64(len("Table.Inner.b45"))},
+		withoutDuration(rec.Stats("Table.Inner.", OpPut)))
+	assert.Equal(t, Stats{Count: 2, NotFound: 1, Keys: 2, BytesRead: 3},
+		withoutDuration(rec.Stats("Table.", OpGet)))
+	assert.Equal(t, 1, rec.Stats("Table.", OpDelete).NotFound)
+	assert.Equal(t, 1, rec.Stats("Table.Inner.", OpDelete).Count)
+	assert.Equal(t, 1, rec.Stats("", OpHas).Count)
+	assert.Equal(t, 3, rec.Total(OpPut).Count)
+	assert.Zero(t, rec.Total(OpGet).ErrorRate())
+
+	require.NoError(t, db.Close())
+	_, err = db.Get("other")
+	assert.True(t, sortedkv.IsClosed(err))
+	assert.Equal(t, 1, rec.Stats("", OpGet).Errors)
+	assert.Equal(t, 1.0, rec.Stats("", OpGet).ErrorRate())
+
+	rec.Reset()
+	assert.Empty(t, rec.Events())
+	assert.Empty(t, rec.Tables())
+}
+
+func TestBatch_Record(t *testing.T) {
+	db, rec := newDatabase()
+	b := db.NewBatch()
+	require.NoError(t, b.Put("Table.a", "1"))
+	require.NoError(t, b.Put("Table.b", "2"))
+	require.NoError(t, b.Delete("x"))
+	require.NoError(t, b.Apply())
+
+	events := rec.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, OpBatch, events[0].Op)
+	assert.Equal(t, "Table.", events[0].Table)
+	assert.Equal(t, 2, events[0].Keys)
+	assert.Equal(t, 2*len("Table.a1"), events[0].BytesWritten)
+	assert.Equal(t, "", events[1].Table)
+	assert.Equal(t, 1, events[1].Keys)
+	assert.Equal(t, events[0].Start, events[1].Start)
+
+	rec.Reset()
+	b.Reset()
+	require.NoError(t, b.Apply())
+	assert.Equal(t, []Event{{Op: OpBatch, Start: rec.Events()[0].Start, Duration: rec.Events()[0].Duration}}, rec.Events())
+}
+
+func TestDatabase_RecordRangeDelete(t *testing.T) {
+	db, rec := newDatabase()
+	require.NoError(t, db.Put("Table.a", "1"))
+	require.NoError(t, db.Put("Table.b", "2"))
+	require.NoError(t, db.Put("x", "3"))
+	rec.Reset()
+
+	// Range deletions count no keys and are attributed to the table of their
+	// start, even if they span several tables.
+	require.NoError(t, db.DeleteRange("Table.", ""))
+	require.NoError(t, db.DeletePrefix("Table."))
+	assert.Equal(t, Stats{Count: 2, BytesWritten: int64(2 * len("Table."))},
+		withoutDuration(rec.Stats("Table.", OpDeleteRange)))
+	assert.Zero(t, rec.Stats("", OpDeleteRange).Count)
+
+	rec.Reset()
+	b := db.NewBatch()
+	require.NoError(t, b.Put("x", "4"))
+	require.NoError(t, b.(sortedkv.RangeDeleter).DeletePrefix("Table."))
+	require.NoError(t, b.Apply())
+	assert.Equal(t, 0, rec.Stats("Table.", OpBatch).Keys)
+	assert.Equal(t, 1, rec.Stats("", OpBatch).Keys)
+}
+
+func TestIterator_Record(t *testing.T) {
+	db, rec := newDatabase()
+	require.NoError(t, db.Put("Table.a", "1"))
+	require.NoError(t, db.Put("Table.b", "22"))
+	require.NoError(t, db.Put("c", "3"))
+	rec.Reset()
+
+	it := db.NewIteratorWithPrefix("Table.")
+	for it.Next() {
+	}
+	time.Sleep(time.Millisecond)
+	assert.Empty(t, rec.Events(), "iterator recorded before Close")
+	require.NoError(t, it.Close())
+	require.NoError(t, it.Close())
+
+	events := rec.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, OpIterator, events[0].Op)
+	assert.Equal(t, "Table.", events[0].Table)
+	assert.Equal(t, 2, events[0].Keys)
+	assert.Equal(t, len("Table.a1Table.b22"), events[0].BytesRead)
+	assert.GreaterOrEqual(t, events[0].Duration, time.Millisecond, "lifetime")
+
+	sit, ok := db.NewIterator().(sortedkv.SeekableIterator)
+	require.True(t, ok)
+	assert.True(t, sit.Last())
+	assert.True(t, sit.Seek("Table.b"))
+	require.NoError(t, sit.Close())
+	assert.Equal(t, 2, rec.Stats("", OpIterator).Keys)
+}
+
+func TestTransaction_Record(t *testing.T) {
+	db, rec := newDatabase()
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx.Put("Table.a", "1"))
+	assert.Error(t, tx.Delete("Table.missing"))
+	_, err = tx.Get("Table.a")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	assert.Equal(t, 1, rec.Stats("Table.", OpPut).Count)
+	assert.Equal(t, 1, rec.Stats("Table.", OpDelete).NotFound)
+	assert.Equal(t, 1, rec.Stats("Table.", OpGet).Count)
+	assert.Equal(t, Stats{Count: 1, Keys: 1, BytesWritten: int64(len("Table.a1"))},
+		withoutDuration(rec.Stats("Table.", OpCommit)))
+
+	s, err := db.NewSnapshot()
+	require.NoError(t, err)
+	_, err = s.Get("Table.a")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	assert.Equal(t, 1, rec.Stats("", OpSnapshot).Count)
+	assert.Equal(t, 2, rec.Stats("Table.", OpGet).Count)
+}
+
+func TestOp_String(t *testing.T) {
+	assert.Equal(t, "Commit", OpCommit.String())
+	assert.Equal(t, "Op(42)", Op(42).String())
+}
+
+func withoutDuration(s Stats) Stats {
+	s.Duration = 0
+	return s
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// reader records the reads of a database, snapshot or transaction.
+type reader struct {
+	r  sortedkv.Reader
+	it sortedkv.Iterable
+	m  *meter
+}
+
+// Has returns whether the key exists.
+func (r *reader) Has(key string) (bool, error) {
+	start := time.Now()
+	has, err := r.r.Has(key)
+	r.m.record(Event{Op: OpHas, Table: r.m.table(key), Start: start, Keys: 1, Err: err})
+	return has, err
+}
+
+// Get returns the value of a key.
+func (r *reader) Get(key string) (string, error) {
+	start := time.Now()
+	value, err := r.r.Get(key)
+	r.m.record(Event{Op: OpGet, Table: r.m.table(key), Start: start, Keys: 1, BytesRead: len(value), Err: err})
+	return value, err
+}
+
+// GetBytes returns the value of a key.
+func (r *reader) GetBytes(key string) ([]byte, error) {
+	start := time.Now()
+	value, err := r.r.GetBytes(key)
+	r.m.record(Event{Op: OpGet, Table: r.m.table(key), Start: start, Keys: 1, BytesRead: len(value), Err: err})
+	return value, err
+}
+
+// NewIterator creates an iterator over all keys.
+func (r *reader) NewIterator() sortedkv.Iterator {
+	return newIterator(r.it.NewIterator(), r.m, "")
+}
+
+// NewIteratorWithRange creates an iterator over the range [start, end). It is
+// attributed to the table of start.
+func (r *reader) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return newIterator(r.it.NewIteratorWithRange(start, end), r.m, start)
+}
+
+// NewIteratorWithPrefix creates an iterator over the keys with the given
+// prefix.
+func (r *reader) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return newIterator(r.it.NewIteratorWithPrefix(prefix), r.m, prefix)
+}
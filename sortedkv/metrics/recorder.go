@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Op is the type of a recorded operation.
+type Op int
+
+const (
+	// OpHas is a call to Has.
+	OpHas Op = iota
+	// OpGet is a call to Get or GetBytes.
+	OpGet
+	// OpPut is a call to Put or PutBytes.
+	OpPut
+	// OpDelete is a call to Delete.
+	OpDelete
+	// OpDeleteRange is a call to DeleteRange or DeletePrefix.
+	OpDeleteRange
+	// OpBatch is the application of a batch.
+	OpBatch
+	// OpIterator is the lifetime of an iterator, from its creation until it is
+	// closed.
+	OpIterator
+	// OpSnapshot is the creation of a snapshot.
+	OpSnapshot
+	// OpCommit is the commit of a transaction.
+	OpCommit
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	switch op {
+	case OpHas:
+		return "Has"
+	case OpGet:
+		return "Get"
+	case OpPut:
+		return "Put"
+	case OpDelete:
+		return "Delete"
+	case OpDeleteRange:
+		return "DeleteRange"
+	case OpBatch:
+		return "Batch"
+	case OpIterator:
+		return "Iterator"
+	case OpSnapshot:
+		return "Snapshot"
+	case OpCommit:
+		return "Commit"
+	default:
+		return "Op(" + strconv.Itoa(int(op)) + ")"
+	}
+}
+
+// Event is a recorded operation.
+type Event struct {
+	Op    Op
+	Table string // The table of the accessed keys.
+
+	Start    time.Time
+	Duration time.Duration
+
+	// Keys is the number of keys that were accessed: 1 for point operations,
+	// the number of writes for batches and commits and the number of visited
+	// entries for iterators. Range deletions count no keys, since the number
+	// of deleted keys is unknown.
+	Keys int
+	// BytesRead is the size of the read values, and additionally of the keys
+	// for iterators.
+	BytesRead int
+	// BytesWritten is the size of the written keys and values.
+	BytesWritten int
+
+	// Err is the error of the operation, if any.
+	Err error
+}
+
+// Recorder records events. It must be safe for concurrent use.
+type Recorder interface {
+	Record(Event)
+}
+
+// RecorderFunc is a function that implements Recorder.
+type RecorderFunc func(Event)
+
+// Record calls f.
+func (f RecorderFunc) Record(e Event) {
+	f(e)
+}
@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import "polycry.pt/poly-go/sortedkv"
+
+// snapshot records the reads of an underlying snapshot.
+type snapshot struct {
+	reader
+	s sortedkv.Snapshot
+}
+
+// Close closes the underlying snapshot.
+func (s *snapshot) Close() error {
+	return s.s.Close()
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// transaction records the operations of an underlying transaction. Its
+// writes are recorded individually and, per table, when they are committed.
+type transaction struct {
+	reader
+	tx     sortedkv.Transaction
+	writes writeSet
+}
+
+// Put saves a value under a key.
+func (t *transaction) Put(key string, value string) error {
+	return t.write(OpPut, key, len(key)+len(value), func() error { return t.tx.Put(key, value) })
+}
+
+// PutBytes saves a value under a key.
+func (t *transaction) PutBytes(key string, value []byte) error {
+	return t.write(OpPut, key, len(key)+len(value), func() error { return t.tx.PutBytes(key, value) })
+}
+
+// Delete deletes a key.
+func (t *transaction) Delete(key string) error {
+	return t.write(OpDelete, key, len(key), func() error { return t.tx.Delete(key) })
+}
+
+// write records a write of the given size and counts it for the commit if it
+// succeeded.
+func (t *transaction) write(op Op, key string, size int, f func() error) error {
+	start := time.Now()
+	err := f()
+	table := t.m.table(key)
+	t.m.record(Event{Op: op, Table: table, Start: start, Keys: 1, BytesWritten: size, Err: err})
+	if err == nil {
+		t.writes.add(table, 1, size)
+	}
+	return err
+}
+
+// Commit commits the underlying transaction. It records an event for every
+// table that the transaction writes to.
+func (t *transaction) Commit() error {
+	start := time.Now()
+	err := t.tx.Commit()
+	t.writes.record(t.m, OpCommit, start, err)
+	t.writes = writeSet{}
+	return err
+}
+
+// Discard discards the underlying transaction.
+func (t *transaction) Discard() {
+	t.tx.Discard()
+	t.writes = writeSet{}
+}
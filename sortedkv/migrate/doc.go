@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package migrate versions the schema of sortedkv tables and migrates their
+// entries between versions.
+//
+// The schema of a table is described by an ordered list of Steps. Step i
+// migrates the entries of the table from version i to version i+1, so the
+// latest version of a table is the number of steps. A new table has version 0.
+// Open migrates a table to the latest version and refuses to open tables whose
+// version is newer than the steps, which were written by newer code.
+//
+// Steps are applied entry by entry in batches. Every batch atomically writes
+// the migrated entries together with the progress of the step, so that an
+// interrupted migration continues after the last applied batch and every entry
+// is migrated exactly once. Migrations must not run concurrently with other
+// writes to the table.
+//
+// The versions and the progress are stored in a metadata entry per table, with
+// keys starting with DefaultMetaPrefix or the prefix set by WithMetaPrefix.
+// Metadata entries that lie within a migrated table are skipped.
+package migrate // import "polycry.pt/poly-go/sortedkv/migrate"
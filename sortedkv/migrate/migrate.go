@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+const (
+	// DefaultMetaPrefix is the default key prefix of the metadata entries.
+	DefaultMetaPrefix = "\x00migrate."
+	// DefaultBatchSize is the default number of entries per batch.
+	DefaultBatchSize = 256
+)
+
+type (
+	// Step migrates a table from one version to the next.
+	Step struct {
+		// Name describes the step in errors.
+		Name string
+		// Migrate is called for every entry of the table with a key relative to
+		// the table. It rewrites the entry, if necessary, by writing to w,
+		// whose keys are relative to the table as well. Keys that Migrate puts
+		// after the current key are migrated by the step again.
+		Migrate func(key string, value []byte, w sortedkv.Writer) error
+	}
+
+	// Option configures a migration.
+	Option func(*options)
+
+	options struct {
+		metaPrefix string
+		batchSize  int
+	}
+
+	// VersionError is returned when a table has a newer version than the
+	// steps support.
+	VersionError struct {
+		Table   string
+		Version uint64 // Version of the table.
+		Latest  uint64 // Latest version that the steps support.
+	}
+
+	// state is the stored version of a table and the progress of the running
+	// step, if any.
+	state struct {
+		version uint64
+		running bool
+		cursor  string // Last migrated key of the running step.
+	}
+)
+
+// WithMetaPrefix sets the key prefix of the metadata entries.
+func WithMetaPrefix(prefix string) Option {
+	return func(o *options) { o.metaPrefix = prefix }
+}
+
+// WithBatchSize sets the number of entries that are migrated per batch.
+func WithBatchSize(n int) Option {
+	return func(o *options) { o.batchSize = n }
+}
+
+func newOptions(opts []Option) options {
+	o := options{metaPrefix: DefaultMetaPrefix, batchSize: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Error returns the error string.
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("migrate: table %q has version %d, but only versions up to %d are supported",
+		e.Table, e.Version, e.Latest)
+}
+
+// Open migrates the table with the given prefix to the latest version and
+// returns it. It fails with a *VersionError if the table is newer than the
+// steps.
+func Open(db sortedkv.Database, prefix string, steps []Step, opts ...Option) (sortedkv.Database, error) {
+	if err := Migrate(db, prefix, steps, opts...); err != nil {
+		return nil, err
+	}
+	return sortedkv.NewTable(db, prefix), nil
+}
+
+// Version returns the version of the table with the given prefix and whether
+// a migration step was interrupted.
+func Version(db sortedkv.Reader, prefix string, opts ...Option) (version uint64, interrupted bool, err error) {
+	o := newOptions(opts)
+	s, err := loadState(db, o.metaPrefix+prefix)
+	return s.version, s.running, err
+}
+
+// Migrate migrates the table with the given prefix to the latest version. An
+// interrupted migration is continued. It fails with a *VersionError if the
+// table is newer than the steps.
+func Migrate(db sortedkv.Database, prefix string, steps []Step, opts ...Option) error {
+	o := newOptions(opts)
+	if o.batchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	m := &migration{db: db, prefix: prefix, metaKey: o.metaPrefix + prefix, o: o}
+	s, err := loadState(db, m.metaKey)
+	if err != nil {
+		return err
+	}
+	if latest := uint64(len(steps)); s.version > latest {
+		return &VersionError{Table: prefix, Version: s.version, Latest: latest}
+	}
+
+	for ; s.version < uint64(len(steps)); s = (state{version: s.version + 1}) {
+		step := steps[s.version]
+		if err := m.run(step, s); err != nil {
+			return errors.WithMessagef(err, "migrating table %q to version %d (%s)", prefix, s.version+1, step.Name)
+		}
+	}
+	return nil
+}
+
+// migration migrates a table.
+type migration struct {
+	db      sortedkv.Database
+	prefix  string
+	metaKey string
+	o       options
+}
+
+// run applies a step in batches, starting after the cursor of s if the step
+// is running already.
+func (m *migration) run(step Step, s state) error {
+	start := m.prefix
+	if s.running {
+		start = key.Next(m.prefix + s.cursor)
+	}
+	for {
+		entries, err := m.read(start)
+		if err != nil {
+			return err
+		}
+
+		batch := m.db.NewBatch()
+		w := sortedkv.TableBatch(batch, m.prefix)
+		for _, e := range entries {
+			if err := step.Migrate(e.key[len(m.prefix):], e.value, w); err != nil {
+				return errors.WithMessagef(err, "migrating key %q", e.key[len(m.prefix):])
+			}
+		}
+
+		done := len(entries) < m.o.batchSize
+		next := state{version: s.version, running: true}
+		if done {
+			next = state{version: s.version + 1}
+		} else {
+			last := entries[len(entries)-1].key
+			next.cursor = last[len(m.prefix):]
+			start = key.Next(last)
+		}
+		if err := batch.PutBytes(m.metaKey, next.encode()); err != nil {
+			return err
+		}
+		if err := batch.Apply(); err != nil {
+			return errors.WithMessage(err, "applying batch")
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+type entry struct {
+	key   string
+	value []byte
+}
+
+// read returns up to one batch of entries of the table, starting at start.
+// Metadata entries are skipped.
+func (m *migration) read(start string) ([]entry, error) {
+	it := m.db.NewIteratorWithRange(start, key.IncPrefix(m.prefix))
+	defer it.Close()
+
+	var entries []entry
+	for len(entries) < m.o.batchSize && it.Next() {
+		if strings.HasPrefix(it.Key(), m.o.metaPrefix) {
+			continue
+		}
+		value := append([]byte(nil), it.ValueBytes()...)
+		entries = append(entries, entry{key: it.Key(), value: value})
+	}
+	return entries, errors.WithMessage(it.Close(), "reading table")
+}
+
+// loadState loads the state of a table. Tables without a state have version
+// 0.
+func loadState(db sortedkv.Reader, metaKey string) (state, error) {
+	data, err := db.GetBytes(metaKey)
+	if sortedkv.IsNotFound(err) {
+		return state{}, nil
+	} else if err != nil {
+		return state{}, errors.WithMessage(err, "loading schema version")
+	}
+	return decodeState(data)
+}
+
+// encode encodes the state as the version as big-endian uint64, followed by
+// the cursor if the step is running.
+func (s state) encode() []byte {
+	data := make([]byte, 8, 8+len(s.cursor)+1)
+	binary.BigEndian.PutUint64(data, s.version)
+	if s.running {
+		data = append(data, 1)
+		data = append(data, s.cursor...)
+	}
+	return data
+}
+
+func decodeState(data []byte) (state, error) {
+	if len(data) < 8 || (len(data) > 8 && data[8] != 1) {
+		return state{}, &sortedkv.CorruptedError{Err: errors.New("invalid schema version entry")}
+	}
+	s := state{version: binary.BigEndian.Uint64(data)}
+	if len(data) > 8 {
+		s.running, s.cursor = true, string(data[9:])
+	}
+	return s, nil
+}
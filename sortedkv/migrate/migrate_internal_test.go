@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+// appendStep appends suffix to every value. It is not idempotent, so applying
+// it twice to an entry is detected.
+func appendStep(suffix string) Step {
+	return Step{
+		Name: "append " + suffix,
+		Migrate: func(key string, value []byte, w sortedkv.Writer) error {
+			return w.Put(key, string(value)+suffix)
+		},
+	}
+}
+
+func fill(t *testing.T, db sortedkv.Writer, prefix string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.Put(fmt.Sprintf("%s%03d", prefix, i), "v"))
+	}
+}
+
+func requireValues(t *testing.T, db sortedkv.Reader, prefix string, n int, value string) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		v, err := db.Get(fmt.Sprintf("%s%03d", prefix, i))
+		require.NoError(t, err)
+		require.Equal(t, value, v, "key %d", i)
+	}
+}
+
+func requireVersion(t *testing.T, db sortedkv.Reader, prefix string, version uint64, interrupted bool, opts ...Option) {
+	t.Helper()
+	v, i, err := Version(db, prefix, opts...)
+	require.NoError(t, err)
+	require.Equal(t, version, v)
+	require.Equal(t, interrupted, i)
+}
+
+func TestMigrate(t *testing.T) {
+	db := memorydb.NewDatabase()
+	fill(t, db, "t/", 10)
+	fill(t, db, "u/", 3)
+	steps := []Step{appendStep("1"), appendStep("2")}
+
+	requireVersion(t, db, "t/", 0, false)
+	require.NoError(t, Migrate(db, "t/", steps, WithBatchSize(3)))
+	requireValues(t, db, "t/", 10, "v12")
+	requireValues(t, db, "u/", 3, "v")
+	requireVersion(t, db, "t/", 2, false)
+	requireVersion(t, db, "u/", 0, false)
+
+	// Migrated tables are not migrated again.
+	require.NoError(t, Migrate(db, "t/", steps))
+	requireValues(t, db, "t/", 10, "v12")
+
+	// New steps are applied on top.
+	require.NoError(t, Migrate(db, "t/", append(steps, appendStep("3"))))
+	requireValues(t, db, "t/", 10, "v123")
+	requireVersion(t, db, "t/", 3, false)
+}
+
+func TestMigrate_BatchBoundary(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 6, 7} {
+		db := memorydb.NewDatabase()
+		fill(t, db, "t/", n)
+		require.NoError(t, Migrate(db, "t/", []Step{appendStep("1")}, WithBatchSize(3)))
+		requireValues(t, db, "t/", n, "v1")
+		requireVersion(t, db, "t/", 1, false)
+	}
+}
+
+func TestMigrate_Interrupted(t *testing.T) {
+	db := memorydb.NewDatabase()
+	fill(t, db, "t/", 10)
+
+	errFail := errors.New("interrupted")
+	calls := 0
+	failing := Step{
+		Name: "failing",
+		Migrate: func(key string, value []byte, w sortedkv.Writer) error {
+			if calls++; calls == 5 {
+				return errFail
+			}
+			return w.Put(key, string(value)+"2")
+		},
+	}
+	steps := []Step{appendStep("1"), failing}
+
+	// The first step is completed and the second one fails in the second
+	// batch, so the first batch of the second step stays applied.
+	err := Migrate(db, "t/", steps, WithBatchSize(3))
+	require.True(t, errors.Is(err, errFail))
+	assert.Contains(t, err.Error(), "version 2 (failing)")
+	requireVersion(t, db, "t/", 1, true)
+	(&test.DatabaseTest{T: t, Database: db}).MustGetEqual("t/002", "v12")
+	(&test.DatabaseTest{T: t, Database: db}).MustGetEqual("t/003", "v1")
+
+	// The resumed migration does not apply the step again to the first batch.
+	require.NoError(t, Migrate(db, "t/", steps, WithBatchSize(3)))
+	requireValues(t, db, "t/", 10, "v12")
+	requireVersion(t, db, "t/", 2, false)
+}
+
+// failingBatchDB fails to apply the n-th batch.
+type failingBatchDB struct {
+	sortedkv.Database
+	n int
+}
+
+var errApply = errors.New("apply failed")
+
+type failingBatch struct {
+	sortedkv.Batch
+	fail bool
+}
+
+func (db *failingBatchDB) NewBatch() sortedkv.Batch {
+	db.n--
+	return &failingBatch{Batch: db.Database.NewBatch(), fail: db.n == 0}
+}
+
+func (b *failingBatch) Apply() error {
+	if b.fail {
+		return errApply
+	}
+	return b.Batch.Apply()
+}
+
+func TestMigrate_InterruptedApply(t *testing.T) {
+	for n := 1; n <= 5; n++ {
+		mem := memorydb.NewDatabase()
+		fill(t, mem, "t/", 8)
+		steps := []Step{appendStep("1"), appendStep("2")}
+
+		// With 8 entries and batches of 4, each step takes 3 batches.
+		err := Migrate(&failingBatchDB{Database: mem, n: n}, "t/", steps, WithBatchSize(4))
+		require.True(t, errors.Is(err, errApply), "batch %d", n)
+		requireVersion(t, mem, "t/", uint64((n-1)/3), (n-1)%3 != 0)
+
+		require.NoError(t, Migrate(mem, "t/", steps, WithBatchSize(4)))
+		requireValues(t, mem, "t/", 8, "v12")
+		requireVersion(t, mem, "t/", 2, false)
+	}
+}
+
+func TestMigrate_Rewrite(t *testing.T) {
+	db := memorydb.NewDatabase()
+	fill(t, db, "t/", 5)
+
+	// Moves all entries to keys before the current one and drops odd ones.
+	rename := Step{
+		Name: "rename",
+		Migrate: func(key string, value []byte, w sortedkv.Writer) error {
+			if err := w.Delete(key); err != nil {
+				return err
+			}
+			if key[len(key)-1]%2 == 1 {
+				return nil
+			}
+			return w.PutBytes("-"+key, value)
+		},
+	}
+	require.NoError(t, Migrate(db, "t/", []Step{rename}, WithBatchSize(2)))
+
+	it := db.NewIteratorWithPrefix("t/")
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	require.NoError(t, it.Close())
+	assert.Equal(t, []string{"t/-000", "t/-002", "t/-004"}, keys)
+}
+
+func TestMigrate_EmptyPrefix(t *testing.T) {
+	db := memorydb.NewDatabase()
+	fill(t, db, "", 5)
+	steps := []Step{appendStep("1"), appendStep("2")}
+
+	// The metadata entry of the table lies within the table and is skipped.
+	require.NoError(t, Migrate(db, "", steps, WithBatchSize(2)))
+	requireValues(t, db, "", 5, "v12")
+	requireVersion(t, db, "", 2, false)
+
+	db = memorydb.NewDatabase()
+	fill(t, db, "", 5)
+	require.NoError(t, Migrate(db, "", steps, WithBatchSize(2), WithMetaPrefix("meta/")))
+	requireValues(t, db, "", 5, "v12")
+	requireVersion(t, db, "", 2, false, WithMetaPrefix("meta/"))
+}
+
+func TestOpen(t *testing.T) {
+	db := memorydb.NewDatabase()
+	fill(t, db, "t/", 3)
+	steps := []Step{appendStep("1")}
+
+	table, err := Open(db, "t/", steps)
+	require.NoError(t, err)
+	v, err := table.Get("001")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", v)
+
+	// Tables of newer code are refused.
+	_, err = Open(db, "t/", nil)
+	var verr *VersionError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, VersionError{Table: "t/", Version: 1, Latest: 0}, *verr)
+	requireValues(t, db, "t/", 3, "v1")
+
+	// Interrupted migrations of newer code are refused, too.
+	require.NoError(t, db.Put(DefaultMetaPrefix+"t/", string(state{version: 1, running: true}.encode())))
+	require.True(t, errors.As(Migrate(db, "t/", nil), &verr))
+}
+
+func TestMigrate_Errors(t *testing.T) {
+	db := memorydb.NewDatabase()
+	assert.Error(t, Migrate(db, "t/", nil, WithBatchSize(0)))
+
+	require.NoError(t, db.Put(DefaultMetaPrefix+"t/", "x"))
+	_, err := Open(db, "t/", nil)
+	assert.True(t, sortedkv.IsCorrupted(err))
+
+	require.NoError(t, db.Close())
+	assert.True(t, sortedkv.IsClosed(Migrate(db, "u/", nil)))
+}
+
+func TestState(t *testing.T) {
+	for _, s := range []state{{}, {version: 3}, {version: 1, running: true}, {version: 2, running: true, cursor: "a\x00b"}} {
+		d, err := decodeState(s.encode())
+		require.NoError(t, err)
+		assert.Equal(t, s, d)
+	}
+	for _, data := range []string{"", "1234567", "12345678\x02"} {
+		_, err := decodeState([]byte(data))
+		assert.True(t, sortedkv.IsCorrupted(err))
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package overlay
+
+import (
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+// Batch is a batch of an overlay. It implements sortedkv.Batch and
+// sortedkv.RangeDeleter.
+type Batch struct {
+	db  *Database
+	ops []batchOp
+}
+
+// batchOp is a buffered operation of a batch.
+type batchOp struct {
+	key, value string
+	end        string // End of the range of a range deletion.
+	kind       batchOpKind
+}
+
+type batchOpKind int
+
+const (
+	opPut batchOpKind = iota
+	opDelete
+	opDeleteRange
+)
+
+// Put puts a value into the batch.
+func (b *Batch) Put(key string, value string) error {
+	b.ops = append(b.ops, batchOp{kind: opPut, key: key, value: value})
+	return nil
+}
+
+// PutBytes puts a value into the batch.
+func (b *Batch) PutBytes(key string, value []byte) error {
+	return b.Put(key, string(value))
+}
+
+// Delete adds the deletion of a key to the batch.
+func (b *Batch) Delete(key string) error {
+	b.ops = append(b.ops, batchOp{kind: opDelete, key: key})
+	return nil
+}
+
+// DeleteRange adds the deletion of all keys in the range [start, end) to the
+// batch.
+func (b *Batch) DeleteRange(start string, end string) error {
+	b.ops = append(b.ops, batchOp{kind: opDeleteRange, key: start, end: end})
+	return nil
+}
+
+// DeletePrefix adds the deletion of all keys with the given prefix to the
+// batch.
+func (b *Batch) DeletePrefix(prefix string) error {
+	return b.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Apply applies the batch to the overlay atomically. Deleting keys that are
+// not present is not an error.
+func (b *Batch) Apply() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+
+	top := b.db.top.NewBatch()
+	for _, op := range b.ops {
+		var err error
+		switch op.kind {
+		case opPut:
+			err = top.Put(op.key, encode(op.value))
+		case opDelete:
+			err = b.deleteKey(top, op.key)
+		case opDeleteRange:
+			err = b.deleteRange(top, op.key, op.end)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return top.Apply()
+}
+
+// deleteKey writes a tombstone for key to the top layer if the key is in the
+// base, and deletes it otherwise.
+func (b *Batch) deleteKey(top sortedkv.Batch, key string) error {
+	inBase, err := b.db.base.Has(key)
+	if err != nil {
+		return errors.WithMessage(err, "reading base")
+	}
+	if inBase {
+		return top.Put(key, tombstone)
+	}
+	return top.Delete(key)
+}
+
+// deleteRange deletes the range [start, end) from the top layer and writes
+// tombstones for all keys of the base in the range.
+func (b *Batch) deleteRange(top sortedkv.Batch, start, end string) error {
+	if err := top.(sortedkv.RangeDeleter).DeleteRange(start, end); err != nil {
+		return err
+	}
+	it := b.db.base.NewIteratorWithRange(start, end)
+	defer it.Close()
+	for it.Next() {
+		if err := top.Put(it.Key(), tombstone); err != nil {
+			return err
+		}
+	}
+	return errors.WithMessage(it.Close(), "reading base")
+}
+
+// Reset resets the batch.
+func (b *Batch) Reset() {
+	b.ops = nil
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package overlay provides a writable database on top of a read-only base.
+// All writes go to an in-memory top layer and the base is never modified.
+// Deleted keys of the base are hidden by tombstones in the top layer, so the
+// overlay shows the base as if the writes had been made to it. This makes an
+// overlay a scratch area for dry runs: its changes can be discarded with Reset
+// or written to a database with Commit.
+//
+// The base must not change while it is used by an overlay.
+package overlay // import "polycry.pt/poly-go/sortedkv/overlay"
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+)
+
+type (
+	// Base is the read-only base of an overlay. Databases and snapshots are
+	// bases.
+	Base interface {
+		sortedkv.Reader
+		sortedkv.Iterable
+	}
+
+	// Database is a writable overlay over a read-only base. It implements
+	// sortedkv.Database, sortedkv.RangeDeleter and sortedkv.Snapshotter.
+	Database struct {
+		view
+		mu  sync.Mutex // Serializes writes with each other and with Commit and Reset.
+		top *memorydb.Database
+	}
+)
+
+// New creates an empty overlay over base.
+func New(base Base) *Database {
+	top := memorydb.NewDatabase().(*memorydb.Database)
+	return &Database{
+		view: view{top: top, base: base},
+		top:  top,
+	}
+}
+
+// Put saves a value under a key in the top layer.
+func (d *Database) Put(key string, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.top.Put(key, encode(value))
+}
+
+// PutBytes saves a value under a key in the top layer.
+func (d *Database) PutBytes(key string, value []byte) error {
+	return d.Put(key, string(value))
+}
+
+// Delete deletes a key. It fails with a *sortedkv.NotFoundError if the key is
+// not present in the overlay.
+func (d *Database) Delete(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok, err := d.lookup(key); err != nil {
+		return err
+	} else if !ok {
+		return &sortedkv.NotFoundError{Key: key}
+	}
+	inBase, err := d.base.Has(key)
+	if err != nil {
+		return errors.WithMessage(err, "reading base")
+	}
+	if inBase {
+		return d.top.Put(key, tombstone)
+	}
+	return d.top.Delete(key)
+}
+
+// DeleteRange deletes all keys in the range [start, end).
+func (d *Database) DeleteRange(start string, end string) error {
+	b := d.NewBatch()
+	if err := b.(*Batch).DeleteRange(start, end); err != nil {
+		return err
+	}
+	return b.Apply()
+}
+
+// DeletePrefix deletes all keys with the given prefix.
+func (d *Database) DeletePrefix(prefix string) error {
+	b := d.NewBatch()
+	if err := b.(*Batch).DeletePrefix(prefix); err != nil {
+		return err
+	}
+	return b.Apply()
+}
+
+// NewBatch creates a new batch.
+func (d *Database) NewBatch() sortedkv.Batch {
+	return &Batch{db: d}
+}
+
+// NewSnapshot creates a snapshot of the overlay. Taking a snapshot does not
+// copy any data.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	top, err := d.top.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{view: view{top: top, base: d.base}, top: top}, nil
+}
+
+// Commit writes the changes of the overlay atomically to db, using a single
+// batch, and then resets the overlay. If db is the base, the overlay shows the
+// same entries before and after the commit.
+func (d *Database) Commit(db sortedkv.Batcher) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	it := d.top.NewIterator()
+	defer it.Close()
+	b := db.NewBatch()
+	for it.Next() {
+		value, ok := decode(it.Value())
+		var err error
+		if ok {
+			err = b.Put(it.Key(), value)
+		} else {
+			err = b.Delete(it.Key())
+		}
+		if err != nil {
+			return errors.WithMessage(err, "writing batch")
+		}
+	}
+	if err := it.Close(); err != nil {
+		return errors.WithMessage(err, "reading changes")
+	}
+	if err := b.Apply(); err != nil {
+		return errors.WithMessage(err, "applying batch")
+	}
+	return d.top.DeleteRange("", "")
+}
+
+// Reset discards all changes of the overlay.
+func (d *Database) Reset() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.top.DeleteRange("", "")
+}
+
+// Close discards all changes of the overlay and closes it. The base is not
+// closed.
+func (d *Database) Close() error {
+	return d.close(d.top)
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package overlay
+
+import "polycry.pt/poly-go/sortedkv"
+
+// iterator merges the top layer with the base and skips tombstones.
+type iterator struct {
+	*sortedkv.MergeIterator
+}
+
+// Source indices of the merged iterators.
+const (
+	fromTop = iota
+	fromBase
+)
+
+func newMergedIterator(top, base sortedkv.Iterator) *iterator {
+	return &iterator{sortedkv.NewMergeIterator(sortedkv.FirstWins, top, base)}
+}
+
+// Next moves the iterator to the next entry that is not deleted.
+func (it *iterator) Next() bool {
+	for it.MergeIterator.Next() {
+		if it.Source() == fromBase {
+			return true
+		}
+		if _, ok := decode(it.MergeIterator.Value()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the value of the current entry, or "" if done.
+func (it *iterator) Value() string {
+	value := it.MergeIterator.Value()
+	if it.Source() == fromTop {
+		value, _ = decode(value)
+	}
+	return value
+}
+
+// ValueBytes returns the value of the current entry, or nil if done.
+func (it *iterator) ValueBytes() []byte {
+	value := it.MergeIterator.ValueBytes()
+	if it.Source() == fromTop {
+		return value[1:]
+	}
+	return value
+}
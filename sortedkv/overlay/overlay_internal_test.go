@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package overlay
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/leveldb"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func TestDatabase(t *testing.T) {
+	newDB := func() sortedkv.Database { return New(memorydb.NewDatabase()) }
+
+	test.GenericDatabaseTest(t, newDB())
+	test.GenericBatchTest(t, newDB())
+	test.GenericIteratorTest(t, newDB())
+	test.GenericTableTest(t, newDB())
+	test.GenericSnapshotTest(t, newDB())
+	test.GenericRangeDeleteTest(t, newDB())
+	test.GenericClosedDatabaseTest(t, newDB())
+	test.GenericClosedDatabaseTest(t, New(memorydb.FromData(map[string]string{"a": "b"})))
+}
+
+func TestOverlay(t *testing.T) {
+	baseData := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+	base := memorydb.FromData(baseData)
+	db := New(base)
+	dbtest := &test.DatabaseTest{T: t, Database: db}
+
+	dbtest.Put("b", "22")
+	dbtest.Put("e", "5")
+	dbtest.Delete("a")
+	dbtest.MustFailDelete("a")
+	dbtest.MustFailDelete("x")
+	require.NoError(t, db.DeleteRange("c", "d"))
+	dbtest.MustGetEqual("b", "22")
+	dbtest.MustFailGet("a")
+	dbtest.MustFailGet("c")
+	expected := map[string]string{"b": "22", "d": "4", "e": "5"}
+	assert.Equal(t, expected, test.ReadAll(t, db))
+
+	// The base is not modified.
+	assert.Equal(t, baseData, test.ReadAll(t, base))
+
+	// Keys that are not in the base do not leave tombstones.
+	dbtest.Delete("e")
+	_, err := db.top.Get("e")
+	assert.True(t, sortedkv.IsNotFound(err))
+	dbtest.Put("a", "11")
+	delete(expected, "e")
+	expected["a"] = "11"
+	assert.Equal(t, expected, test.ReadAll(t, db))
+
+	// Snapshots are not affected by later writes.
+	s, err := db.NewSnapshot()
+	require.NoError(t, err)
+	dbtest.Put("f", "6")
+	assert.Equal(t, expected, test.ReadAll(t, s))
+	require.NoError(t, s.Close())
+	expected["f"] = "6"
+
+	// Reset discards all changes.
+	require.NoError(t, db.Reset())
+	assert.Equal(t, baseData, test.ReadAll(t, db))
+}
+
+func TestOverlay_Batch(t *testing.T) {
+	db := New(memorydb.FromData(map[string]string{"a": "1", "b": "2", "c": "3"}))
+	b := db.NewBatch()
+	require.NoError(t, b.Put("d", "4"))
+	require.NoError(t, b.Delete("a"))
+	require.NoError(t, b.Delete("x"))
+	require.NoError(t, b.(sortedkv.RangeDeleter).DeletePrefix("c"))
+	require.NoError(t, b.Put("c1", "31"))
+	assert.Equal(t, map[string]string{"a": "1", "b": "2", "c": "3"}, test.ReadAll(t, db))
+
+	require.NoError(t, b.Apply())
+	assert.Equal(t, map[string]string{"b": "2", "c1": "31", "d": "4"}, test.ReadAll(t, db))
+}
+
+func TestOverlay_Commit(t *testing.T) {
+	path, err := ioutil.TempDir("", "poly_testdb_")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(path)) }()
+	base, err := leveldb.LoadDatabase(path)
+	require.NoError(t, err)
+	defer base.Close()
+	for _, k := range []string{"a", "b", "c"} {
+		require.NoError(t, base.Put(k, k))
+	}
+
+	// A dry run is discarded.
+	db := New(base)
+	require.NoError(t, db.Put("d", "d"))
+	require.NoError(t, db.DeletePrefix("a"))
+	require.NoError(t, db.Reset())
+	assert.Equal(t, map[string]string{"a": "a", "b": "b", "c": "c"}, test.ReadAll(t, db))
+
+	require.NoError(t, db.Put("b", "bb"))
+	require.NoError(t, db.Put("d", "d"))
+	require.NoError(t, db.Delete("a"))
+	expected := map[string]string{"b": "bb", "c": "c", "d": "d"}
+	require.NoError(t, db.Commit(base))
+	assert.Equal(t, expected, test.ReadAll(t, base))
+	assert.Equal(t, expected, test.ReadAll(t, db))
+	assert.Empty(t, test.ReadAll(t, db.top))
+
+	require.NoError(t, db.Close())
+	assert.Equal(t, expected, test.ReadAll(t, base), "closing the overlay must not close the base")
+}
+
+func TestOverlay_ConcurrentCommit(t *testing.T) {
+	const n = 1000
+	db := New(memorydb.NewDatabase())
+	target := memorydb.NewDatabase()
+
+	// Commit repeatedly while the keys are written, so that writes land
+	// between the reads and the reset of the top layer.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				assert.NoError(t, db.Commit(target))
+			}
+		}
+	}()
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := w; i < n; i += 4 {
+				assert.NoError(t, db.Put(strconv.Itoa(i), "v"))
+			}
+		}(w)
+	}
+	writers.Wait()
+	close(done)
+	wg.Wait()
+	require.NoError(t, db.Commit(target))
+
+	assert.Len(t, test.ReadAll(t, target), n, "no write may be lost by a commit")
+}
@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package overlay
+
+import "polycry.pt/poly-go/sortedkv"
+
+// Snapshot is a read-only, point-in-time view of an overlay.
+type Snapshot struct {
+	view
+	top sortedkv.Snapshot
+}
+
+// Close releases the snapshot.
+func (s *Snapshot) Close() error {
+	return s.close(s.top)
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package overlay
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// The values in the top layer are tagged to distinguish them from tombstones.
+const (
+	tombstone = "\x00"
+	present   = '\x01'
+)
+
+// view merges the top layer of an overlay with its base.
+type view struct {
+	top  Base
+	base Base
+
+	mu     sync.RWMutex
+	closed bool // Whether top is closed, so that only it is read.
+}
+
+// encode tags a value for the top layer.
+func encode(value string) string {
+	return string(present) + value
+}
+
+// decode returns the value of a tagged value of the top layer and whether it
+// is not a tombstone.
+func decode(tagged string) (string, bool) {
+	if tagged == "" || tagged[0] != present {
+		return "", false
+	}
+	return tagged[1:], true
+}
+
+// lookup returns the value of a key and whether it is present.
+func (v *view) lookup(key string) (string, bool, error) {
+	tagged, err := v.top.Get(key)
+	if err == nil {
+		value, ok := decode(tagged)
+		return value, ok, nil
+	} else if !sortedkv.IsNotFound(err) {
+		return "", false, err
+	}
+
+	value, err := v.base.Get(key)
+	if sortedkv.IsNotFound(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, errors.WithMessage(err, "reading base")
+	}
+	return value, true, nil
+}
+
+// Has returns whether the key is present.
+func (v *view) Has(key string) (bool, error) {
+	_, ok, err := v.lookup(key)
+	return ok, err
+}
+
+// Get returns the value of a key.
+func (v *view) Get(key string) (string, error) {
+	value, ok, err := v.lookup(key)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "", &sortedkv.NotFoundError{Key: key}
+	}
+	return value, nil
+}
+
+// GetBytes returns the value of a key in bytes.
+func (v *view) GetBytes(key string) ([]byte, error) {
+	value, err := v.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// NewIterator creates an iterator over all entries.
+func (v *view) NewIterator() sortedkv.Iterator {
+	return v.newIterator(func(db Base) sortedkv.Iterator {
+		return db.NewIterator()
+	})
+}
+
+// NewIteratorWithRange creates an iterator over the range [start, end).
+func (v *view) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return v.newIterator(func(db Base) sortedkv.Iterator {
+		return db.NewIteratorWithRange(start, end)
+	})
+}
+
+// NewIteratorWithPrefix creates an iterator over all keys with the given
+// prefix.
+func (v *view) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return v.newIterator(func(db Base) sortedkv.Iterator {
+		return db.NewIteratorWithPrefix(prefix)
+	})
+}
+
+// newIterator merges the iterators that newIt creates on the top layer and
+// the base. If the view is closed, only the failing iterator of the top layer
+// is returned.
+func (v *view) newIterator(newIt func(Base) sortedkv.Iterator) sortedkv.Iterator {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.closed {
+		return newIt(v.top)
+	}
+	return newMergedIterator(newIt(v.top), newIt(v.base))
+}
+
+// close closes top, the closer of the top layer.
+func (v *view) close(top io.Closer) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.closed = true
+	return top.Close()
+}
@@ -0,0 +1,360 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+type (
+	// Policy is an allowlist of key prefixes. A key may be read if it starts
+	// with one of the Read prefixes and written if it starts with one of the
+	// Write prefixes. The empty prefix allows all keys.
+	Policy struct {
+		Read  []string
+		Write []string
+	}
+
+	// prefixSet is a sorted set of prefixes of which none is a prefix of
+	// another one.
+	prefixSet []string
+
+	// policyView restricts the reads of a Reader and Iterable to a set of
+	// prefixes.
+	policyView struct {
+		r    Reader
+		it   Iterable
+		read prefixSet
+	}
+
+	// restricted is a database that is restricted by a Policy.
+	restricted struct {
+		policyView
+		db    Database
+		write prefixSet
+	}
+
+	// restrictedBatch is a batch of a restricted database.
+	restrictedBatch struct {
+		b     Batch
+		write prefixSet
+	}
+
+	// restrictedSnapshot is a snapshot of a restricted database.
+	restrictedSnapshot struct {
+		policyView
+		snap Snapshot
+	}
+
+	// policyIterator skips all keys of its iterator that are not in a set of
+	// prefixes.
+	policyIterator struct {
+		Iterator
+		allowed prefixSet
+	}
+)
+
+// Restrict returns a view of db that only allows the reads and writes of the
+// policy. Disallowed operations fail with an *AccessDeniedError.
+//
+// Iterators may be created for any range, but only yield the keys of the range
+// that may be read. Range deletions are only allowed if the whole range may be
+// written. Close fails with an *AccessDeniedError, because the view does not
+// own db.
+func Restrict(db Database, p Policy) Database {
+	return &restricted{
+		policyView: policyView{r: db, it: db, read: newPrefixSet(p.Read)},
+		db:         db,
+		write:      newPrefixSet(p.Write),
+	}
+}
+
+// newPrefixSet sorts the prefixes and removes those that are covered by
+// another prefix.
+func newPrefixSet(prefixes []string) prefixSet {
+	sorted := append([]string(nil), prefixes...)
+	sort.Strings(sorted)
+	set := prefixSet{}
+	for _, p := range sorted {
+		// A covering prefix sorts before all prefixes that it covers, and
+		// every prefix in between is covered by it, too.
+		if len(set) > 0 && strings.HasPrefix(p, set[len(set)-1]) {
+			continue
+		}
+		set = append(set, p)
+	}
+	return set
+}
+
+// covers returns whether the key starts with one of the prefixes. Because no
+// prefix is a prefix of another one, only the largest prefix that is not
+// greater than key can be a prefix of key.
+func (s prefixSet) covers(key string) bool {
+	i := sort.SearchStrings(s, key)
+	if i < len(s) && s[i] == key {
+		return true
+	}
+	return i > 0 && strings.HasPrefix(key, s[i-1])
+}
+
+// coversRange returns whether all keys in the range [start, end) start with
+// one prefix. An empty end denotes no upper bound.
+func (s prefixSet) coversRange(start, end string) bool {
+	if !s.covers(start) {
+		return false
+	}
+	i := sort.SearchStrings(s, start)
+	if i == len(s) || s[i] != start {
+		i--
+	}
+	limit := key.IncPrefix(s[i])
+	return limit == "" || (end != "" && end <= limit)
+}
+
+// ranges returns the intersections of the range [start, end) with the ranges
+// of the prefixes in ascending order. An empty end denotes no upper bound.
+func (s prefixSet) ranges(start, end string) (ranges [][2]string) {
+	for _, p := range s {
+		lo, hi := p, key.IncPrefix(p)
+		if start > lo {
+			lo = start
+		}
+		if hi == "" || (end != "" && end < hi) {
+			hi = end
+		}
+		if hi != "" && lo >= hi {
+			continue
+		}
+		ranges = append(ranges, [2]string{lo, hi})
+	}
+	return ranges
+}
+
+func (v *policyView) checkRead(op, key string) error {
+	if !v.read.covers(key) {
+		return &AccessDeniedError{Op: op, Key: key}
+	}
+	return nil
+}
+
+// Has calls Has of the underlying Reader if the key may be read.
+func (v *policyView) Has(key string) (bool, error) {
+	if err := v.checkRead("Has", key); err != nil {
+		return false, err
+	}
+	return v.r.Has(key)
+}
+
+// Get calls Get of the underlying Reader if the key may be read.
+func (v *policyView) Get(key string) (string, error) {
+	if err := v.checkRead("Get", key); err != nil {
+		return "", err
+	}
+	return v.r.Get(key)
+}
+
+// GetBytes calls GetBytes of the underlying Reader if the key may be read.
+func (v *policyView) GetBytes(key string) ([]byte, error) {
+	if err := v.checkRead("GetBytes", key); err != nil {
+		return nil, err
+	}
+	return v.r.GetBytes(key)
+}
+
+// NewIterator returns an iterator over all keys that may be read.
+func (v *policyView) NewIterator() Iterator {
+	return v.NewIteratorWithRange("", "")
+}
+
+// NewIteratorWithRange returns an iterator over all keys in the range
+// [start, end) that may be read.
+func (v *policyView) NewIteratorWithRange(start string, end string) Iterator {
+	ranges := v.read.ranges(start, end)
+	its := make([]Iterator, len(ranges))
+	for i, r := range ranges {
+		its[i] = v.it.NewIteratorWithRange(r[0], r[1])
+	}
+	// The ranges are disjoint, so no key is shadowed. The filter only guards
+	// against iterators that yield keys outside of their range.
+	return &policyIterator{
+		Iterator: NewMergeIterator(FirstWins, its...),
+		allowed:  v.read,
+	}
+}
+
+// NewIteratorWithPrefix returns an iterator over all keys with the given prefix
+// that may be read.
+func (v *policyView) NewIteratorWithPrefix(prefix string) Iterator {
+	return v.NewIteratorWithRange(prefix, key.IncPrefix(prefix))
+}
+
+func (r *restricted) checkWrite(op, key string) error {
+	if !r.write.covers(key) {
+		return &AccessDeniedError{Op: op, Key: key}
+	}
+	return nil
+}
+
+// Put calls db.Put if the key may be written.
+func (r *restricted) Put(key string, value string) error {
+	if err := r.checkWrite("Put", key); err != nil {
+		return err
+	}
+	return r.db.Put(key, value)
+}
+
+// PutBytes calls db.PutBytes if the key may be written.
+func (r *restricted) PutBytes(key string, value []byte) error {
+	if err := r.checkWrite("PutBytes", key); err != nil {
+		return err
+	}
+	return r.db.PutBytes(key, value)
+}
+
+// Delete calls db.Delete if the key may be written.
+func (r *restricted) Delete(key string) error {
+	if err := r.checkWrite("Delete", key); err != nil {
+		return err
+	}
+	return r.db.Delete(key)
+}
+
+// DeleteRange calls db.DeleteRange if all keys of the range may be written.
+// It fails if db is not a RangeDeleter.
+func (r *restricted) DeleteRange(start string, end string) error {
+	return deleteRange(r.db, r.write, "DeleteRange", start, end)
+}
+
+// DeletePrefix calls db.DeletePrefix if all keys with the prefix may be
+// written. It fails if db is not a RangeDeleter.
+func (r *restricted) DeletePrefix(prefix string) error {
+	return deletePrefix(r.db, r.write, "DeletePrefix", prefix)
+}
+
+// NewBatch returns a batch that only allows the writes of the policy.
+func (r *restricted) NewBatch() Batch {
+	return &restrictedBatch{b: r.db.NewBatch(), write: r.write}
+}
+
+// NewSnapshot creates a snapshot of db that only allows the reads of the
+// policy. It fails if db is not a Snapshotter.
+func (r *restricted) NewSnapshot() (Snapshot, error) {
+	db, ok := r.db.(Snapshotter)
+	if !ok {
+		return nil, &NotSupportedError{Op: "NewSnapshot"}
+	}
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &restrictedSnapshot{
+		policyView: policyView{r: snap, it: snap, read: r.read},
+		snap:       snap,
+	}, nil
+}
+
+// Watch calls db.Watch if all keys with the prefix may be read. It fails if
+// db is not a Watcher.
+func (r *restricted) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	if !r.read.coversRange(prefix, key.IncPrefix(prefix)) {
+		return nil, &AccessDeniedError{Op: "Watch", Key: prefix}
+	}
+	db, ok := r.db.(Watcher)
+	if !ok {
+		return nil, &NotSupportedError{Op: "Watch"}
+	}
+	return db.Watch(ctx, prefix)
+}
+
+// Close fails with an *AccessDeniedError. The underlying database is not
+// closed.
+func (r *restricted) Close() error {
+	return &AccessDeniedError{Op: "Close"}
+}
+
+// Put calls Put of the batch if the key may be written.
+func (b *restrictedBatch) Put(key string, value string) error {
+	if !b.write.covers(key) {
+		return &AccessDeniedError{Op: "Batch.Put", Key: key}
+	}
+	return b.b.Put(key, value)
+}
+
+// PutBytes calls PutBytes of the batch if the key may be written.
+func (b *restrictedBatch) PutBytes(key string, value []byte) error {
+	if !b.write.covers(key) {
+		return &AccessDeniedError{Op: "Batch.PutBytes", Key: key}
+	}
+	return b.b.PutBytes(key, value)
+}
+
+// Delete calls Delete of the batch if the key may be written.
+func (b *restrictedBatch) Delete(key string) error {
+	if !b.write.covers(key) {
+		return &AccessDeniedError{Op: "Batch.Delete", Key: key}
+	}
+	return b.b.Delete(key)
+}
+
+// DeleteRange calls DeleteRange of the batch if all keys of the range may be
+// written. It fails if the batch is not a RangeDeleter.
+func (b *restrictedBatch) DeleteRange(start string, end string) error {
+	return deleteRange(b.b, b.write, "Batch.DeleteRange", start, end)
+}
+
+// DeletePrefix calls DeletePrefix of the batch if all keys with the prefix may
+// be written. It fails if the batch is not a RangeDeleter.
+func (b *restrictedBatch) DeletePrefix(prefix string) error {
+	return deletePrefix(b.b, b.write, "Batch.DeletePrefix", prefix)
+}
+
+// Apply applies the batch.
+func (b *restrictedBatch) Apply() error {
+	return b.b.Apply()
+}
+
+// Reset resets the batch.
+func (b *restrictedBatch) Reset() {
+	b.b.Reset()
+}
+
+// Close closes the snapshot.
+func (s *restrictedSnapshot) Close() error {
+	return s.snap.Close()
+}
+
+// Next moves the iterator to the next key/value pair that may be read.
+func (it *policyIterator) Next() bool {
+	for it.Iterator.Next() {
+		if it.allowed.covers(it.Iterator.Key()) {
+			return true
+		}
+	}
+	return false
+}
+
+func deleteRange(w interface{}, write prefixSet, op, start, end string) error {
+	if !write.coversRange(start, end) {
+		return &AccessDeniedError{Op: op, Key: start}
+	}
+	rd, ok := w.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: op}
+	}
+	return rd.DeleteRange(start, end)
+}
+
+func deletePrefix(w interface{}, write prefixSet, op, prefix string) error {
+	if !write.coversRange(prefix, key.IncPrefix(prefix)) {
+		return &AccessDeniedError{Op: op, Key: prefix}
+	}
+	rd, ok := w.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: op}
+	}
+	return rd.DeletePrefix(prefix)
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import "polycry.pt/poly-go/sortedkv/key"
+
+// Batch is a batch of a Table. It implements sortedkv.Batch and
+// sortedkv.RangeDeleter.
+type Batch struct {
+	t   *Table
+	ops []batchOp
+}
+
+// batchOp is a buffered operation of a batch.
+type batchOp struct {
+	kind  batchOpKind
+	key   string
+	value []byte
+	end   string // End of the range of a range deletion.
+}
+
+type batchOpKind int
+
+const (
+	opPut batchOpKind = iota
+	opDelete
+	opDeleteRange
+)
+
+// Put adds a write to the batch. It fails with an *ExceededError if the key
+// or value is too large.
+func (b *Batch) Put(key string, value string) error {
+	return b.PutBytes(key, []byte(value))
+}
+
+// PutBytes adds a write to the batch. It fails with an *ExceededError if the
+// key or value is too large.
+func (b *Batch) PutBytes(key string, value []byte) error {
+	if err := b.t.limits.checkEntry(key, len(value)); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, batchOp{kind: opPut, key: key, value: append([]byte(nil), value...)})
+	return nil
+}
+
+// Delete adds the deletion of a key to the batch.
+func (b *Batch) Delete(key string) error {
+	b.ops = append(b.ops, batchOp{kind: opDelete, key: key})
+	return nil
+}
+
+// DeleteRange adds the deletion of all keys in the range [start, end) to the
+// batch. An empty end denotes the end of the table.
+func (b *Batch) DeleteRange(start string, end string) error {
+	b.ops = append(b.ops, batchOp{kind: opDeleteRange, key: start, end: end})
+	return nil
+}
+
+// DeletePrefix adds the deletion of all keys with the given prefix to the
+// batch.
+func (b *Batch) DeletePrefix(prefix string) error {
+	return b.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Apply applies the batch atomically. It fails with an *ExceededError and
+// applies none of its writes if the usage after the batch would exceed a
+// limit. Deleting keys that are not present is not an error.
+func (b *Batch) Apply() error {
+	b.t.mu.Lock()
+	defer b.t.mu.Unlock()
+
+	w := b.t.newWriteSet()
+	for _, op := range b.ops {
+		var err error
+		switch op.kind {
+		case opPut:
+			err = w.put(op.key, op.value)
+		case opDelete:
+			_, err = w.delete(op.key)
+		case opDeleteRange:
+			err = w.deleteRange(op.key, op.end)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.apply()
+}
+
+// Reset resets the batch.
+func (b *Batch) Reset() {
+	b.ops = nil
+}
@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package quota provides sortedkv tables with limits on their size.
+//
+// A Table is a table of a database, like sortedkv.NewTable, that enforces
+// Limits on its number of keys, its total size and the sizes of its keys and
+// values. The size of an entry is the length of its key, relative to the
+// table, plus the length of its value. Writes that would exceed a limit fail
+// with an *ExceededError and are not applied; batches are rejected as a whole.
+// Writes that reduce the usage are always accepted, even if the table exceeds
+// a limit, for example after the limits were lowered.
+//
+// The usage of a table is persisted in the same database under a separate
+// key, together with every write. Writes that bypass the Table are not
+// accounted for; Recompute restores the usage from a full scan of the table.
+package quota // import "polycry.pt/poly-go/sortedkv/quota"
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Limit is a kind of limit of a table.
+type Limit int
+
+// The kinds of limits.
+const (
+	LimitKeys      Limit = iota // Number of keys.
+	LimitBytes                  // Total size of the entries.
+	LimitKeySize                // Size of a key.
+	LimitValueSize              // Size of a value.
+)
+
+// ErrExceeded is the target to test for with errors.Is. It matches all
+// *ExceededErrors.
+var ErrExceeded = errors.New("quota: exceeded")
+
+// ExceededError is returned whenever a write would exceed a limit of a table.
+type ExceededError struct {
+	Limit Limit
+	// Key is the key of the write, if the size of the key or value is too
+	// large.
+	Key string
+	// Size is the size or usage that the write would result in.
+	Size int64
+	// Max is the limit.
+	Max int64
+}
+
+// String returns the name of the limit.
+func (l Limit) String() string {
+	switch l {
+	case LimitKeys:
+		return "keys"
+	case LimitBytes:
+		return "bytes"
+	case LimitKeySize:
+		return "key size"
+	case LimitValueSize:
+		return "value size"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(l))
+	}
+}
+
+// Error returns the error string.
+func (e *ExceededError) Error() string {
+	if e.Limit == LimitKeySize || e.Limit == LimitValueSize {
+		return fmt.Sprintf("quota: %s %d of key %q exceeds limit %d", e.Limit, e.Size, e.Key, e.Max)
+	}
+	return fmt.Sprintf("quota: %s %d exceeds limit %d", e.Limit, e.Size, e.Max)
+}
+
+// Is returns whether target is ErrExceeded.
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// IsExceeded returns whether err is or wraps an *ExceededError.
+func IsExceeded(err error) bool {
+	return errors.Is(err, ErrExceeded)
+}
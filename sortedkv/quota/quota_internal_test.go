@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+func newTable(t *testing.T, db sortedkv.Database, limits Limits, opts ...Option) *Table {
+	t.Helper()
+	table, err := New(db, "t.", limits, opts...)
+	require.NoError(t, err)
+	return table
+}
+
+func TestTable(t *testing.T) {
+	newDB := func() sortedkv.Database { return newTable(t, memorydb.NewDatabase(), Limits{}) }
+	test.GenericDatabaseTest(t, newDB())
+	test.GenericBatchTest(t, newDB())
+	test.GenericIteratorTest(t, newDB())
+	test.GenericRangeDeleteTest(t, newDB())
+	test.GenericClosedDatabaseTest(t, newDB())
+}
+
+func requireExceeded(t *testing.T, err error, limit Limit, size int64) {
+	t.Helper()
+	require.True(t, IsExceeded(err), "expected ExceededError, got %v", err)
+	var e *ExceededError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, limit, e.Limit)
+	assert.Equal(t, size, e.Size)
+}
+
+func TestTable_Limits(t *testing.T) {
+	db := memorydb.NewDatabase()
+	table := newTable(t, db, Limits{MaxKeys: 3, MaxBytes: 20, MaxKeySize: 4, MaxValueSize: 8})
+
+	requireExceeded(t, table.Put("12345", "v"), LimitKeySize, 5)
+	requireExceeded(t, table.PutBytes("k", []byte("123456789")), LimitValueSize, 9)
+	assert.Contains(t, table.Put("k", "123456789").Error(), `key "k"`)
+
+	require.NoError(t, table.Put("a", "1234"))   // 5 bytes.
+	require.NoError(t, table.Put("b", "1234"))   // 10 bytes.
+	require.NoError(t, table.Put("a", "123456")) // Overwrite: 12 bytes.
+	assert.Equal(t, Usage{Keys: 2, Bytes: 12}, table.Usage())
+	requireExceeded(t, table.Put("cc", "12345678"), LimitBytes, 22)
+	require.NoError(t, table.Put("c", "1"))
+	requireExceeded(t, table.Put("d", ""), LimitKeys, 4)
+	assert.Equal(t, Usage{Keys: 3, Bytes: 14}, table.Usage())
+
+	// Rejected writes are not applied.
+	(&test.DatabaseTest{T: t, Database: table}).MustFailGet("d")
+	(&test.DatabaseTest{T: t, Database: table}).MustFailGet("cc")
+
+	// Writes that reduce the usage are accepted even if a limit is exceeded.
+	lowered := newTable(t, db, Limits{MaxKeys: 1, MaxBytes: 5})
+	assert.Equal(t, Usage{Keys: 3, Bytes: 14}, lowered.Usage())
+	require.NoError(t, lowered.Put("a", "1"))
+	requireExceeded(t, lowered.Put("b", "123456"), LimitBytes, 11)
+	require.NoError(t, lowered.Delete("b"))
+	assert.Equal(t, Usage{Keys: 2, Bytes: 4}, lowered.Usage())
+	requireExceeded(t, lowered.Put("d", ""), LimitKeys, 3)
+}
+
+func TestTable_Batch(t *testing.T) {
+	table := newTable(t, memorydb.NewDatabase(), Limits{MaxKeys: 3, MaxValueSize: 4})
+	require.NoError(t, table.Put("a", "1"))
+
+	b := table.NewBatch()
+	requireExceeded(t, b.Put("x", "12345"), LimitValueSize, 5)
+	require.NoError(t, b.Put("b", "2"))
+	require.NoError(t, b.Put("c", "3"))
+	require.NoError(t, b.Put("d", "4"))
+	requireExceeded(t, b.Apply(), LimitKeys, 4)
+	assert.Equal(t, Usage{Keys: 1, Bytes: 2}, table.Usage())
+	(&test.DatabaseTest{T: t, Database: table}).MustFailGet("b")
+
+	// Deleting within the batch makes room.
+	require.NoError(t, b.Delete("a"))
+	require.NoError(t, b.Delete("missing"))
+	require.NoError(t, b.Apply())
+	assert.Equal(t, Usage{Keys: 3, Bytes: 6}, table.Usage())
+
+	b = table.NewBatch()
+	require.NoError(t, b.Put("e", "5"))
+	require.NoError(t, b.(sortedkv.RangeDeleter).DeleteRange("c", ""))
+	require.NoError(t, b.Apply())
+	assert.Equal(t, Usage{Keys: 1, Bytes: 2}, table.Usage())
+
+	require.NoError(t, table.DeletePrefix(""))
+	assert.Equal(t, Usage{}, table.Usage())
+}
+
+func TestTable_Persistence(t *testing.T) {
+	db := memorydb.NewDatabase()
+	require.NoError(t, db.Put("t.x", "123"))
+	require.NoError(t, db.Put("u.x", "123"))
+
+	// A new table computes its usage.
+	table := newTable(t, db, Limits{})
+	assert.Equal(t, Usage{Keys: 1, Bytes: 4}, table.Usage())
+	require.NoError(t, table.Put("y", "12"))
+
+	// The usage is loaded and not recomputed.
+	require.NoError(t, db.Put("t.z", "bypassed"))
+	table = newTable(t, db, Limits{})
+	assert.Equal(t, Usage{Keys: 2, Bytes: 7}, table.Usage())
+	usage, err := table.Recompute()
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Keys: 3, Bytes: 16}, usage)
+	assert.Equal(t, usage, newTable(t, db, Limits{}).Usage())
+
+	// Custom usage keys.
+	table = newTable(t, db, Limits{}, WithUsageKey("usage"))
+	assert.Equal(t, usage, table.Usage())
+	(&test.DatabaseTest{T: t, Database: db}).MustGetBytesEqual("usage", usage.encode())
+	_, err = New(db, "t.", Limits{}, WithUsageKey("t.usage"))
+	assert.Error(t, err)
+
+	require.NoError(t, db.Put("usage", "x"))
+	_, err = New(db, "t.", Limits{}, WithUsageKey("usage"))
+	assert.True(t, sortedkv.IsCorrupted(err))
+}
+
+func TestLimit_String(t *testing.T) {
+	assert.Equal(t, "keys", LimitKeys.String())
+	assert.Equal(t, "value size", LimitValueSize.String())
+	assert.Equal(t, "Limit(7)", Limit(7).String())
+	assert.Equal(t, "quota: bytes 5 exceeds limit 4",
+		(&ExceededError{Limit: LimitBytes, Size: 5, Max: 4}).Error())
+}
@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+// DefaultUsagePrefix is the default key prefix of the usages of tables. The
+// usage of a table is stored under this prefix followed by the table prefix.
+const DefaultUsagePrefix = "\x00quota."
+
+type (
+	// Option configures a Table. Options are passed to New.
+	Option func(*options)
+
+	options struct {
+		usageKey *string
+	}
+
+	// Table is a table of a database with limits. It implements
+	// sortedkv.Database and sortedkv.RangeDeleter. Reads are passed through
+	// to the table of the underlying database.
+	Table struct {
+		sortedkv.Database // The table.
+
+		db       sortedkv.Database
+		prefix   string
+		limits   Limits
+		usageKey string
+
+		mu    sync.Mutex // Serializes writes, which update the usage.
+		usage Usage
+	}
+)
+
+// WithUsageKey sets the key under which the usage of the table is stored. It
+// must not be within the table.
+func WithUsageKey(key string) Option {
+	return func(o *options) { o.usageKey = &key }
+}
+
+// New creates a table with the given prefix and limits. It loads the usage of
+// the table, or computes it from a full scan if no usage is stored.
+func New(db sortedkv.Database, prefix string, limits Limits, opts ...Option) (*Table, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	usageKey := DefaultUsagePrefix + prefix
+	if o.usageKey != nil {
+		usageKey = *o.usageKey
+	}
+	if strings.HasPrefix(usageKey, prefix) {
+		return nil, errors.Errorf("usage key %q is within the table %q", usageKey, prefix)
+	}
+
+	t := &Table{
+		Database: sortedkv.NewTable(db, prefix),
+		db:       db,
+		prefix:   prefix,
+		limits:   limits,
+		usageKey: usageKey,
+	}
+	data, err := db.GetBytes(usageKey)
+	if sortedkv.IsNotFound(err) {
+		_, err = t.Recompute()
+		return t, err
+	} else if err != nil {
+		return nil, errors.WithMessage(err, "loading usage")
+	}
+	if t.usage, err = decodeUsage(data); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Usage returns the current usage of the table.
+func (t *Table) Usage() Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// Limits returns the limits of the table.
+func (t *Table) Limits() Limits {
+	return t.limits
+}
+
+// Recompute computes the usage of the table from a full scan, stores it and
+// returns it.
+func (t *Table) Recompute() (Usage, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var u Usage
+	it := t.db.NewIteratorWithPrefix(t.prefix)
+	defer it.Close()
+	for it.Next() {
+		u.Keys++
+		u.Bytes += int64(len(it.Key()) - len(t.prefix) + len(it.ValueBytes()))
+	}
+	if err := it.Close(); err != nil {
+		return Usage{}, errors.WithMessage(err, "scanning table")
+	}
+	if err := t.db.PutBytes(t.usageKey, u.encode()); err != nil {
+		return Usage{}, errors.WithMessage(err, "storing usage")
+	}
+	t.usage = u
+	return u, nil
+}
+
+// Put saves a value under a key. It fails with an *ExceededError if the write
+// would exceed a limit.
+func (t *Table) Put(key string, value string) error {
+	return t.PutBytes(key, []byte(value))
+}
+
+// PutBytes saves a value under a key. It fails with an *ExceededError if the
+// write would exceed a limit.
+func (t *Table) PutBytes(key string, value []byte) error {
+	if err := t.limits.checkEntry(key, len(value)); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.newWriteSet()
+	if err := w.put(key, value); err != nil {
+		return err
+	}
+	return w.apply()
+}
+
+// Delete deletes a key. It fails with a *sortedkv.NotFoundError if the key is
+// not present.
+func (t *Table) Delete(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.newWriteSet()
+	if found, err := w.delete(key); err != nil {
+		return err
+	} else if !found {
+		return &sortedkv.NotFoundError{Key: key}
+	}
+	return w.apply()
+}
+
+// DeleteRange deletes all keys in the range [start, end) of the table. An
+// empty end denotes the end of the table.
+func (t *Table) DeleteRange(start string, end string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.newWriteSet()
+	if err := w.deleteRange(start, end); err != nil {
+		return err
+	}
+	return w.apply()
+}
+
+// DeletePrefix deletes all keys with the given prefix from the table.
+func (t *Table) DeletePrefix(prefix string) error {
+	return t.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// NewBatch creates a new batch.
+func (t *Table) NewBatch() sortedkv.Batch {
+	return &Batch{t: t}
+}
+
+// writeSet collects writes to the table in a batch of the underlying
+// database and computes the resulting usage. It tracks the writes, so that
+// later writes to the same keys see them.
+type writeSet struct {
+	t       *Table
+	b       sortedkv.Batch
+	usage   Usage
+	pending map[string]*int // Value sizes, nil for deleted keys.
+}
+
+// newWriteSet creates a writeSet. The table must be locked until the write
+// set is applied.
+func (t *Table) newWriteSet() *writeSet {
+	return &writeSet{
+		t:       t,
+		b:       t.db.NewBatch(),
+		usage:   t.usage,
+		pending: make(map[string]*int),
+	}
+}
+
+// valueSize returns the size of the value of a key and whether it is present.
+func (w *writeSet) valueSize(key string) (int, bool, error) {
+	if size, ok := w.pending[key]; ok {
+		if size == nil {
+			return 0, false, nil
+		}
+		return *size, true, nil
+	}
+	value, err := w.t.db.GetBytes(w.t.prefix + key)
+	if sortedkv.IsNotFound(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return len(value), true, nil
+}
+
+func (w *writeSet) put(key string, value []byte) error {
+	if _, err := w.delete(key); err != nil {
+		return err
+	}
+	if err := w.b.PutBytes(w.t.prefix+key, value); err != nil {
+		return err
+	}
+	size := len(value)
+	w.pending[key] = &size
+	w.usage.Keys++
+	w.usage.Bytes += int64(len(key) + size)
+	return nil
+}
+
+// delete deletes a key and returns whether it was present.
+func (w *writeSet) delete(key string) (bool, error) {
+	size, ok, err := w.valueSize(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := w.b.Delete(w.t.prefix + key); err != nil {
+		return false, err
+	}
+	w.pending[key] = nil
+	w.usage.Keys--
+	w.usage.Bytes -= int64(len(key) + size)
+	return true, nil
+}
+
+// deleteRange deletes all keys in the range [start, end) of the table that
+// are stored or were written by the write set.
+func (w *writeSet) deleteRange(start, end string) error {
+	inRange := func(key string) bool { return key >= start && (end == "" || key < end) }
+	for key, size := range w.pending {
+		if size != nil && inRange(key) {
+			if _, err := w.delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	it := w.t.Database.NewIteratorWithRange(start, end)
+	defer it.Close()
+	for it.Next() {
+		if _, err := w.delete(it.Key()); err != nil {
+			return err
+		}
+	}
+	return errors.WithMessage(it.Close(), "reading table")
+}
+
+// apply checks the resulting usage against the limits and applies the writes
+// together with the usage.
+func (w *writeSet) apply() error {
+	if err := w.t.limits.checkUsage(w.t.usage, w.usage); err != nil {
+		return err
+	}
+	if err := w.b.PutBytes(w.t.usageKey, w.usage.encode()); err != nil {
+		return err
+	}
+	if err := w.b.Apply(); err != nil {
+		return err
+	}
+	w.t.usage = w.usage
+	return nil
+}
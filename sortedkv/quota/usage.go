@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package quota
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// Limits are the limits of a table. A zero limit means no limit.
+	Limits struct {
+		MaxKeys      int64
+		MaxBytes     int64
+		MaxKeySize   int
+		MaxValueSize int
+	}
+
+	// Usage is the usage of a table.
+	Usage struct {
+		Keys  int64
+		Bytes int64 // Sum of the lengths of the keys and values.
+	}
+)
+
+const usageSize = 16
+
+// checkEntry checks the sizes of a key and value against the limits.
+func (l Limits) checkEntry(key string, valueSize int) error {
+	if l.MaxKeySize > 0 && len(key) > l.MaxKeySize {
+		return &ExceededError{Limit: LimitKeySize, Key: key, Size: int64(len(key)), Max: int64(l.MaxKeySize)}
+	}
+	if l.MaxValueSize > 0 && valueSize > l.MaxValueSize {
+		return &ExceededError{Limit: LimitValueSize, Key: key, Size: int64(valueSize), Max: int64(l.MaxValueSize)}
+	}
+	return nil
+}
+
+// checkUsage checks whether the change of the usage from old to u exceeds
+// the limits. Usage that does not grow never exceeds the limits.
+func (l Limits) checkUsage(old, u Usage) error {
+	if l.MaxKeys > 0 && u.Keys > l.MaxKeys && u.Keys > old.Keys {
+		return &ExceededError{Limit: LimitKeys, Size: u.Keys, Max: l.MaxKeys}
+	}
+	if l.MaxBytes > 0 && u.Bytes > l.MaxBytes && u.Bytes > old.Bytes {
+		return &ExceededError{Limit: LimitBytes, Size: u.Bytes, Max: l.MaxBytes}
+	}
+	return nil
+}
+
+// encode encodes the usage as the keys and bytes, each 8 bytes big-endian.
+func (u Usage) encode() []byte {
+	data := make([]byte, usageSize)
+	binary.BigEndian.PutUint64(data, uint64(u.Keys))
+	binary.BigEndian.PutUint64(data[8:], uint64(u.Bytes))
+	return data
+}
+
+func decodeUsage(data []byte) (Usage, error) {
+	if len(data) != usageSize {
+		return Usage{}, &sortedkv.CorruptedError{Err: errors.Errorf("invalid usage length %d", len(data))}
+	}
+	return Usage{
+		Keys:  int64(binary.BigEndian.Uint64(data)),
+		Bytes: int64(binary.BigEndian.Uint64(data[8:])),
+	}, nil
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import "context"
+
+type (
+	// readOnly is a wrapper around a database that rejects all writes.
+	readOnly struct {
+		db Database
+	}
+
+	// readOnlyBatch is a batch of a read-only database.
+	readOnlyBatch struct{}
+)
+
+// ReadOnly returns a read-only view of db. All writes, including those of its
+// batches, range deletions, compactions and transactions, fail with a
+// *ReadOnlyError. Reads, iterators, snapshots, watches and statistics are
+// passed through. Close fails with a *ReadOnlyError as well, because the view
+// does not own db.
+func ReadOnly(db Database) Database {
+	return &readOnly{db: db}
+}
+
+// Has calls db.Has.
+func (r *readOnly) Has(key string) (bool, error) {
+	return r.db.Has(key)
+}
+
+// Get calls db.Get.
+func (r *readOnly) Get(key string) (string, error) {
+	return r.db.Get(key)
+}
+
+// GetBytes calls db.GetBytes.
+func (r *readOnly) GetBytes(key string) ([]byte, error) {
+	return r.db.GetBytes(key)
+}
+
+// Put fails with a *ReadOnlyError.
+func (r *readOnly) Put(string, string) error {
+	return &ReadOnlyError{Op: "Put"}
+}
+
+// PutBytes fails with a *ReadOnlyError.
+func (r *readOnly) PutBytes(string, []byte) error {
+	return &ReadOnlyError{Op: "PutBytes"}
+}
+
+// Delete fails with a *ReadOnlyError.
+func (r *readOnly) Delete(string) error {
+	return &ReadOnlyError{Op: "Delete"}
+}
+
+// DeleteRange fails with a *ReadOnlyError.
+func (r *readOnly) DeleteRange(string, string) error {
+	return &ReadOnlyError{Op: "DeleteRange"}
+}
+
+// DeletePrefix fails with a *ReadOnlyError.
+func (r *readOnly) DeletePrefix(string) error {
+	return &ReadOnlyError{Op: "DeletePrefix"}
+}
+
+// CompactRange fails with a *ReadOnlyError.
+func (r *readOnly) CompactRange(string, string) error {
+	return &ReadOnlyError{Op: "CompactRange"}
+}
+
+// NewBatch returns a batch whose operations fail with a *ReadOnlyError.
+func (r *readOnly) NewBatch() Batch {
+	return readOnlyBatch{}
+}
+
+// NewTransaction fails with a *ReadOnlyError.
+func (r *readOnly) NewTransaction() (Transaction, error) {
+	return nil, &ReadOnlyError{Op: "NewTransaction"}
+}
+
+// NewIterator calls db.NewIterator.
+func (r *readOnly) NewIterator() Iterator {
+	return r.db.NewIterator()
+}
+
+// NewIteratorWithRange calls db.NewIteratorWithRange.
+func (r *readOnly) NewIteratorWithRange(start string, end string) Iterator {
+	return r.db.NewIteratorWithRange(start, end)
+}
+
+// NewIteratorWithPrefix calls db.NewIteratorWithPrefix.
+func (r *readOnly) NewIteratorWithPrefix(prefix string) Iterator {
+	return r.db.NewIteratorWithPrefix(prefix)
+}
+
+// NewSnapshot calls db.NewSnapshot. It fails if db is not a Snapshotter.
+func (r *readOnly) NewSnapshot() (Snapshot, error) {
+	db, ok := r.db.(Snapshotter)
+	if !ok {
+		return nil, &NotSupportedError{Op: "NewSnapshot"}
+	}
+	return db.NewSnapshot()
+}
+
+// Watch calls db.Watch. It fails if db is not a Watcher.
+func (r *readOnly) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	db, ok := r.db.(Watcher)
+	if !ok {
+		return nil, &NotSupportedError{Op: "Watch"}
+	}
+	return db.Watch(ctx, prefix)
+}
+
+// ApproximateSize calls db.ApproximateSize. It fails if db is not a Stater.
+func (r *readOnly) ApproximateSize(start string, end string) (int64, error) {
+	db, ok := r.db.(Stater)
+	if !ok {
+		return 0, &NotSupportedError{Op: "ApproximateSize"}
+	}
+	return db.ApproximateSize(start, end)
+}
+
+// Stats calls db.Stats. It fails if db is not a Stater.
+func (r *readOnly) Stats() (map[string]string, error) {
+	db, ok := r.db.(Stater)
+	if !ok {
+		return nil, &NotSupportedError{Op: "Stats"}
+	}
+	return db.Stats()
+}
+
+// Close fails with a *ReadOnlyError. The underlying database is not closed.
+func (r *readOnly) Close() error {
+	return &ReadOnlyError{Op: "Close"}
+}
+
+// Put fails with a *ReadOnlyError.
+func (readOnlyBatch) Put(string, string) error {
+	return &ReadOnlyError{Op: "Batch.Put"}
+}
+
+// PutBytes fails with a *ReadOnlyError.
+func (readOnlyBatch) PutBytes(string, []byte) error {
+	return &ReadOnlyError{Op: "Batch.PutBytes"}
+}
+
+// Delete fails with a *ReadOnlyError.
+func (readOnlyBatch) Delete(string) error {
+	return &ReadOnlyError{Op: "Batch.Delete"}
+}
+
+// DeleteRange fails with a *ReadOnlyError.
+func (readOnlyBatch) DeleteRange(string, string) error {
+	return &ReadOnlyError{Op: "Batch.DeleteRange"}
+}
+
+// DeletePrefix fails with a *ReadOnlyError.
+func (readOnlyBatch) DeletePrefix(string) error {
+	return &ReadOnlyError{Op: "Batch.DeletePrefix"}
+}
+
+// Apply fails with a *ReadOnlyError.
+func (readOnlyBatch) Apply() error {
+	return &ReadOnlyError{Op: "Batch.Apply"}
+}
+
+// Reset does nothing.
+func (readOnlyBatch) Reset() {}
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import "io"
+
+// Snapshot is a read-only, point-in-time view of a database. Writes to the
+// database that happen after the snapshot was taken are not visible through
+// the snapshot. A snapshot must be closed after use.
+type Snapshot interface {
+	Reader
+	Iterable
+	io.Closer
+}
+
+// Snapshotter wraps the NewSnapshot method of a backing data store.
+type Snapshotter interface {
+	// NewSnapshot creates a Snapshot of the current state of the data store.
+	NewSnapshot() (Snapshot, error)
+}
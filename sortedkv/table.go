@@ -2,7 +2,7 @@
 
 package sortedkv
 
-import "polycry.pt/poly-go/sortedkv/key"
+import "context"
 
 // Table is a wrapper around a database with a key prefix. All key access is
 // automatically prefixed. Close() is a noop and properties are forwarded
@@ -29,14 +29,18 @@ func (t *table) Has(key string) (bool, error) {
 	return t.Database.Has(t.pkey(key))
 }
 
-// Get calls db.Get with the prefixed key.
+// Get calls db.Get with the prefixed key. The key of a returned
+// *NotFoundError is relative to the table.
 func (t *table) Get(key string) (string, error) {
-	return t.Database.Get(t.pkey(key))
+	value, err := t.Database.Get(t.pkey(key))
+	return value, stripErrorPrefix(err, t.prefix)
 }
 
-// GetBytes calls db.GetBytes with the prefixed key.
+// GetBytes calls db.GetBytes with the prefixed key. The key of a returned
+// *NotFoundError is relative to the table.
 func (t *table) GetBytes(key string) ([]byte, error) {
-	return t.Database.GetBytes(t.pkey(key))
+	value, err := t.Database.GetBytes(t.pkey(key))
+	return value, stripErrorPrefix(err, t.prefix)
 }
 
 // Put calls db.Put with the prefixed key.
@@ -49,34 +53,152 @@ func (t *table) PutBytes(key string, value []byte) error {
 	return t.Database.PutBytes(t.pkey(key), value)
 }
 
-// Delete calls db.Delete with the prefixed key.
+// Delete calls db.Delete with the prefixed key. The key of a returned
+// *NotFoundError is relative to the table.
 func (t *table) Delete(key string) error {
-	return t.Database.Delete(t.pkey(key))
+	return stripErrorPrefix(t.Database.Delete(t.pkey(key)), t.prefix)
+}
+
+// DeleteRange calls db.DeleteRange with the prefixed range. An empty end
+// denotes the end of the table. It fails if the underlying database is not a
+// RangeDeleter.
+func (t *table) DeleteRange(start string, end string) error {
+	db, ok := t.Database.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: "DeleteRange"}
+	}
+	return db.DeleteRange(tableRange(t.prefix, start, end))
+}
+
+// DeletePrefix calls db.DeletePrefix with the prefixed prefix. It fails if the
+// underlying database is not a RangeDeleter.
+func (t *table) DeletePrefix(prefix string) error {
+	db, ok := t.Database.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: "DeletePrefix"}
+	}
+	return db.DeletePrefix(t.pkey(prefix))
+}
+
+// CompactRange calls db.CompactRange with the prefixed range. An empty end
+// denotes the end of the table. It fails if the underlying database is not a
+// Compacter.
+func (t *table) CompactRange(start string, end string) error {
+	db, ok := t.Database.(Compacter)
+	if !ok {
+		return &NotSupportedError{Op: "CompactRange"}
+	}
+	return db.CompactRange(tableRange(t.prefix, start, end))
+}
+
+// ApproximateSize calls db.ApproximateSize with the prefixed range. An empty
+// end denotes the end of the table. It fails if the underlying database is not
+// a Stater.
+func (t *table) ApproximateSize(start string, end string) (int64, error) {
+	db, ok := t.Database.(Stater)
+	if !ok {
+		return 0, &NotSupportedError{Op: "ApproximateSize"}
+	}
+	return db.ApproximateSize(tableRange(t.prefix, start, end))
+}
+
+// Stats returns the statistics of the whole underlying database. It fails if
+// the underlying database is not a Stater.
+func (t *table) Stats() (map[string]string, error) {
+	db, ok := t.Database.(Stater)
+	if !ok {
+		return nil, &NotSupportedError{Op: "Stats"}
+	}
+	return db.Stats()
 }
 
 // NewBatch creates a new batch.
 func (t *table) NewBatch() Batch {
-	return &tableBatch{t.Database.NewBatch(), t.prefix}
+	return newTableBatch(t.Database.NewBatch(), t.prefix)
 }
 
 // NewIterator creates a new table iterator.
 func (t *table) NewIterator() Iterator {
-	return newTableIterator(t.Database.NewIteratorWithPrefix(t.prefix), t)
+	return newTableIteratorWithPrefix(t.Database, t.prefix, "")
 }
 
 // NewIteratorWithRange creates a new ranged iterator.
 func (t *table) NewIteratorWithRange(start string, end string) Iterator {
-	start = t.pkey(start)
-	if end == "" {
-		end = key.IncPrefix(t.prefix)
-	} else {
-		end = t.pkey(end)
-	}
-
-	return newTableIterator(t.Database.NewIteratorWithRange(start, end), t)
+	return newTableIteratorWithRange(t.Database, t.prefix, start, end)
 }
 
 // NewIteratorWithPrefix creates a new iterator for a prefix.
 func (t *table) NewIteratorWithPrefix(prefix string) Iterator {
-	return newTableIterator(t.Database.NewIteratorWithPrefix(t.pkey(prefix)), t)
+	return newTableIteratorWithPrefix(t.Database, t.prefix, prefix)
+}
+
+// NewSnapshot creates a snapshot of the table. It fails if the underlying
+// database is not a Snapshotter.
+func (t *table) NewSnapshot() (Snapshot, error) {
+	db, ok := t.Database.(Snapshotter)
+	if !ok {
+		return nil, &NotSupportedError{Op: "NewSnapshot"}
+	}
+	s, err := db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &tableSnapshot{s, t.prefix}, nil
+}
+
+// NewTransaction creates a transaction on the table. It fails if the
+// underlying database is not a Transactor.
+func (t *table) NewTransaction() (Transaction, error) {
+	db, ok := t.Database.(Transactor)
+	if !ok {
+		return nil, &NotSupportedError{Op: "NewTransaction"}
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &tableTransaction{tx, t.prefix}, nil
+}
+
+// Watch calls db.Watch with the prefixed prefix. The keys of the events are
+// relative to the table. It fails if the underlying database is not a
+// Watcher.
+func (t *table) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	return t.watch(ctx, prefix, 0)
+}
+
+func (t *table) watch(ctx context.Context, prefix string, trim int) (<-chan Event, error) {
+	switch db := t.Database.(type) {
+	case prefixWatcher:
+		return db.watch(ctx, t.pkey(prefix), trim+len(t.prefix))
+	case Watcher:
+		events, err := db.Watch(ctx, t.pkey(prefix))
+		if err != nil {
+			return nil, err
+		}
+		return trimEvents(ctx, events, trim+len(t.prefix)), nil
+	default:
+		return nil, &NotSupportedError{Op: "Watch"}
+	}
+}
+
+// trimEvents forwards the events and trims the first trim bytes of their
+// keys. It is used for Watcher implementations other than the watchable
+// database.
+func trimEvents(ctx context.Context, events <-chan Event, trim int) <-chan Event {
+	trimmed := make(chan Event, WatchBufferSize+1)
+	go func() {
+		defer close(trimmed)
+		for e := range events {
+			if e.Type != EventOverflow {
+				e.Key = e.Key[trim:]
+			}
+			select {
+			case trimmed <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return trimmed
 }
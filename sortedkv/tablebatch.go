@@ -3,27 +3,81 @@
 package sortedkv
 
 // tableBatch is a wrapper around a Database Batch with a key prefix. All
-// Writer operations are automatically prefixed.
+// Writer operations are automatically prefixed by its tableWriter.
 type tableBatch struct {
-	Batch
+	*tableWriter
+	batch Batch
+}
+
+// newTableBatch creates a table batch that writes to batch.
+func newTableBatch(batch Batch, prefix string) *tableBatch {
+	return &tableBatch{tableWriter: newTableWriter(batch, prefix), batch: batch}
+}
+
+// Apply applies the underlying batch.
+func (b *tableBatch) Apply() error {
+	return b.batch.Apply()
+}
+
+// Reset resets the underlying batch.
+func (b *tableBatch) Reset() {
+	b.batch.Reset()
+}
+
+// tableWriter is a wrapper around a Writer with a key prefix. All Writer
+// operations are automatically prefixed.
+type tableWriter struct {
+	parent Writer
 	prefix string
 }
 
-func (b *tableBatch) pkey(key string) string {
-	return b.prefix + key
+// TableBatch returns a Writer for the table with the given prefix that writes
+// to the parent batch. This lets several tables share one batch, so that the
+// writes to all of them are applied atomically by a single Apply of the
+// parent. TableBatch can also be called on a Writer that TableBatch returned,
+// to write to nested tables.
+//
+// The returned Writer implements RangeDeleter. Its range deletions fail if the
+// parent is not a RangeDeleter.
+func TableBatch(parent Writer, prefix string) Writer {
+	return newTableWriter(parent, prefix)
+}
+
+func newTableWriter(parent Writer, prefix string) *tableWriter {
+	return &tableWriter{parent: parent, prefix: prefix}
+}
+
+// Put puts a value under the prefixed key into the parent.
+func (w *tableWriter) Put(key, value string) error {
+	return w.parent.Put(w.prefix+key, value)
+}
+
+// PutBytes puts a value under the prefixed key into the parent.
+func (w *tableWriter) PutBytes(key string, value []byte) error {
+	return w.parent.PutBytes(w.prefix+key, value)
 }
 
-// Put puts a value into a table batch.
-func (b *tableBatch) Put(key, value string) error {
-	return b.Batch.Put(b.pkey(key), value)
+// Delete deletes the prefixed key from the parent.
+func (w *tableWriter) Delete(key string) error {
+	return stripErrorPrefix(w.parent.Delete(w.prefix+key), w.prefix)
 }
 
-// Put puts a value into a table batch.
-func (b *tableBatch) PutBytes(key string, value []byte) error {
-	return b.Batch.PutBytes(b.pkey(key), value)
+// DeleteRange deletes the prefixed range from the parent. An empty end denotes
+// the end of the table. It fails if the parent is not a RangeDeleter.
+func (w *tableWriter) DeleteRange(start string, end string) error {
+	parent, ok := w.parent.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: "DeleteRange"}
+	}
+	return parent.DeleteRange(tableRange(w.prefix, start, end))
 }
 
-// Delete deletes a value from a table batch.
-func (b *tableBatch) Delete(key string) error {
-	return b.Batch.Delete(b.pkey(key))
+// DeletePrefix deletes all keys with the prefixed prefix from the parent. It
+// fails if the parent is not a RangeDeleter.
+func (w *tableWriter) DeletePrefix(prefix string) error {
+	parent, ok := w.parent.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: "DeletePrefix"}
+	}
+	return parent.DeletePrefix(w.prefix + prefix)
 }
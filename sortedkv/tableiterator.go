@@ -2,21 +2,76 @@
 
 package sortedkv
 
+import "polycry.pt/poly-go/sortedkv/key"
+
 // tableIterator is a wrapper around the Iterator interface.
 type tableIterator struct {
 	Iterator
 	prefix int
 }
 
-// newTableIterator creates a new table iterator.
-func newTableIterator(it Iterator, table *table) Iterator {
+// seekableTableIterator is a wrapper around the SeekableIterator interface.
+type seekableTableIterator struct {
+	SeekableIterator
+	prefix string
+}
+
+// newTableIterator creates a new table iterator. If it is a SeekableIterator,
+// so is the table iterator.
+func newTableIterator(it Iterator, prefix string) Iterator {
+	if sit, ok := it.(SeekableIterator); ok {
+		return &seekableTableIterator{
+			SeekableIterator: sit,
+			prefix:           prefix,
+		}
+	}
 	return &tableIterator{
 		Iterator: it,
-		prefix:   len(table.prefix),
+		prefix:   len(prefix),
+	}
+}
+
+// newTableIteratorWithRange creates a table iterator over the key range
+// [start, end) of the table with the given prefix. An empty end denotes the
+// end of the table.
+func newTableIteratorWithRange(db Iterable, prefix, start, end string) Iterator {
+	return newTableIterator(db.NewIteratorWithRange(tableRange(prefix, start, end)), prefix)
+}
+
+// tableRange returns the database range of the range [start, end) of the
+// table with the given prefix. An empty end denotes the end of the table.
+func tableRange(prefix, start, end string) (string, string) {
+	if end == "" {
+		return prefix + start, key.IncPrefix(prefix)
 	}
+	return prefix + start, prefix + end
+}
+
+// newTableIteratorWithPrefix creates a table iterator over all keys of the
+// table with the given prefix that start with keyPrefix.
+func newTableIteratorWithPrefix(db Iterable, prefix, keyPrefix string) Iterator {
+	return newTableIterator(db.NewIteratorWithPrefix(prefix+keyPrefix), prefix)
 }
 
 // Key returns the value that is iterated over, but without the table's prefix.
 func (it *tableIterator) Key() string {
-	return it.Iterator.Key()[it.prefix:]
+	key := it.Iterator.Key()
+	if key == "" {
+		return "" // Iterator is done.
+	}
+	return key[it.prefix:]
+}
+
+// Key returns the value that is iterated over, but without the table's prefix.
+func (it *seekableTableIterator) Key() string {
+	key := it.SeekableIterator.Key()
+	if key == "" {
+		return "" // Iterator is done.
+	}
+	return key[len(it.prefix):]
+}
+
+// Seek calls it.Seek with the prefixed key.
+func (it *seekableTableIterator) Seek(key string) bool {
+	return it.SeekableIterator.Seek(it.prefix + key)
 }
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+// tableSnapshot is a wrapper around a database Snapshot with a key prefix. All
+// key access is automatically prefixed.
+type tableSnapshot struct {
+	Snapshot
+	prefix string
+}
+
+func (s *tableSnapshot) pkey(key string) string {
+	return s.prefix + key
+}
+
+// Has calls snapshot.Has with the prefixed key.
+func (s *tableSnapshot) Has(key string) (bool, error) {
+	return s.Snapshot.Has(s.pkey(key))
+}
+
+// Get calls snapshot.Get with the prefixed key.
+func (s *tableSnapshot) Get(key string) (string, error) {
+	value, err := s.Snapshot.Get(s.pkey(key))
+	return value, stripErrorPrefix(err, s.prefix)
+}
+
+// GetBytes calls snapshot.GetBytes with the prefixed key.
+func (s *tableSnapshot) GetBytes(key string) ([]byte, error) {
+	value, err := s.Snapshot.GetBytes(s.pkey(key))
+	return value, stripErrorPrefix(err, s.prefix)
+}
+
+// NewIterator creates a new table iterator.
+func (s *tableSnapshot) NewIterator() Iterator {
+	return newTableIteratorWithPrefix(s.Snapshot, s.prefix, "")
+}
+
+// NewIteratorWithRange creates a new ranged iterator.
+func (s *tableSnapshot) NewIteratorWithRange(start string, end string) Iterator {
+	return newTableIteratorWithRange(s.Snapshot, s.prefix, start, end)
+}
+
+// NewIteratorWithPrefix creates a new iterator for a prefix.
+func (s *tableSnapshot) NewIteratorWithPrefix(prefix string) Iterator {
+	return newTableIteratorWithPrefix(s.Snapshot, s.prefix, prefix)
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+// tableTransaction is a wrapper around a database Transaction with a key
+// prefix. All key access is automatically prefixed.
+type tableTransaction struct {
+	Transaction
+	prefix string
+}
+
+func (tx *tableTransaction) pkey(key string) string {
+	return tx.prefix + key
+}
+
+// Has calls tx.Has with the prefixed key.
+func (tx *tableTransaction) Has(key string) (bool, error) {
+	return tx.Transaction.Has(tx.pkey(key))
+}
+
+// Get calls tx.Get with the prefixed key.
+func (tx *tableTransaction) Get(key string) (string, error) {
+	value, err := tx.Transaction.Get(tx.pkey(key))
+	return value, stripErrorPrefix(err, tx.prefix)
+}
+
+// GetBytes calls tx.GetBytes with the prefixed key.
+func (tx *tableTransaction) GetBytes(key string) ([]byte, error) {
+	value, err := tx.Transaction.GetBytes(tx.pkey(key))
+	return value, stripErrorPrefix(err, tx.prefix)
+}
+
+// Put calls tx.Put with the prefixed key.
+func (tx *tableTransaction) Put(key, value string) error {
+	return tx.Transaction.Put(tx.pkey(key), value)
+}
+
+// PutBytes calls tx.PutBytes with the prefixed key.
+func (tx *tableTransaction) PutBytes(key string, value []byte) error {
+	return tx.Transaction.PutBytes(tx.pkey(key), value)
+}
+
+// Delete calls tx.Delete with the prefixed key.
+func (tx *tableTransaction) Delete(key string) error {
+	return stripErrorPrefix(tx.Transaction.Delete(tx.pkey(key)), tx.prefix)
+}
+
+// NewIterator creates a new table iterator.
+func (tx *tableTransaction) NewIterator() Iterator {
+	return newTableIteratorWithPrefix(tx.Transaction, tx.prefix, "")
+}
+
+// NewIteratorWithRange creates a new ranged iterator.
+func (tx *tableTransaction) NewIteratorWithRange(start string, end string) Iterator {
+	return newTableIteratorWithRange(tx.Transaction, tx.prefix, start, end)
+}
+
+// NewIteratorWithPrefix creates a new iterator for a prefix.
+func (tx *tableTransaction) NewIteratorWithPrefix(prefix string) Iterator {
+	return newTableIteratorWithPrefix(tx.Transaction, tx.prefix, prefix)
+}
+
+// Commit commits the transaction. The key of a returned *ConflictError is
+// relative to the table.
+func (tx *tableTransaction) Commit() error {
+	return stripErrorPrefix(tx.Transaction.Commit(), tx.prefix)
+}
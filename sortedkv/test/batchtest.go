@@ -38,6 +38,15 @@ func GenericBatchTest(t *testing.T, database sortedkv.Database) {
 
 	dbtest.MustNotHave("1234")
 	dbtest.MustGetEqual("5678", "ghjk")
+
+	// Deleting a key that is not present must not make Apply fail.
+	this.Batch.Reset()
+	this.MustDelete("absent")
+	this.MustPut("abcd", "efgh")
+	this.MustApply()
+	dbtest.MustNotHave("absent")
+	dbtest.MustGetEqual("abcd", "efgh")
+	dbtest.Delete("abcd")
 }
 
 // BatchTest tests a batch.
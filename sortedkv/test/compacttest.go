@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericCompactTest provides generic tests for Compacter and Stater
+// implementations. The database must be empty and implement
+// sortedkv.Compacter and sortedkv.Stater.
+func GenericCompactTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	value := strings.Repeat("v", 1000)
+	for i := 0; i < 100; i++ {
+		dbtest.Put("a."+strconv.Itoa(i), value)
+		dbtest.Put("b."+strconv.Itoa(i), value)
+		dbtest.Put("c."+strconv.Itoa(i), value)
+	}
+	for i := 0; i < 100; i++ {
+		dbtest.Delete("c." + strconv.Itoa(i))
+	}
+	mustCompact(t, database, "", "")
+
+	total := mustApproximateSize(t, database, "", "")
+	a := mustApproximateSize(t, database, "a.", "a/")
+	b := mustApproximateSize(t, database, "b.", "")
+	if a <= 0 || b <= 0 || a > total || b > total {
+		t.Errorf("ApproximateSize(): Sizes of a (%d) and b (%d) not within (0, %d].\n", a, b, total)
+	}
+	// Compression may shrink the values, but not to nothing.
+	if total < 100 {
+		t.Errorf("ApproximateSize(): Total size %d too small.\n", total)
+	}
+	if size := mustApproximateSize(t, database, "c.", "c/"); size > total/10 {
+		t.Errorf("ApproximateSize(): Size of compacted deleted range is %d.\n", size)
+	}
+	if size := mustApproximateSize(t, database, "d", ""); size != 0 {
+		t.Errorf("ApproximateSize(): Size of empty range is %d.\n", size)
+	}
+
+	table := sortedkv.NewTable(database, "a.")
+	mustCompact(t, table, "", "")
+	if size := mustApproximateSize(t, table, "", ""); size != a {
+		t.Errorf("ApproximateSize(): Table size is %d, expected %d.\n", size, a)
+	}
+	if size := mustApproximateSize(t, sortedkv.NewTable(database, "b."), "", ""); size != b {
+		t.Errorf("ApproximateSize(): Table size is %d, expected %d.\n", size, b)
+	}
+
+	stats, err := stater(t, table).Stats()
+	if err != nil {
+		t.Fatalf("Stats(): Failed with reason %v.\n", err)
+	}
+	if len(stats) == 0 {
+		t.Errorf("Stats(): Expected statistics, got none.\n")
+	}
+}
+
+func mustCompact(t *testing.T, database sortedkv.Database, start, end string) {
+	t.Helper()
+	c, ok := database.(sortedkv.Compacter)
+	if !ok {
+		t.Fatalf("%T does not implement sortedkv.Compacter.\n", database)
+	}
+	if err := c.CompactRange(start, end); err != nil {
+		t.Fatalf("CompactRange(%q, %q): Failed with reason %v.\n", start, end, err)
+	}
+}
+
+func mustApproximateSize(t *testing.T, database sortedkv.Database, start, end string) int64 {
+	t.Helper()
+	size, err := stater(t, database).ApproximateSize(start, end)
+	if err != nil {
+		t.Fatalf("ApproximateSize(%q, %q): Failed with reason %v.\n", start, end, err)
+	}
+	return size
+}
+
+func stater(t *testing.T, database sortedkv.Database) sortedkv.Stater {
+	t.Helper()
+	s, ok := database.(sortedkv.Stater)
+	if !ok {
+		t.Fatalf("%T does not implement sortedkv.Stater.\n", database)
+	}
+	return s
+}
@@ -0,0 +1,396 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCrashed is returned by all operations of a CrashFS and its files after
+// the simulated crash.
+var ErrCrashed = errors.New("simulated crash")
+
+type (
+	// CrashFS is an in-memory file system that simulates a power loss. Every
+	// mutating operation uses up write units: a write uses one unit per byte,
+	// all other mutating operations use one unit. When a crash is scheduled
+	// via CrashAfter, the operation that exceeds the remaining units is cut: a
+	// write only writes the bytes that are left, all other operations are not
+	// executed. From then on, all operations fail with ErrCrashed until
+	// Restart is called.
+	//
+	// Only synced data survives a Restart: the content of a file is reset to
+	// its content at its last Sync, and a Rename is only durable after the
+	// next SyncDir. Creations and removals of files are durable immediately.
+	// RestartTorn additionally keeps a random part of the bytes that were
+	// appended to a file after its last Sync, like a partially written back
+	// page cache.
+	CrashFS struct {
+		mu      sync.Mutex
+		files   map[string]*crashFileData
+		durable map[string]*crashFileData // Files as of the last SyncDir.
+		gen     int                       // Generation, incremented by Restart.
+		budget  int64                     // Remaining write units, negative if no crash is scheduled.
+		used    int64                     // Write units used since the last Restart.
+		crashed bool
+	}
+
+	// CrashFile is an open file of a CrashFS.
+	CrashFile struct {
+		fs     *CrashFS
+		gen    int
+		data   *crashFileData
+		flag   int
+		pos    int64
+		closed bool
+	}
+
+	// crashFileData is the content of a file, which outlives renames and
+	// removals while the file is open.
+	crashFileData struct {
+		bytes  []byte
+		synced []byte // Content at the last Sync.
+	}
+)
+
+// NewCrashFS creates an empty CrashFS without a scheduled crash.
+func NewCrashFS() *CrashFS {
+	return &CrashFS{
+		files:   make(map[string]*crashFileData),
+		durable: make(map[string]*crashFileData),
+		budget:  -1,
+	}
+}
+
+// CrashAfter schedules a crash after n more write units.
+func (fs *CrashFS) CrashAfter(n int64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.budget = n
+}
+
+// Crashed returns whether the simulated crash happened.
+func (fs *CrashFS) Crashed() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.crashed
+}
+
+// Used returns the number of write units that were used since the file system
+// was created or restarted.
+func (fs *CrashFS) Used() int64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.used
+}
+
+// Restart simulates a restart after a power loss. Unsynced data and renames
+// are lost and all files that were opened before become unusable. Scheduled
+// crashes are cancelled.
+func (fs *CrashFS) Restart() {
+	fs.restart(nil)
+}
+
+// RestartTorn is like Restart, but keeps a random prefix of the bytes that
+// were appended to each file after its last Sync.
+func (fs *CrashFS) RestartTorn(rng *rand.Rand) {
+	fs.restart(rng)
+}
+
+func (fs *CrashFS) restart(rng *rand.Rand) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.files = make(map[string]*crashFileData, len(fs.durable))
+	for name, data := range fs.durable {
+		fs.files[name] = data
+		data.restart(rng)
+	}
+	fs.gen++
+	fs.budget = -1
+	fs.used = 0
+	fs.crashed = false
+}
+
+// restart resets the content to the synced content. If rng is not nil and
+// the content only appended to the synced content, a random prefix of the
+// appended bytes is kept.
+func (d *crashFileData) restart(rng *rand.Rand) {
+	keep := len(d.synced)
+	if rng != nil && len(d.bytes) > keep && bytes.Equal(d.bytes[:keep], d.synced) {
+		keep += rng.Intn(len(d.bytes) - keep + 1)
+		d.synced = append(d.synced, d.bytes[len(d.synced):keep]...)
+	}
+	d.bytes = append([]byte(nil), d.synced...)
+}
+
+// Names returns the sorted names of all files.
+func (fs *CrashFS) Names() ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.crashed {
+		return nil, ErrCrashed
+	}
+	names := make([]string, 0, len(fs.files))
+	for name := range fs.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// OpenFile opens the named file like os.OpenFile. The permissions are
+// ignored.
+func (fs *CrashFS) OpenFile(name string, flag int, _ os.FileMode) (*CrashFile, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.crashed {
+		return nil, ErrCrashed
+	}
+	data, ok := fs.files[name]
+	switch {
+	case ok && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !ok || flag&os.O_TRUNC != 0:
+		if err := fs.use(1); err != nil {
+			return nil, err
+		}
+		if !ok {
+			data = new(crashFileData)
+			fs.files[name] = data
+			fs.durable[name] = data
+		}
+		data.bytes = nil
+	}
+	return &CrashFile{fs: fs, gen: fs.gen, data: data, flag: flag}, nil
+}
+
+// Rename renames a file like os.Rename. The rename is durable after the next
+// SyncDir.
+func (fs *CrashFS) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.crashed {
+		return ErrCrashed
+	}
+	data, ok := fs.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	if err := fs.use(1); err != nil {
+		return err
+	}
+	delete(fs.files, oldpath)
+	fs.files[newpath] = data
+	return nil
+}
+
+// Remove removes a file like os.Remove.
+func (fs *CrashFS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.crashed {
+		return ErrCrashed
+	}
+	if _, ok := fs.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if err := fs.use(1); err != nil {
+		return err
+	}
+	delete(fs.files, name)
+	delete(fs.durable, name)
+	return nil
+}
+
+// SyncDir makes all renames durable. The directory name is ignored, since the
+// file system has only one directory.
+func (fs *CrashFS) SyncDir(string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.crashed {
+		return ErrCrashed
+	}
+	if err := fs.use(1); err != nil {
+		return err
+	}
+	fs.durable = make(map[string]*crashFileData, len(fs.files))
+	for name, data := range fs.files {
+		fs.durable[name] = data
+	}
+	return nil
+}
+
+// use uses up n write units. If less than n units are left, it uses up the
+// remaining units, crashes and returns ErrCrashed. fs.mu must be held.
+func (fs *CrashFS) use(n int64) error {
+	_, err := fs.useUpTo(n)
+	return err
+}
+
+// useUpTo is like use, but returns the number of units that may be used
+// before the crash.
+func (fs *CrashFS) useUpTo(n int64) (int64, error) {
+	if fs.budget < 0 {
+		fs.used += n
+		return n, nil
+	}
+	if n <= fs.budget {
+		fs.budget -= n
+		fs.used += n
+		return n, nil
+	}
+	n, fs.budget = fs.budget, 0
+	fs.used += n
+	fs.crashed = true
+	return n, ErrCrashed
+}
+
+// check returns an error if the file cannot be used. f.fs.mu must be held.
+func (f *CrashFile) check() error {
+	if f.fs.crashed || f.gen != f.fs.gen {
+		return ErrCrashed
+	}
+	if f.closed {
+		return os.ErrClosed
+	}
+	return nil
+}
+
+// Read reads from the file like os.File.Read.
+func (f *CrashFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	n, err := f.readAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// ReadAt reads from the file like os.File.ReadAt.
+func (f *CrashFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	n, err := f.readAt(p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *CrashFile) readAt(p []byte, off int64) (int, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		return 0, errors.New("file not opened for reading")
+	}
+	if off >= int64(len(f.data.bytes)) {
+		return 0, io.EOF
+	}
+	return copy(p, f.data.bytes[off:]), nil
+}
+
+// Write writes to the file like os.File.Write.
+func (f *CrashFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, errors.New("file not opened for writing")
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.pos = int64(len(f.data.bytes))
+	}
+	n, err := f.fs.useUpTo(int64(len(p)))
+	if end := f.pos + n; end > int64(len(f.data.bytes)) {
+		f.data.bytes = append(f.data.bytes, make([]byte, end-int64(len(f.data.bytes)))...)
+	}
+	copy(f.data.bytes[f.pos:], p[:n])
+	f.pos += n
+	return int(n), err
+}
+
+// Seek sets the offset of the file like os.File.Seek.
+func (f *CrashFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(len(f.data.bytes))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file like os.File.Truncate.
+func (f *CrashFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check(); err != nil {
+		return err
+	}
+	if err := f.fs.use(1); err != nil {
+		return err
+	}
+	if size <= int64(len(f.data.bytes)) {
+		f.data.bytes = f.data.bytes[:size:size]
+	} else {
+		f.data.bytes = append(f.data.bytes, make([]byte, size-int64(len(f.data.bytes)))...)
+	}
+	return nil
+}
+
+// Sync makes the current content of the file durable, see CrashFS.
+func (f *CrashFile) Sync() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check(); err != nil {
+		return err
+	}
+	if err := f.fs.use(1); err != nil {
+		return err
+	}
+	f.data.synced = append(f.data.synced[:0], f.data.bytes...)
+	return nil
+}
+
+// Close closes the file.
+func (f *CrashFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check(); err != nil {
+		return err
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,335 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+const (
+	crashTestRounds  = 64
+	crashTestBatches = 48
+	crashTestKeys    = 24
+)
+
+type (
+	// CrashOpenFunc opens the database that is stored in fs. It must create an
+	// empty database if fs contains none.
+	CrashOpenFunc func(fs *CrashFS) (sortedkv.Database, error)
+
+	// crashBatch is a batch of the crash test workload.
+	crashBatch []crashOp
+
+	crashOp struct {
+		kind  crashOpKind
+		key   string
+		value string
+	}
+
+	crashOpKind int
+)
+
+const (
+	crashPut crashOpKind = iota
+	crashDelete
+	crashDeletePrefix
+)
+
+// CrashConsistencyTest tests that a persistent database survives power losses.
+// The database must be stored in the CrashFS that is passed to open. The test
+// creates the database, applies a random workload of batches, crashes the file
+// system at a random point, reopens the database and checks that
+//
+//   - all batches that were applied successfully are durable,
+//   - the batch that was interrupted by the crash is applied completely or not
+//     at all,
+//   - the recovered database is writable and its writes survive a restart.
+//
+// Since the CrashFS drops unsynced data on a restart, the database must sync
+// every write. The workload and the crash points are seeded by pkgtest.Prng.
+func CrashConsistencyTest(t *testing.T, open CrashOpenFunc) {
+	t.Helper()
+	crashTest(t, open, true)
+}
+
+// CrashAtomicityTest is like CrashConsistencyTest for databases that do not
+// sync every write. It only checks that the recovered database contains the
+// batches up to some batch completely and none of the later batches, and that
+// it is writable.
+func CrashAtomicityTest(t *testing.T, open CrashOpenFunc) {
+	t.Helper()
+	crashTest(t, open, false)
+}
+
+func crashTest(t *testing.T, open CrashOpenFunc, durable bool) {
+	t.Helper()
+	rng := pkgtest.Prng(t)
+	batches := genCrashBatches(rng, crashTestBatches)
+
+	// Run the workload without a crash to determine its size.
+	fs := NewCrashFS()
+	if err := mustOpenCrash(t, open, fs).Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+	fs.Restart()
+	db := mustOpenCrash(t, open, fs)
+	prefixDeletes := canDeletePrefix(db)
+	if !prefixDeletes {
+		batches = withoutPrefixDeletes(batches)
+	}
+	for _, b := range batches {
+		if err := b.apply(db); err != nil {
+			t.Fatalf("Batch.Apply(): Failed with reason %v.\n", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+	total := fs.Used()
+
+	for i := 0; i < crashTestRounds; i++ {
+		crashAfter := rng.Int63n(total + 1)
+		final := genCrashBatches(rng, 1)[0]
+		if !prefixDeletes {
+			final = withoutPrefixDeletes([]crashBatch{final})[0]
+		}
+		restartRng := rand.New(rand.NewSource(rng.Int63())) // nolint: gosec
+		t.Run("crash after "+strconv.FormatInt(crashAfter, 10)+" units", func(t *testing.T) {
+			testCrash(t, open, batches, final, crashAfter, restartRng, durable)
+		})
+	}
+}
+
+// testCrash runs the workload until the crash and checks the recovered
+// database. Every other restart after the crash keeps a random part of the
+// unsynced data. If durable is false, the recovered database may lack any
+// number of the last successfully applied batches.
+func testCrash(t *testing.T, open CrashOpenFunc, batches []crashBatch, final crashBatch, crashAfter int64, rng *rand.Rand, durable bool) {
+	t.Helper()
+	// The database is created before the crash is scheduled, since not every
+	// backend recovers from a crash during the creation of a database.
+	fs := NewCrashFS()
+	if err := mustOpenCrash(t, open, fs).Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+	fs.Restart()
+	fs.CrashAfter(crashAfter)
+
+	// States after each successfully applied batch, starting with the empty
+	// state, and the state with the interrupted batch.
+	states := []map[string]string{{}}
+	var pending crashBatch
+	if db, err := open(fs); err != nil {
+		mustBeCrash(t, fs, "Open()", err)
+	} else {
+		for _, b := range batches {
+			if err := b.apply(db); err != nil {
+				mustBeCrash(t, fs, "Batch.Apply()", err)
+				pending = b
+				break
+			}
+			state := copyMap(states[len(states)-1])
+			b.applyTo(state)
+			states = append(states, state)
+		}
+		// Closing fails if the crash happened already.
+		_ = db.Close()
+	}
+	committed := states[len(states)-1]
+	withPending := copyMap(committed)
+	pending.applyTo(withPending)
+	allowed := []map[string]string{committed, withPending}
+	if !durable {
+		allowed = append(allowed, states[:len(states)-1]...)
+	}
+
+	restart(fs, rng)
+	db := mustOpenCrash(t, open, fs)
+	recovered := ReadAll(t, db)
+	if !containsState(allowed, recovered) {
+		if durable {
+			t.Fatalf("Recovered state %s is neither the committed state %s nor "+
+				"the committed state with the interrupted batch %s.\n",
+				describeState(recovered), describeState(committed), describeState(withPending))
+		}
+		t.Fatalf("Recovered state %s is not the state after any prefix of the "+
+			"batches up to the committed state with the interrupted batch %s.\n",
+			describeState(recovered), describeState(withPending))
+	}
+
+	if err := final.apply(db); err != nil {
+		t.Fatalf("Batch.Apply(): Failed after recovery with reason %v.\n", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close(): Failed after recovery with reason %v.\n", err)
+	}
+	withFinal := copyMap(recovered)
+	final.applyTo(withFinal)
+	allowed = []map[string]string{withFinal}
+	if !durable {
+		allowed = append(allowed, recovered)
+	}
+
+	restart(fs, rng)
+	db = mustOpenCrash(t, open, fs)
+	defer db.Close()
+	if state := ReadAll(t, db); !containsState(allowed, state) {
+		t.Fatalf("State after restart %s does not equal state %s before.\n",
+			describeState(state), describeState(withFinal))
+	}
+}
+
+// restart restarts the file system and keeps a random part of the unsynced
+// data in every other restart.
+func restart(fs *CrashFS, rng *rand.Rand) {
+	if rng.Intn(2) == 0 {
+		fs.Restart()
+	} else {
+		fs.RestartTorn(rng)
+	}
+}
+
+func containsState(states []map[string]string, state map[string]string) bool {
+	for _, s := range states {
+		if reflect.DeepEqual(s, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// genCrashBatches generates n random batches.
+func genCrashBatches(rng *rand.Rand, n int) []crashBatch {
+	batches := make([]crashBatch, n)
+	for i := range batches {
+		batch := make(crashBatch, 1+rng.Intn(8)) // nolint: gomnd
+		for j := range batch {
+			key := "k" + strconv.Itoa(rng.Intn(crashTestKeys))
+			switch r := rng.Intn(16); { // nolint: gomnd
+			case r == 0:
+				batch[j] = crashOp{kind: crashDeletePrefix, key: key[:2]}
+			case r < 4:
+				batch[j] = crashOp{kind: crashDelete, key: key}
+			default:
+				value := make([]byte, rng.Intn(1<<rng.Intn(12))) // nolint: gomnd
+				rng.Read(value)
+				batch[j] = crashOp{kind: crashPut, key: key, value: string(value)}
+			}
+		}
+		batches[i] = batch
+	}
+	return batches
+}
+
+// canDeletePrefix returns whether the batches of the database support prefix
+// deletions. Batches of wrappers may implement RangeDeleter, but fail with a
+// *sortedkv.NotSupportedError, so a prefix deletion is tried on a batch that
+// is not applied.
+func canDeletePrefix(db sortedkv.Database) bool {
+	batch := db.NewBatch()
+	defer batch.Reset()
+	rangeDeleter, ok := batch.(sortedkv.RangeDeleter)
+	return ok && !sortedkv.IsNotSupported(rangeDeleter.DeletePrefix(""))
+}
+
+// withoutPrefixDeletes returns the batches without their prefix deletions.
+func withoutPrefixDeletes(batches []crashBatch) []crashBatch {
+	stripped := make([]crashBatch, len(batches))
+	for i, b := range batches {
+		for _, o := range b {
+			if o.kind != crashDeletePrefix {
+				stripped[i] = append(stripped[i], o)
+			}
+		}
+	}
+	return stripped
+}
+
+// apply applies the batch to the database. The batches of the database must
+// support prefix deletions if the batch contains any, see canDeletePrefix.
+func (b crashBatch) apply(db sortedkv.Database) (err error) {
+	batch := db.NewBatch()
+	for _, o := range b {
+		switch o.kind {
+		case crashPut:
+			err = batch.Put(o.key, o.value)
+		case crashDelete:
+			err = batch.Delete(o.key)
+		case crashDeletePrefix:
+			err = batch.(sortedkv.RangeDeleter).DeletePrefix(o.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Apply()
+}
+
+// applyTo applies the batch to the model state. It must be consistent with
+// apply.
+func (b crashBatch) applyTo(state map[string]string) {
+	for _, o := range b {
+		switch o.kind {
+		case crashPut:
+			state[o.key] = o.value
+		case crashDelete:
+			delete(state, o.key)
+		case crashDeletePrefix:
+			for key := range state {
+				if len(key) >= len(o.key) && key[:len(o.key)] == o.key {
+					delete(state, key)
+				}
+			}
+		}
+	}
+}
+
+func mustOpenCrash(t *testing.T, open CrashOpenFunc, fs *CrashFS) sortedkv.Database {
+	t.Helper()
+	db, err := open(fs)
+	if err != nil {
+		t.Fatalf("Open(): Failed with reason %v.\n", err)
+	}
+	return db
+}
+
+func mustBeCrash(t *testing.T, fs *CrashFS, op string, err error) {
+	t.Helper()
+	if !fs.Crashed() {
+		t.Fatalf("%s: Failed without a crash with reason %v.\n", op, err)
+	}
+}
+
+// describeState describes a state by its keys and the lengths and checksums of
+// its values, which are binary.
+func describeState(state map[string]string) string {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s:%d/%08x ", key, len(state[key]), crc32.ChecksumIEEE([]byte(state[key])))
+	}
+	return "[" + strings.TrimSpace(b.String()) + "]"
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
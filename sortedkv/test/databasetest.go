@@ -10,6 +10,8 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/pkg/errors"
+
 	"polycry.pt/poly-go/sortedkv"
 )
 
@@ -34,6 +36,46 @@ func GenericDatabaseTest(t *testing.T, database sortedkv.Database) {
 	})
 }
 
+// GenericClosedDatabaseTest closes the database and tests that all further
+// operations fail with a *sortedkv.ClosedError. The database must not be used
+// afterwards.
+func GenericClosedDatabaseTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	d := DatabaseTest{T: t, Database: database}
+	d.Put("1234", "qwer")
+	batch := database.NewBatch()
+	if err := batch.Put("5678", "asdf"); err != nil {
+		t.Fatalf("Batch.Put(): Failed with reason %v.\n", err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+
+	_, err := database.Has("1234")
+	d.mustBeClosed("Has()", err)
+	_, err = database.Get("1234")
+	d.mustBeClosed("Get()", err)
+	_, err = database.GetBytes("1234")
+	d.mustBeClosed("GetBytes()", err)
+	d.mustBeClosed("Put()", database.Put("1234", "qwer"))
+	d.mustBeClosed("PutBytes()", database.PutBytes("1234", []byte("qwer")))
+	d.mustBeClosed("Delete()", database.Delete("1234"))
+	d.mustBeClosed("Batch.Apply()", batch.Apply())
+
+	it := database.NewIterator()
+	if it.Next() {
+		t.Errorf("Next(): Expected end on closed database, but got [%q].\n", it.Key())
+	}
+	d.mustBeClosed("Iterator.Close()", it.Close())
+}
+
+// mustBeClosed tests that err is a *sortedkv.ClosedError.
+func (d *DatabaseTest) mustBeClosed(op string, err error) {
+	if !sortedkv.IsClosed(err) {
+		d.Errorf("%s should have failed with a ClosedError, but got: %v\n", op, err)
+	}
+}
+
 // test Tests a generic database.
 func (d *DatabaseTest) test() {
 	if d.T == nil {
@@ -137,10 +179,27 @@ func (d *DatabaseTest) GetBytes(key string) []byte {
 	return value
 }
 
-// MustFailGet tests the get functionality.
+// MustFailGet tests that Get and GetBytes fail with a *sortedkv.NotFoundError.
 func (d *DatabaseTest) MustFailGet(key string) {
 	if _, err := d.Database.Get(key); err == nil {
 		d.Errorf("Get() did not fail when expected to ([%q]).\n", key)
+	} else {
+		d.mustBeNotFound("Get()", key, err)
+	}
+	if _, err := d.Database.GetBytes(key); err == nil {
+		d.Errorf("GetBytes() did not fail when expected to ([%q]).\n", key)
+	} else {
+		d.mustBeNotFound("GetBytes()", key, err)
+	}
+}
+
+// mustBeNotFound tests that err is a *sortedkv.NotFoundError for key.
+func (d *DatabaseTest) mustBeNotFound(op string, key string, err error) {
+	var notFound *sortedkv.NotFoundError
+	if !errors.As(err, &notFound) {
+		d.Errorf("%s [%q] should have failed with a NotFoundError, but got: %v\n", op, key, err)
+	} else if notFound.Key != key {
+		d.Errorf("%s [%q] failed with a NotFoundError for the wrong key %q\n", op, key, notFound.Key)
 	}
 }
 
@@ -173,9 +232,11 @@ func (d *DatabaseTest) Delete(key string) {
 	}
 }
 
-// MustFailDelete tests the delete functionality.
+// MustFailDelete tests that Delete fails with a *sortedkv.NotFoundError.
 func (d *DatabaseTest) MustFailDelete(key string) {
 	if err := d.Database.Delete(key); err == nil {
 		d.Errorf("Delete() [%q] should have failed, but did not.\n", key)
+	} else {
+		d.mustBeNotFound("Delete()", key, err)
 	}
 }
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericDecoratorTest runs the generic tests of all interfaces that a full
+// decorator of a database, like a cache, supports: the database, closed
+// database, batch, iterator, seekable iterator, snapshot, table, transaction
+// and range deletion tests. Every test runs on a new, empty database that
+// newDB creates.
+func GenericDecoratorTest(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	for _, test := range []struct {
+		name string
+		run  func(*testing.T, sortedkv.Database)
+	}{
+		{"Database", GenericDatabaseTest},
+		{"Closed", GenericClosedDatabaseTest},
+		{"Batch", GenericBatchTest},
+		{"Iterator", GenericIteratorTest},
+		{"SeekableIterator", GenericSeekableIteratorTest},
+		{"Snapshot", GenericSnapshotTest},
+		{"Table", GenericTableTest},
+		{"Transaction", GenericTransactionTest},
+		{"RangeDelete", GenericRangeDeleteTest},
+	} {
+		run := test.run
+		t.Run(test.name, func(t *testing.T) {
+			run(t, newDB())
+		})
+	}
+}
@@ -69,13 +69,169 @@ func GenericIteratorTest(t *testing.T, database sortedkv.Database) {
 	it.MustEnd()
 }
 
+// ReadAll reads all entries of db into a map. It fails the test if Value and
+// ValueBytes of an entry differ or the iterator fails.
+func ReadAll(t *testing.T, db sortedkv.Iterable) map[string]string {
+	t.Helper()
+	entries := make(map[string]string)
+	it := db.NewIterator()
+	for it.Next() {
+		if value := string(it.ValueBytes()); value != it.Value() {
+			t.Errorf("ValueBytes(): Expected %q, but got %q.\n", it.Value(), value)
+		}
+		entries[it.Key()] = it.Value()
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Iterator.Close(): Failed with reason %v.\n", err)
+	}
+	return entries
+}
+
+// GenericSeekableIteratorTest provides generic tests for iterators that
+// implement sortedkv.SeekableIterator. The database must be empty.
+func GenericSeekableIteratorTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.Put("2b", "2bv")
+	dbtest.Put("3", "3v")
+	dbtest.Put("1", "1v")
+	dbtest.Put("2a", "2av")
+
+	// Test backwards iteration over all elements.
+	it := IteratorTest{T: t, Iterator: database.NewIterator()}
+	it.LastMustEqual("3", "3v")
+	it.PrevMustEqual("2b", "2bv")
+	it.PrevMustEqual("2a", "2av")
+	it.PrevMustEqual("1", "1v")
+	it.MustNotPrev()
+	// Next after the start must restart the iteration.
+	it.NextMustEqual("1", "1v")
+	it.NextMustEqual("2a", "2av")
+	// Change of direction.
+	it.PrevMustEqual("1", "1v")
+	it.FirstMustEqual("1", "1v")
+	it.SeekMustEqual("2", "2a", "2av")
+	it.NextMustEqual("2b", "2bv")
+	it.SeekMustEqual("2b", "2b", "2bv")
+	it.SeekMustEqual("", "1", "1v")
+	it.MustNotSeek("4")
+	// Prev after the end must restart the iteration from the back.
+	it.PrevMustEqual("3", "3v")
+	it.MustEnd()
+
+	// Test [..., "2b") backwards.
+	it.Iterator = database.NewIteratorWithRange("", "2b")
+	it.LastMustEqual("2a", "2av")
+	it.PrevMustEqual("1", "1v")
+	it.MustNotPrev()
+	it.Close()
+
+	// Test seeking outside of ["2", "3").
+	it.Iterator = database.NewIteratorWithRange("2", "3")
+	it.SeekMustEqual("1", "2a", "2av")
+	it.MustNotSeek("3")
+	it.LastMustEqual("2b", "2bv")
+	it.Close()
+
+	// Test "2"+... backwards.
+	it.Iterator = database.NewIteratorWithPrefix("2")
+	it.LastMustEqual("2b", "2bv")
+	it.PrevMustEqual("2a", "2av")
+	it.MustNotPrev()
+	it.Close()
+
+	// Test an empty range.
+	it.Iterator = database.NewIteratorWithPrefix("4")
+	it.MustNotFirst()
+	it.MustNotLast()
+	it.MustNotSeek("")
+	it.Close()
+}
+
+// seekable returns the tested iterator as sortedkv.SeekableIterator.
+func (i *IteratorTest) seekable() sortedkv.SeekableIterator {
+	it, ok := i.Iterator.(sortedkv.SeekableIterator)
+	if !ok {
+		i.Fatalf("Iterator does not implement sortedkv.SeekableIterator.\n")
+	}
+	return it
+}
+
+// PrevMustEqual tests the prev method.
+func (i *IteratorTest) PrevMustEqual(key, value string) {
+	if !i.seekable().Prev() {
+		i.Errorf("Prev(): Expected [%q] = %q, but iterator ended.\n", key, value)
+		return
+	}
+	i.currentMustEqual(key, value)
+}
+
+// FirstMustEqual tests the first method.
+func (i *IteratorTest) FirstMustEqual(key, value string) {
+	if !i.seekable().First() {
+		i.Errorf("First(): Expected [%q] = %q, but iterator is empty.\n", key, value)
+		return
+	}
+	i.currentMustEqual(key, value)
+}
+
+// LastMustEqual tests the last method.
+func (i *IteratorTest) LastMustEqual(key, value string) {
+	if !i.seekable().Last() {
+		i.Errorf("Last(): Expected [%q] = %q, but iterator is empty.\n", key, value)
+		return
+	}
+	i.currentMustEqual(key, value)
+}
+
+// SeekMustEqual tests the seek method.
+func (i *IteratorTest) SeekMustEqual(seek, key, value string) {
+	if !i.seekable().Seek(seek) {
+		i.Errorf("Seek(%q): Expected [%q] = %q, but iterator ended.\n", seek, key, value)
+		return
+	}
+	i.currentMustEqual(key, value)
+}
+
+// MustNotPrev tests that the prev method returns false.
+func (i *IteratorTest) MustNotPrev() {
+	if i.seekable().Prev() {
+		i.Errorf("Prev(): Expected start, but got [%q] = %q.\n", i.Iterator.Key(), i.Iterator.Value())
+	}
+}
+
+// MustNotFirst tests that the first method returns false.
+func (i *IteratorTest) MustNotFirst() {
+	if i.seekable().First() {
+		i.Errorf("First(): Expected empty iterator, but got [%q] = %q.\n", i.Iterator.Key(), i.Iterator.Value())
+	}
+}
+
+// MustNotLast tests that the last method returns false.
+func (i *IteratorTest) MustNotLast() {
+	if i.seekable().Last() {
+		i.Errorf("Last(): Expected empty iterator, but got [%q] = %q.\n", i.Iterator.Key(), i.Iterator.Value())
+	}
+}
+
+// MustNotSeek tests that the seek method returns false.
+func (i *IteratorTest) MustNotSeek(seek string) {
+	if i.seekable().Seek(seek) {
+		i.Errorf("Seek(%q): Expected end, but got [%q] = %q.\n", seek, i.Iterator.Key(), i.Iterator.Value())
+	}
+}
+
 // NextMustEqual tests the next method.
 func (i *IteratorTest) NextMustEqual(key, value string) {
 	if !i.Iterator.Next() {
 		i.Errorf("Next(): Expected [%q] = %q, but iterator ended.\n", key, value)
 		return
 	}
+	i.currentMustEqual(key, value)
+}
 
+// currentMustEqual tests the current key/value pair of the iterator.
+func (i *IteratorTest) currentMustEqual(key, value string) {
 	if actual := i.Iterator.Value(); actual != value {
 		i.Errorf("Value(): Expected %q, but got %q.\n", value, actual)
 	}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericListTest provides generic tests for sortedkv.List on a database. The
+// database must be empty. List is tested with the iterators of the database,
+// with iterators that cannot seek and on a table of the database.
+func GenericListTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("List", func(t *testing.T) {
+		testList(t, database, database)
+	})
+	t.Run("List without seeking", func(t *testing.T) {
+		testList(t, database, unseekable{database})
+	})
+	t.Run("List table", func(t *testing.T) {
+		table := sortedkv.NewTable(database, "Table.")
+		testList(t, table, table)
+	})
+}
+
+// unseekable hides that the iterators of an Iterable are seekable.
+type unseekable struct {
+	sortedkv.Iterable
+}
+
+type unseekableIterator struct {
+	sortedkv.Iterator
+}
+
+func (u unseekable) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return unseekableIterator{u.Iterable.NewIteratorWithPrefix(prefix)}
+}
+
+// testList tests List on iterable, which reads the database.
+func testList(t *testing.T, database sortedkv.Database, iterable sortedkv.Iterable) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.Put("o", "ov")
+	dbtest.Put("q", "qv")
+	for i := 0; i < 10; i++ {
+		dbtest.Put(listKey(i), listKey(i)+"v")
+	}
+
+	list := func(cursor string, limit int) *sortedkv.Page {
+		page, err := sortedkv.List(iterable, "p/", cursor, limit)
+		if err != nil {
+			t.Fatalf("List(%q, %d): Failed with reason %v.\n", cursor, limit, err)
+		}
+		return page
+	}
+
+	// Forward through all pages and back again.
+	var pages []*sortedkv.Page
+	for cursor := sortedkv.FirstPage; ; {
+		page := list(cursor, 3)
+		pages = append(pages, page)
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	mustHavePages(t, "forward", pages, [][]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, {9}})
+	if pages[0].Prev != "" {
+		t.Errorf("First page: Expected no previous page.\n")
+	}
+	mustHavePage(t, "previous of the second page", list(pages[1].Prev, 3), []int{0, 1, 2})
+	mustHavePage(t, "previous of the last page", list(pages[3].Prev, 2), []int{7, 8})
+
+	// Backward from the last page.
+	pages = nil
+	for cursor := sortedkv.LastPage; ; {
+		page := list(cursor, 3)
+		pages = append(pages, page)
+		if page.Prev == "" {
+			break
+		}
+		cursor = page.Prev
+	}
+	mustHavePages(t, "backward", pages, [][]int{{7, 8, 9}, {4, 5, 6}, {1, 2, 3}, {0}})
+	if pages[0].Next != "" {
+		t.Errorf("Last page: Expected no next page.\n")
+	}
+	mustHavePage(t, "next of the first page", list(pages[3].Next, 3), []int{1, 2, 3})
+	mustHavePage(t, "large page", list(sortedkv.FirstPage, 100), []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+	// Pages stay consistent under writes between the pages.
+	first := list(sortedkv.FirstPage, 3)
+	dbtest.Put("p/025", "p/025v")
+	dbtest.Delete(listKey(3))
+	dbtest.Delete(listKey(1))
+	second := list(first.Next, 3)
+	if len(second.Entries) != 3 || second.Entries[0].Key != "p/025" || second.Entries[1].Key != listKey(4) {
+		t.Errorf("Page after writes: Expected [p/025 p/04 p/05], got %v.\n", second.Entries)
+	}
+	mustHavePage(t, "previous after writes", list(second.Prev, 3), []int{0, 2})
+	dbtest.Delete("p/025")
+	dbtest.Put(listKey(1), listKey(1)+"v")
+	dbtest.Put(listKey(3), listKey(3)+"v")
+
+	// Empty listings.
+	for _, cursor := range []string{sortedkv.FirstPage, sortedkv.LastPage} {
+		page, err := sortedkv.List(iterable, "x", cursor, 3)
+		if err != nil || len(page.Entries) != 0 || page.Next != "" || page.Prev != "" {
+			t.Errorf("List(x, %q): Expected an empty page, got %v, %v.\n", cursor, page, err)
+		}
+	}
+
+	// Invalid arguments.
+	for _, cursor := range []string{"!", "eA", sortedkv.LastPage + "A"} {
+		if _, err := sortedkv.List(iterable, "p/", cursor, 3); err == nil {
+			t.Errorf("List(%q): Expected an error for an invalid cursor.\n", cursor)
+		}
+	}
+	if _, err := sortedkv.List(iterable, "p/", sortedkv.FirstPage, 0); err == nil {
+		t.Errorf("List(): Expected an error for limit 0.\n")
+	}
+
+	for _, key := range []string{"o", "q"} {
+		dbtest.Delete(key)
+	}
+	for i := 0; i < 10; i++ {
+		dbtest.Delete(listKey(i))
+	}
+}
+
+func listKey(i int) string {
+	return fmt.Sprintf("p/%02d", i)
+}
+
+func mustHavePages(t *testing.T, name string, pages []*sortedkv.Page, expected [][]int) {
+	t.Helper()
+	if len(pages) != len(expected) {
+		t.Fatalf("%s: Expected %d pages, got %d.\n", name, len(expected), len(pages))
+	}
+	for i, page := range pages {
+		mustHavePage(t, fmt.Sprintf("%s page %d", name, i), page, expected[i])
+	}
+}
+
+func mustHavePage(t *testing.T, name string, page *sortedkv.Page, expected []int) {
+	t.Helper()
+	if len(page.Entries) != len(expected) {
+		t.Fatalf("%s: Expected %d entries, got %v.\n", name, len(expected), page.Entries)
+	}
+	for i, e := range page.Entries {
+		if e.Key != listKey(expected[i]) || e.Value != listKey(expected[i])+"v" {
+			t.Errorf("%s: Expected entry %d to be %s, got %v.\n", name, i, listKey(expected[i]), e)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+// mergeEntry is a key/value pair that a MergeIterator yielded, together with
+// the index of its source iterator.
+type mergeEntry struct {
+	key, value string
+	source     int
+}
+
+// GenericMergeIteratorTest provides generic tests for sortedkv.MergeIterator
+// on the iterators of databases that newDB creates. newDB must return a new,
+// empty database on every call.
+func GenericMergeIteratorTest(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	t.Run("Precedence", func(t *testing.T) {
+		testMergePrecedence(t, newDB)
+	})
+	t.Run("Close", func(t *testing.T) {
+		testMergeClose(t, newDB)
+	})
+	t.Run("Random", func(t *testing.T) {
+		testMergeRandom(t, newDB)
+	})
+}
+
+// testMergePrecedence tests the precedences on overlapping databases.
+func testMergePrecedence(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	dbs := []sortedkv.Database{
+		withData(t, newDB(), map[string]string{"a": "0", "c": "0", "d": "0"}),
+		withData(t, newDB(), map[string]string{"b": "1", "c": "1"}),
+		newDB(),
+		withData(t, newDB(), map[string]string{"c": "3", "d": "3", "e": "3"}),
+	}
+	merge := func(p sortedkv.Precedence) *sortedkv.MergeIterator {
+		its := make([]sortedkv.Iterator, len(dbs))
+		for i, db := range dbs {
+			its[i] = db.NewIterator()
+		}
+		return sortedkv.NewMergeIterator(p, its...)
+	}
+
+	mustMergeEqual(t, "FirstWins", []mergeEntry{
+		{"a", "0", 0}, {"b", "1", 1}, {"c", "0", 0}, {"d", "0", 0}, {"e", "3", 3},
+	}, readMerge(t, merge(sortedkv.FirstWins)))
+	mustMergeEqual(t, "LastWins", []mergeEntry{
+		{"a", "0", 0}, {"b", "1", 1}, {"c", "3", 3}, {"d", "3", 3}, {"e", "3", 3},
+	}, readMerge(t, merge(sortedkv.LastWins)))
+	mustMergeEqual(t, "KeepAll", []mergeEntry{
+		{"a", "0", 0}, {"b", "1", 1}, {"c", "0", 0}, {"c", "1", 1}, {"c", "3", 3},
+		{"d", "0", 0}, {"d", "3", 3}, {"e", "3", 3},
+	}, readMerge(t, merge(sortedkv.KeepAll)))
+	mustMergeEqual(t, "no iterators", nil, readMerge(t, sortedkv.NewMergeIterator(sortedkv.FirstWins)))
+}
+
+// testMergeClose tests that closing stops the iteration and reports the errors
+// of the iterators.
+func testMergeClose(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	db := withData(t, newDB(), map[string]string{"a": "0"})
+	closed := newDB()
+	if err := closed.Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+
+	it := sortedkv.NewMergeIterator(sortedkv.FirstWins, db.NewIterator(), closed.NewIterator())
+	if !it.Next() {
+		t.Fatalf("Next(): Expected [\"a\"], but iterator ended.\n")
+	}
+	if err := it.Close(); !sortedkv.IsClosed(err) {
+		t.Errorf("Close() should have failed with a ClosedError, but got: %v\n", err)
+	}
+	if it.Next() {
+		t.Errorf("Next(): Expected end after Close(), but got [%q].\n", it.Key())
+	}
+}
+
+// testMergeRandom compares the merge of random databases with the expected
+// entries for every precedence.
+func testMergeRandom(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	rng := pkgtest.Prng(t)
+	for _, p := range []sortedkv.Precedence{sortedkv.FirstWins, sortedkv.LastWins, sortedkv.KeepAll} {
+		var (
+			its      []sortedkv.Iterator
+			expected []mergeEntry
+			winners  = make(map[string]mergeEntry)
+		)
+		for i := 0; i < 1+rng.Intn(8); i++ {
+			data := make(map[string]string)
+			for j := 0; j < rng.Intn(50); j++ {
+				key := strconv.Itoa(rng.Intn(100))
+				data[key] = strconv.Itoa(i)
+				if _, ok := winners[key]; !ok || p == sortedkv.LastWins {
+					winners[key] = mergeEntry{key, data[key], i}
+				}
+			}
+			if p == sortedkv.KeepAll {
+				for key, value := range data {
+					expected = append(expected, mergeEntry{key, value, i})
+				}
+			}
+			its = append(its, withData(t, newDB(), data).NewIterator())
+		}
+		if p != sortedkv.KeepAll {
+			for _, e := range winners {
+				expected = append(expected, e)
+			}
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			if expected[i].key != expected[j].key {
+				return expected[i].key < expected[j].key
+			}
+			return expected[i].source < expected[j].source
+		})
+
+		entries := readMerge(t, sortedkv.NewMergeIterator(p, its...))
+		mustMergeEqual(t, fmt.Sprintf("precedence %d", p), expected, entries)
+	}
+}
+
+// readMerge reads all entries of the iterator and closes it.
+func readMerge(t *testing.T, it *sortedkv.MergeIterator) []mergeEntry {
+	t.Helper()
+	var entries []mergeEntry
+	for it.Next() {
+		if value := string(it.ValueBytes()); value != it.Value() {
+			t.Errorf("ValueBytes(): Expected %q, but got %q.\n", it.Value(), value)
+		}
+		entries = append(entries, mergeEntry{it.Key(), it.Value(), it.Source()})
+	}
+	if key, source := it.Key(), it.Source(); key != "" || source != -1 {
+		t.Errorf("Key(), Source(): Expected \"\", -1 after the end, but got %q, %d.\n", key, source)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+	return entries
+}
+
+func mustMergeEqual(t *testing.T, name string, expected, actual []mergeEntry) {
+	t.Helper()
+	if len(expected) != len(actual) || (len(expected) > 0 && !reflect.DeepEqual(expected, actual)) {
+		t.Fatalf("%s: Expected entries %v, but got %v.\n", name, expected, actual)
+	}
+}
+
+// withData puts the data into the database and returns it.
+func withData(t *testing.T, database sortedkv.Database, data map[string]string) sortedkv.Database {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	for key, value := range data {
+		dbtest.Put(key, value)
+	}
+	return database
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+// leakyDatabase returns iterators that ignore their range.
+type leakyDatabase struct {
+	sortedkv.Database
+}
+
+// NewIteratorWithRange returns an iterator over all keys of the database.
+func (db leakyDatabase) NewIteratorWithRange(string, string) sortedkv.Iterator {
+	return db.Database.NewIterator()
+}
+
+// GenericRestrictTest provides generic tests for sortedkv.Restrict on
+// databases that newDB creates. newDB must return a new, empty database on
+// every call. The databases must implement sortedkv.RangeDeleter and
+// sortedkv.Snapshotter, and their batches sortedkv.RangeDeleter.
+func GenericRestrictTest(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	unrestricted := sortedkv.Policy{Read: []string{""}, Write: []string{""}}
+	t.Run("Iterator", func(t *testing.T) {
+		GenericIteratorTest(t, sortedkv.Restrict(newDB(), unrestricted))
+	})
+	t.Run("Batch", func(t *testing.T) {
+		GenericBatchTest(t, sortedkv.Restrict(newDB(), unrestricted))
+	})
+	t.Run("RangeDelete", func(t *testing.T) {
+		GenericRangeDeleteTest(t, sortedkv.Restrict(newDB(), unrestricted))
+	})
+	t.Run("Access", func(t *testing.T) {
+		testRestrictAccess(t, newDB())
+	})
+	t.Run("Nested", func(t *testing.T) {
+		testRestrictNested(t, newDB())
+	})
+	t.Run("No leaks", func(t *testing.T) {
+		testRestrictNoLeaks(t, newDB())
+	})
+}
+
+// testRestrictAccess tests that exactly the operations of the policy are
+// allowed.
+func testRestrictAccess(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	withData(t, database, map[string]string{"a/1": "1", "b/1": "2", "c/1": "3"})
+	r := sortedkv.Restrict(database, sortedkv.Policy{
+		Read:  []string{"a/", "b/"},
+		Write: []string{"b/"},
+	})
+
+	rtest := DatabaseTest{T: t, Database: r}
+	rtest.MustGetEqual("a/1", "1")
+	rtest.MustGetEqual("b/1", "2")
+	_, err := r.Get("c/1")
+	mustBeAccessDenied(t, "Get(c/1)", err)
+	_, err = r.GetBytes("c/1")
+	mustBeAccessDenied(t, "GetBytes(c/1)", err)
+	_, err = r.Has("c/1")
+	mustBeAccessDenied(t, "Has(c/1)", err)
+	rtest.MustFailGet("b/2")
+
+	var denied *sortedkv.AccessDeniedError
+	if err := r.Put("a/1", "x"); !errors.As(err, &denied) {
+		t.Fatalf("Put(a/1) should have failed with an AccessDeniedError, but got: %v\n", err)
+	} else if expected := (sortedkv.AccessDeniedError{Op: "Put", Key: "a/1"}); *denied != expected {
+		t.Errorf("Put(a/1): Expected error %+v, but got %+v.\n", expected, *denied)
+	}
+	mustBeAccessDenied(t, "PutBytes(c/2)", r.PutBytes("c/2", nil))
+	mustBeAccessDenied(t, "Delete(a/1)", r.Delete("a/1"))
+	rtest.Put("b/2", "x")
+	rtest.Delete("b/1")
+
+	rd := rangeDeleter(t, r)
+	mustBeAccessDenied(t, "DeleteRange(a/, c/)", rd.DeleteRange("a/", "c/"))
+	mustBeAccessDenied(t, "DeleteRange(b/, )", rd.DeleteRange("b/", ""))
+	mustBeAccessDenied(t, "DeletePrefix()", rd.DeletePrefix(""))
+	mustBeAccessDenied(t, "DeletePrefix(b)", rd.DeletePrefix("b"))
+	if err := rd.DeleteRange("b/", "b0"); err != nil {
+		t.Fatalf("DeleteRange(b/, b0): Failed with reason %v.\n", err)
+	}
+	if err := rd.DeletePrefix("b/x"); err != nil {
+		t.Fatalf("DeletePrefix(b/x): Failed with reason %v.\n", err)
+	}
+
+	batch := BatchTest{T: t, Batch: r.NewBatch()}
+	mustBeAccessDenied(t, "Batch.Put(a/2)", batch.Batch.Put("a/2", "x"))
+	mustBeAccessDenied(t, "Batch.PutBytes(c/2)", batch.Batch.PutBytes("c/2", nil))
+	mustBeAccessDenied(t, "Batch.Delete(c/1)", batch.Batch.Delete("c/1"))
+	mustBeAccessDenied(t, "Batch.DeletePrefix(c/)", rangeDeleter(t, batch.Batch).DeletePrefix("c/"))
+	batch.MustPut("b/3", "y")
+	batch.MustApply()
+
+	// The underlying database only contains the allowed writes and stays
+	// open.
+	mustBeAccessDenied(t, "Close()", r.Close())
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.MustGetEqual("a/1", "1")
+	dbtest.MustGetEqual("b/3", "y")
+	dbtest.MustGetEqual("c/1", "3")
+	dbtest.MustFailGet("b/2")
+
+	// Snapshots keep the read policy.
+	snap, err := r.(sortedkv.Snapshotter).NewSnapshot()
+	if err != nil {
+		t.Fatalf("NewSnapshot(): Failed with reason %v.\n", err)
+	}
+	_, err = snap.Get("c/1")
+	mustBeAccessDenied(t, "Snapshot.Get(c/1)", err)
+	mustReadKeys(t, "Snapshot.NewIterator()", snap.NewIterator(), "a/1", "b/3")
+	if err := snap.Close(); err != nil {
+		t.Fatalf("Snapshot.Close(): Failed with reason %v.\n", err)
+	}
+}
+
+// testRestrictNested tests policies with prefixes that cover each other.
+func testRestrictNested(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	withData(t, database, map[string]string{"a": "", "a/1": "", "a/1/x": "", "a/2": "", "b": ""})
+	r := sortedkv.Restrict(database, sortedkv.Policy{Read: []string{"a/1", "a/", "a/1/"}})
+	mustReadKeys(t, "NewIterator()", r.NewIterator(), "a/1", "a/1/x", "a/2")
+	mustReadKeys(t, "NewIteratorWithPrefix(a/1/)", r.NewIteratorWithPrefix("a/1/"), "a/1/x")
+	mustReadKeys(t, "NewIteratorWithPrefix(b)", r.NewIteratorWithPrefix("b"))
+
+	r = sortedkv.Restrict(database, sortedkv.Policy{})
+	mustReadKeys(t, "NewIterator() without policy", r.NewIterator())
+	mustBeAccessDenied(t, "Put(a) without policy", r.Put("a", ""))
+}
+
+// testRestrictNoLeaks tests that iterators of random policies and ranges
+// yield exactly the readable keys of the range, and never a key that may not
+// be read, even if the underlying iterators ignore their range.
+func testRestrictNoLeaks(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	rng := pkgtest.Prng(t)
+	const alphabet = "ab\xff"
+	randKey := func(n int) string {
+		var b strings.Builder
+		for i := rng.Intn(n + 1); i > 0; i-- {
+			b.WriteByte(alphabet[rng.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+
+	dbtest := DatabaseTest{T: t, Database: database}
+	for i := 0; i < 100; i++ {
+		dbtest.Put(randKey(4), "")
+	}
+
+	for i := 0; i < 100; i++ {
+		var read []string
+		for j := rng.Intn(4); j > 0; j-- {
+			read = append(read, randKey(2))
+		}
+		allowed := func(key string) bool {
+			for _, p := range read {
+				if strings.HasPrefix(key, p) {
+					return true
+				}
+			}
+			return false
+		}
+		start, end := randKey(3), randKey(3)
+
+		var want []string
+		for _, key := range readKeys(t, database.NewIteratorWithRange(start, end)) {
+			if allowed(key) {
+				want = append(want, key)
+			}
+		}
+		sort.Strings(want)
+
+		policy := sortedkv.Policy{Read: read}
+		got := readKeys(t, sortedkv.Restrict(database, policy).NewIteratorWithRange(start, end))
+		if len(want) != len(got) || (len(want) > 0 && !reflect.DeepEqual(want, got)) {
+			t.Fatalf("Read %q, range [%q, %q): Expected keys %q, but got %q.\n", read, start, end, want, got)
+		}
+
+		for _, key := range readKeys(t, sortedkv.Restrict(leakyDatabase{database}, policy).NewIteratorWithRange(start, end)) {
+			if !allowed(key) {
+				t.Fatalf("Read %q, range [%q, %q): Leaked key %q.\n", read, start, end, key)
+			}
+		}
+	}
+}
+
+// readKeys reads all keys of the iterator and closes it.
+func readKeys(t *testing.T, it sortedkv.Iterator) []string {
+	t.Helper()
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Close(): Failed with reason %v.\n", err)
+	}
+	return keys
+}
+
+// mustReadKeys tests that the iterator yields exactly the given keys, in that
+// order, and closes it.
+func mustReadKeys(t *testing.T, name string, it sortedkv.Iterator, keys ...string) {
+	t.Helper()
+	if actual := readKeys(t, it); len(keys) != len(actual) || (len(keys) > 0 && !reflect.DeepEqual(keys, actual)) {
+		t.Errorf("%s: Expected keys %q, but got %q.\n", name, keys, actual)
+	}
+}
+
+// mustBeAccessDenied tests that err is a *sortedkv.AccessDeniedError.
+func mustBeAccessDenied(t *testing.T, op string, err error) {
+	t.Helper()
+	if !sortedkv.IsAccessDenied(err) {
+		t.Errorf("%s should have failed with an AccessDeniedError, but got: %v\n", op, err)
+	}
+}
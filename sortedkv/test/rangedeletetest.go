@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericRangeDeleteTest provides generic tests for RangeDeleter
+// implementations. The database and its batches must implement
+// sortedkv.RangeDeleter and the database must be empty.
+func GenericRangeDeleteTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("Database", func(t *testing.T) {
+		testRangeDelete(t, database, func(deleteFn func(sortedkv.RangeDeleter) error) error {
+			return deleteFn(rangeDeleter(t, database))
+		})
+	})
+	t.Run("Batch", func(t *testing.T) {
+		testRangeDelete(t, database, func(deleteFn func(sortedkv.RangeDeleter) error) error {
+			batch := database.NewBatch()
+			if err := deleteFn(rangeDeleter(t, batch)); err != nil {
+				return err
+			}
+			return batch.Apply()
+		})
+	})
+	t.Run("Batch order", func(t *testing.T) {
+		testRangeDeleteBatchOrder(t, database)
+	})
+}
+
+// testRangeDelete tests range deletions that are executed by run.
+func testRangeDelete(t *testing.T, database sortedkv.Database, run func(func(sortedkv.RangeDeleter) error) error) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	mustRun := func(name string, deleteFn func(sortedkv.RangeDeleter) error) {
+		if err := run(deleteFn); err != nil {
+			t.Fatalf("%s: Failed with reason %v.\n", name, err)
+		}
+	}
+	fill := func() {
+		for _, key := range []string{"1", "2a", "2b", "2c", "3"} {
+			dbtest.Put(key, key+"v")
+		}
+	}
+
+	// Test ["2a", "2c").
+	fill()
+	mustRun("DeleteRange(2a, 2c)", func(d sortedkv.RangeDeleter) error { return d.DeleteRange("2a", "2c") })
+	mustContainExactly(t, database, "1", "2c", "3")
+
+	// Test ["2", ...].
+	fill()
+	mustRun("DeleteRange(2, )", func(d sortedkv.RangeDeleter) error { return d.DeleteRange("2", "") })
+	mustContainExactly(t, database, "1")
+
+	// Test "2"+...
+	fill()
+	mustRun("DeletePrefix(2)", func(d sortedkv.RangeDeleter) error { return d.DeletePrefix("2") })
+	mustContainExactly(t, database, "1", "3")
+
+	// Test empty ranges.
+	mustRun("DeletePrefix(4)", func(d sortedkv.RangeDeleter) error { return d.DeletePrefix("4") })
+	mustRun("DeleteRange(2, 3)", func(d sortedkv.RangeDeleter) error { return d.DeleteRange("2", "3") })
+	mustContainExactly(t, database, "1", "3")
+
+	// Test everything.
+	mustRun("DeletePrefix()", func(d sortedkv.RangeDeleter) error { return d.DeletePrefix("") })
+	mustContainExactly(t, database)
+}
+
+// testRangeDeleteBatchOrder tests that range deletions in a batch are ordered
+// correctly with respect to the other operations of the batch.
+func testRangeDeleteBatchOrder(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.Put("2a", "2av")
+	dbtest.Put("3", "3v")
+
+	batch := BatchTest{T: t, Batch: database.NewBatch()}
+	batch.MustPut("2b", "2bv")
+	batch.MustPut("2c", "2cv")
+	if err := rangeDeleter(t, batch.Batch).DeletePrefix("2"); err != nil {
+		t.Fatalf("Batch.DeletePrefix(): Failed with reason %v.\n", err)
+	}
+	batch.MustPut("2c", "2cv'")
+	batch.MustPut("1", "1v")
+	if err := rangeDeleter(t, batch.Batch).DeleteRange("3", ""); err != nil {
+		t.Fatalf("Batch.DeleteRange(): Failed with reason %v.\n", err)
+	}
+	batch.MustApply()
+
+	mustContainExactly(t, database, "1", "2c")
+	dbtest.MustGetEqual("2c", "2cv'")
+
+	if err := rangeDeleter(t, database).DeletePrefix(""); err != nil {
+		t.Fatalf("DeletePrefix(): Failed with reason %v.\n", err)
+	}
+}
+
+func rangeDeleter(t *testing.T, w sortedkv.Writer) sortedkv.RangeDeleter {
+	t.Helper()
+	d, ok := w.(sortedkv.RangeDeleter)
+	if !ok {
+		t.Fatalf("%T does not implement sortedkv.RangeDeleter.\n", w)
+	}
+	return d
+}
+
+// mustContainExactly tests that the database contains exactly the given keys,
+// in that order.
+func mustContainExactly(t *testing.T, database sortedkv.Database, keys ...string) {
+	t.Helper()
+	it := database.NewIterator()
+	defer func() {
+		if err := it.Close(); err != nil {
+			t.Errorf("Close(): failed with error: %v\n", err)
+		}
+	}()
+
+	for _, key := range keys {
+		if !it.Next() {
+			t.Errorf("Next(): Expected [%q], but iterator ended.\n", key)
+			return
+		}
+		if actual := it.Key(); actual != key {
+			t.Errorf("Key(): Expected %q, but got %q.\n", key, actual)
+		}
+	}
+	if it.Next() {
+		t.Errorf("Next(): Expected end, but got [%q].\n", it.Key())
+	}
+}
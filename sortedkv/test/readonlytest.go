@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// writableReadOnly reads through a read-only view and writes to the underlying
+// database, so that the read-only view can be tested with the generic suites.
+type writableReadOnly struct {
+	sortedkv.Database
+	db sortedkv.Database
+}
+
+// GenericReadOnlyTest provides generic tests for sortedkv.ReadOnly on
+// databases that newDB creates. newDB must return a new, empty database on
+// every call. The databases must implement sortedkv.Snapshotter.
+func GenericReadOnlyTest(t *testing.T, newDB func() sortedkv.Database) {
+	t.Helper()
+	t.Run("Iterator", func(t *testing.T) {
+		db := newDB()
+		GenericIteratorTest(t, &writableReadOnly{Database: sortedkv.ReadOnly(db), db: db})
+	})
+	t.Run("Access", func(t *testing.T) {
+		testReadOnlyAccess(t, newDB())
+	})
+}
+
+// testReadOnlyAccess tests that all reads pass through and all writes fail
+// with a *sortedkv.ReadOnlyError.
+func testReadOnlyAccess(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	withData(t, database, map[string]string{"a": "1", "b": "2"})
+	ro := sortedkv.ReadOnly(database)
+
+	rotest := DatabaseTest{T: t, Database: ro}
+	rotest.MustGetEqual("a", "1")
+	rotest.MustGetEqual("b", "2")
+	rotest.MustFailGet("c")
+	mustReadKeys(t, "NewIteratorWithPrefix()", ro.NewIteratorWithPrefix(""), "a", "b")
+
+	mustBeReadOnly(t, "Put()", ro.Put("a", "x"))
+	mustBeReadOnly(t, "PutBytes()", ro.PutBytes("a", []byte("x")))
+	mustBeReadOnly(t, "Delete()", ro.Delete("a"))
+	mustBeReadOnly(t, "DeleteRange()", rangeDeleter(t, ro).DeleteRange("a", ""))
+	mustBeReadOnly(t, "DeletePrefix()", rangeDeleter(t, ro).DeletePrefix(""))
+	if c, ok := ro.(sortedkv.Compacter); !ok {
+		t.Errorf("%T does not implement sortedkv.Compacter.\n", ro)
+	} else {
+		mustBeReadOnly(t, "CompactRange()", c.CompactRange("", ""))
+	}
+	if tr, ok := ro.(sortedkv.Transactor); !ok {
+		t.Errorf("%T does not implement sortedkv.Transactor.\n", ro)
+	} else {
+		_, err := tr.NewTransaction()
+		mustBeReadOnly(t, "NewTransaction()", err)
+	}
+
+	batch := ro.NewBatch()
+	mustBeReadOnly(t, "Batch.Put()", batch.Put("c", "3"))
+	mustBeReadOnly(t, "Batch.PutBytes()", batch.PutBytes("c", []byte("3")))
+	mustBeReadOnly(t, "Batch.Delete()", batch.Delete("a"))
+	mustBeReadOnly(t, "Batch.Apply()", batch.Apply())
+
+	// The underlying database is unchanged and stays open.
+	mustBeReadOnly(t, "Close()", ro.Close())
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.MustGetEqual("a", "1")
+	dbtest.MustGetEqual("b", "2")
+
+	// Snapshots are passed through.
+	snap, err := ro.(sortedkv.Snapshotter).NewSnapshot()
+	if err != nil {
+		t.Fatalf("NewSnapshot(): Failed with reason %v.\n", err)
+	}
+	stest := SnapshotTest{T: t, Snapshot: snap}
+	dbtest.Put("a", "x")
+	stest.MustGetEqual("a", "1")
+	stest.Close()
+}
+
+// mustBeReadOnly tests that err is a *sortedkv.ReadOnlyError.
+func mustBeReadOnly(t *testing.T, op string, err error) {
+	t.Helper()
+	if !sortedkv.IsReadOnly(err) {
+		t.Errorf("%s should have failed with a ReadOnlyError, but got: %v\n", op, err)
+	}
+}
+
+// Put puts the value into the underlying database.
+func (w *writableReadOnly) Put(key, value string) error { return w.db.Put(key, value) }
+
+// PutBytes puts the value into the underlying database.
+func (w *writableReadOnly) PutBytes(key string, value []byte) error {
+	return w.db.PutBytes(key, value)
+}
+
+// Delete deletes the key from the underlying database.
+func (w *writableReadOnly) Delete(key string) error { return w.db.Delete(key) }
+
+// NewBatch returns a batch of the underlying database.
+func (w *writableReadOnly) NewBatch() sortedkv.Batch { return w.db.NewBatch() }
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericSnapshotTest provides generic tests for Snapshotter implementations.
+// The database must be empty and implement sortedkv.Snapshotter.
+func GenericSnapshotTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("Generic snapshot test", func(t *testing.T) {
+		testSnapshot(t, database)
+	})
+	t.Run("Table snapshot test", func(t *testing.T) {
+		testSnapshot(t, sortedkv.NewTable(database, "Table."))
+	})
+}
+
+func testSnapshot(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	snapshotter, ok := database.(sortedkv.Snapshotter)
+	if !ok {
+		t.Fatalf("Database does not implement sortedkv.Snapshotter.\n")
+	}
+
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.Put("1", "1v")
+	dbtest.Put("2a", "2av")
+	dbtest.Put("2b", "2bv")
+
+	s, err := snapshotter.NewSnapshot()
+	if err != nil {
+		t.Fatalf("NewSnapshot(): Failed with reason %v.\n", err)
+	}
+	stest := SnapshotTest{T: t, Snapshot: s}
+
+	// Later writes must not be visible through the snapshot.
+	dbtest.Put("2a", "2av'")
+	dbtest.Put("3", "3v")
+	dbtest.Delete("1")
+
+	stest.MustGetEqual("1", "1v")
+	stest.MustGetEqual("2a", "2av")
+	stest.MustGetEqual("2b", "2bv")
+	stest.MustNotHave("3")
+
+	it := IteratorTest{T: t, Iterator: s.NewIterator()}
+	it.NextMustEqual("1", "1v")
+	it.NextMustEqual("2a", "2av")
+	it.NextMustEqual("2b", "2bv")
+	it.MustEnd()
+
+	it.Iterator = s.NewIteratorWithRange("2", "")
+	it.NextMustEqual("2a", "2av")
+	it.NextMustEqual("2b", "2bv")
+	it.MustEnd()
+
+	it.Iterator = s.NewIteratorWithPrefix("2")
+	it.NextMustEqual("2a", "2av")
+	it.NextMustEqual("2b", "2bv")
+	it.MustEnd()
+
+	// The database itself must see the new state.
+	dbtest.MustNotHave("1")
+	dbtest.MustGetEqual("2a", "2av'")
+	dbtest.MustGetEqual("3", "3v")
+
+	stest.Close()
+
+	dbtest.Delete("2a")
+	dbtest.Delete("2b")
+	dbtest.Delete("3")
+}
+
+// SnapshotTest tests a snapshot.
+type SnapshotTest struct {
+	*testing.T
+	Snapshot sortedkv.Snapshot
+}
+
+// Has tests the has functionality.
+func (s *SnapshotTest) Has(key string) bool {
+	has, err := s.Snapshot.Has(key)
+	if err != nil {
+		s.Fatalf("Has(): Failed to query %q: %v\n", key, err)
+	}
+	return has
+}
+
+// MustNotHave tests the has functionality.
+func (s *SnapshotTest) MustNotHave(key string) {
+	if s.Has(key) {
+		s.Errorf("Snapshot has entry %q but it shouldn't.\n", key)
+	}
+	if _, err := s.Snapshot.Get(key); err == nil {
+		s.Errorf("Get() did not fail when expected to ([%q]).\n", key)
+	}
+}
+
+// MustGetEqual tests the get functionality.
+func (s *SnapshotTest) MustGetEqual(key string, expected string) {
+	if !s.Has(key) {
+		s.Errorf("Snapshot does not have entry %q but it should.\n", key)
+	}
+	value, err := s.Snapshot.Get(key)
+	if err != nil {
+		s.Fatalf("Get(): Failed to get [%q]: %v\n", key, err)
+	}
+	if value != expected {
+		s.Errorf(
+			"Get() returned the wrong value: [%q] (is %q, expected %q)\n",
+			key,
+			value,
+			expected)
+	}
+}
+
+// Close tests the close method.
+func (s *SnapshotTest) Close() {
+	if err := s.Snapshot.Close(); err != nil {
+		s.Errorf("Close(): failed with error: %v\n", err)
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericTableBatchTest provides generic tests for sortedkv.TableBatch on the
+// batches of a database. The database must be empty. Range deletions are
+// tested if the batches of the database are RangeDeleters.
+func GenericTableBatchTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("Shared batch", func(t *testing.T) {
+		testTableBatch(t, database)
+	})
+	t.Run("Shared table batch", func(t *testing.T) {
+		testTableBatch(t, sortedkv.NewTable(database, "Table."))
+	})
+}
+
+func testTableBatch(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	dbtest.Put("a.old", "v")
+	dbtest.Put("c.x.old", "v")
+
+	// Writes to several tables are applied atomically.
+	batch := database.NewBatch()
+	a := sortedkv.TableBatch(batch, "a.")
+	b := sortedkv.TableBatch(batch, "b.")
+	c := sortedkv.TableBatch(batch, "c.")
+	nested := sortedkv.TableBatch(c, "x.")
+	mustWrite(t, "a.Put()", a.Put("1", "a1"))
+	mustWrite(t, "b.PutBytes()", b.PutBytes("1", []byte("b1")))
+	mustWrite(t, "a.Delete()", a.Delete("old"))
+	mustWrite(t, "nested.Put()", nested.Put("1", "cx1"))
+	mustWrite(t, "nested.Delete()", nested.Delete("old"))
+	mustWrite(t, "batch.Put()", batch.Put("d", "d"))
+	mustContainExactly(t, database, "a.old", "c.x.old")
+
+	if err := batch.Apply(); err != nil {
+		t.Fatalf("Apply(): Failed with reason %v.\n", err)
+	}
+	dbtest.MustGetEqual("a.1", "a1")
+	dbtest.MustGetEqual("b.1", "b1")
+	dbtest.MustGetEqual("c.x.1", "cx1")
+	dbtest.MustGetEqual("d", "d")
+	mustContainExactly(t, database, "a.1", "b.1", "c.x.1", "d")
+
+	// Table batches can be created on tables' batches.
+	batch = sortedkv.NewTable(database, "c.").NewBatch()
+	mustWrite(t, "Put()", sortedkv.TableBatch(batch, "y.").Put("1", "cy1"))
+	if err := batch.Apply(); err != nil {
+		t.Fatalf("Apply(): Failed with reason %v.\n", err)
+	}
+	dbtest.MustGetEqual("c.y.1", "cy1")
+
+	if batchesDeleteRanges(database) {
+		testTableBatchRangeDelete(t, database)
+	} else {
+		// Range deletions fail without changing the parent.
+		d := sortedkv.TableBatch(database.NewBatch(), "a.").(sortedkv.RangeDeleter)
+		if err := d.DeleteRange("", ""); !sortedkv.IsNotSupported(err) {
+			t.Errorf("DeleteRange(): Expected a NotSupportedError, got %v.\n", err)
+		}
+		if err := d.DeletePrefix(""); !sortedkv.IsNotSupported(err) {
+			t.Errorf("DeletePrefix(): Expected a NotSupportedError, got %v.\n", err)
+		}
+	}
+
+	for _, key := range []string{"a.1", "b.1", "c.x.1", "c.y.1", "d"} {
+		if has := dbtest.Has(key); has {
+			dbtest.Delete(key)
+		}
+	}
+}
+
+func testTableBatchRangeDelete(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	dbtest := DatabaseTest{T: t, Database: database}
+	for _, key := range []string{"c.x.2", "c.x.3", "c.x\xff.1", "c.y.2"} {
+		dbtest.Put(key, "v")
+	}
+
+	batch := database.NewBatch()
+	c := sortedkv.TableBatch(batch, "c.")
+	x := sortedkv.TableBatch(c, "x.").(sortedkv.RangeDeleter)
+	y := sortedkv.TableBatch(c, "y.").(sortedkv.RangeDeleter)
+	mustWrite(t, "DeleteRange()", x.DeleteRange("2", ""))
+	mustWrite(t, "DeletePrefix()", y.DeletePrefix(""))
+	if err := batch.Apply(); err != nil {
+		t.Fatalf("Apply(): Failed with reason %v.\n", err)
+	}
+	mustContainExactly(t, database, "a.1", "b.1", "c.x.1", "c.x\xff.1", "d")
+	dbtest.Delete("c.x\xff.1")
+}
+
+// batchesDeleteRanges returns whether the batches of the database support
+// range deletions. Tables' batches are RangeDeleters even if the underlying
+// batches are not, so a deletion is tried on a batch that is not applied.
+func batchesDeleteRanges(database sortedkv.Database) bool {
+	batch, ok := database.NewBatch().(sortedkv.RangeDeleter)
+	return ok && !sortedkv.IsNotSupported(batch.DeletePrefix(""))
+}
+
+func mustWrite(t *testing.T, op string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: Failed with reason %v.\n", op, err)
+	}
+}
@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericTransactionTest provides generic tests for Transactor
+// implementations. The database must be empty and implement
+// sortedkv.Transactor.
+func GenericTransactionTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("Generic transaction test", func(t *testing.T) {
+		testTransaction(t, database)
+	})
+	t.Run("Table transaction test", func(t *testing.T) {
+		testTransaction(t, sortedkv.NewTable(database, "Table."))
+	})
+}
+
+func testTransaction(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("Read your writes", func(t *testing.T) {
+		dbtest := DatabaseTest{T: t, Database: database}
+		dbtest.Put("1", "1v")
+		dbtest.Put("2a", "2av")
+		dbtest.Put("3", "3v")
+
+		tx := TransactionTest{T: t, Transaction: newTransaction(t, database)}
+		tx.MustPut("2b", "2bv")
+		tx.MustPut("3", "3v'")
+		tx.MustDelete("1")
+		tx.MustFailDelete("4")
+
+		tx.MustGetEqual("2b", "2bv")
+		tx.MustGetEqual("3", "3v'")
+		tx.MustNotHave("1")
+
+		it := IteratorTest{T: t, Iterator: tx.Transaction.NewIterator()}
+		it.NextMustEqual("2a", "2av")
+		it.NextMustEqual("2b", "2bv")
+		it.NextMustEqual("3", "3v'")
+		it.MustEnd()
+
+		it.Iterator = tx.Transaction.NewIteratorWithRange("2", "3")
+		it.NextMustEqual("2a", "2av")
+		it.NextMustEqual("2b", "2bv")
+		it.MustEnd()
+
+		it.Iterator = tx.Transaction.NewIteratorWithPrefix("2")
+		it.NextMustEqual("2a", "2av")
+		it.NextMustEqual("2b", "2bv")
+		it.MustEnd()
+
+		// Writes must not be visible before the commit.
+		dbtest.MustGetEqual("1", "1v")
+		dbtest.MustNotHave("2b")
+		dbtest.MustGetEqual("3", "3v")
+
+		tx.MustCommit()
+
+		dbtest.MustNotHave("1")
+		dbtest.MustGetEqual("2a", "2av")
+		dbtest.MustGetEqual("2b", "2bv")
+		dbtest.MustGetEqual("3", "3v'")
+
+		if err := tx.Transaction.Put("4", "4v"); !errors.Is(err, sortedkv.ErrTransactionDone) {
+			t.Errorf("Put() after Commit(): expected ErrTransactionDone, got %v.\n", err)
+		}
+		dbtest.Delete("2a")
+		dbtest.Delete("2b")
+		dbtest.Delete("3")
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		dbtest := DatabaseTest{T: t, Database: database}
+		dbtest.Put("k", "v")
+
+		// Changed after read.
+		tx := TransactionTest{T: t, Transaction: newTransaction(t, database)}
+		tx.MustGetEqual("k", "v")
+		tx.MustPut("other", "v")
+		dbtest.Put("k", "v'")
+		tx.MustConflict("k")
+		dbtest.MustNotHave("other")
+
+		// Deleted after read by iterator.
+		tx.Transaction = newTransaction(t, database)
+		it := IteratorTest{T: t, Iterator: tx.Transaction.NewIteratorWithPrefix("k")}
+		it.NextMustEqual("k", "v'")
+		it.MustEnd()
+		tx.MustPut("other", "v")
+		dbtest.Delete("k")
+		tx.MustConflict("k")
+		dbtest.MustNotHave("other")
+
+		// Created after a negative read.
+		tx.Transaction = newTransaction(t, database)
+		tx.MustNotHave("k")
+		tx.MustPut("other", "v")
+		dbtest.Put("k", "v")
+		tx.MustConflict("k")
+		dbtest.MustNotHave("other")
+
+		// Blind writes do not conflict.
+		tx.Transaction = newTransaction(t, database)
+		tx.MustPut("k", "blind")
+		dbtest.Put("k", "v''")
+		tx.MustCommit()
+		dbtest.MustGetEqual("k", "blind")
+
+		// Discarded transactions do not write.
+		tx.Transaction = newTransaction(t, database)
+		tx.MustPut("other", "v")
+		tx.Transaction.Discard()
+		dbtest.MustNotHave("other")
+		if err := tx.Transaction.Commit(); !errors.Is(err, sortedkv.ErrTransactionDone) {
+			t.Errorf("Commit() after Discard(): expected ErrTransactionDone, got %v.\n", err)
+		}
+
+		dbtest.Delete("k")
+	})
+
+	t.Run("Concurrent read-modify-write", func(t *testing.T) {
+		dbtest := DatabaseTest{T: t, Database: database}
+		const (
+			workers    = 4
+			increments = 25
+		)
+		dbtest.Put("counter", "0")
+
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				defer wg.Done()
+				for n := 0; n < increments; {
+					if err := increment(database, "counter"); err == nil {
+						n++
+					} else if !errors.As(err, new(*sortedkv.ConflictError)) {
+						t.Errorf("Increment failed: %v\n", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		dbtest.MustGetEqual("counter", strconv.Itoa(workers*increments))
+		dbtest.Delete("counter")
+	})
+}
+
+// increment increments the number stored under key in a transaction.
+func increment(database sortedkv.Database, key string) error {
+	tx, err := database.(sortedkv.Transactor).NewTransaction()
+	if err != nil {
+		return err
+	}
+	value, err := tx.Get(key)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if err := tx.Put(key, strconv.Itoa(n+1)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func newTransaction(t *testing.T, database sortedkv.Database) sortedkv.Transaction {
+	t.Helper()
+	transactor, ok := database.(sortedkv.Transactor)
+	if !ok {
+		t.Fatalf("Database does not implement sortedkv.Transactor.\n")
+	}
+	tx, err := transactor.NewTransaction()
+	if err != nil {
+		t.Fatalf("NewTransaction(): Failed with reason %v.\n", err)
+	}
+	return tx
+}
+
+// TransactionTest tests a transaction.
+type TransactionTest struct {
+	*testing.T
+	Transaction sortedkv.Transaction
+}
+
+// MustNotHave tests the has functionality.
+func (tt *TransactionTest) MustNotHave(key string) {
+	has, err := tt.Transaction.Has(key)
+	if err != nil {
+		tt.Fatalf("Has(): Failed to query %q: %v\n", key, err)
+	}
+	if has {
+		tt.Errorf("Transaction has entry %q but it shouldn't.\n", key)
+	}
+}
+
+// MustGetEqual tests the get functionality.
+func (tt *TransactionTest) MustGetEqual(key string, expected string) {
+	value, err := tt.Transaction.Get(key)
+	if err != nil {
+		tt.Fatalf("Get(): Failed to get [%q]: %v\n", key, err)
+	}
+	if value != expected {
+		tt.Errorf(
+			"Get() returned the wrong value: [%q] (is %q, expected %q)\n",
+			key,
+			value,
+			expected)
+	}
+}
+
+// MustPut tests the put functionality.
+func (tt *TransactionTest) MustPut(key, value string) {
+	if err := tt.Transaction.Put(key, value); err != nil {
+		tt.Fatalf("Put(): Failed to put [%q] = %q: %v.\n", key, value, err)
+	}
+}
+
+// MustDelete tests the delete functionality.
+func (tt *TransactionTest) MustDelete(key string) {
+	if err := tt.Transaction.Delete(key); err != nil {
+		tt.Fatalf("Delete(): Failed to delete [%q]: %v.\n", key, err)
+	}
+}
+
+// MustFailDelete tests the delete functionality.
+func (tt *TransactionTest) MustFailDelete(key string) {
+	if err := tt.Transaction.Delete(key); err == nil {
+		tt.Errorf("Delete() [%q] should have failed, but did not.\n", key)
+	}
+}
+
+// MustCommit tests the commit functionality.
+func (tt *TransactionTest) MustCommit() {
+	if err := tt.Transaction.Commit(); err != nil {
+		tt.Errorf("Commit(): Failed with reason %v.\n", err)
+	}
+}
+
+// MustConflict tests that the commit fails with a conflict on key.
+func (tt *TransactionTest) MustConflict(key string) {
+	err := tt.Transaction.Commit()
+	var conflict *sortedkv.ConflictError
+	if !errors.As(err, &conflict) {
+		tt.Errorf("Commit(): Expected conflict on [%q], got %v.\n", key, err)
+	} else if conflict.Key != key {
+		tt.Errorf("Commit(): Expected conflict on [%q], got conflict on [%q].\n", key, conflict.Key)
+	}
+}
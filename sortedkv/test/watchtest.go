@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// GenericWatchTest provides generic tests for Watcher implementations. The
+// database must be empty and implement sortedkv.Watcher,
+// sortedkv.RangeDeleter and sortedkv.Transactor.
+func GenericWatchTest(t *testing.T, database sortedkv.Database) {
+	t.Helper()
+	t.Run("Writes", func(t *testing.T) {
+		all := newWatchTest(t, database, "w.")
+		a := newWatchTest(t, database, "w.a")
+		dbtest := DatabaseTest{T: t, Database: database}
+
+		dbtest.Put("w.a1", "1")
+		dbtest.PutBytes("w.b1", []byte("2"))
+		dbtest.Delete("w.a1")
+		dbtest.MustFailDelete("w.a1")
+		dbtest.Put("x", "outside")
+		all.MustReceive(sortedkv.EventPut, "w.a1", "1")
+		all.MustReceive(sortedkv.EventPut, "w.b1", "2")
+		all.MustReceive(sortedkv.EventDelete, "w.a1", "")
+		all.MustNotReceive()
+		a.MustReceive(sortedkv.EventPut, "w.a1", "1")
+		a.MustReceive(sortedkv.EventDelete, "w.a1", "")
+		a.MustNotReceive()
+
+		dbtest.Put("w.a2", "3")
+		dbtest.Put("w.a3", "4")
+		all.MustReceive(sortedkv.EventPut, "w.a2", "3")
+		all.MustReceive(sortedkv.EventPut, "w.a3", "4")
+		if err := rangeDeleter(t, database).DeletePrefix("w.a"); err != nil {
+			t.Fatalf("DeletePrefix(): Failed with reason %v.\n", err)
+		}
+		all.MustReceive(sortedkv.EventDelete, "w.a2", "")
+		all.MustReceive(sortedkv.EventDelete, "w.a3", "")
+		all.MustNotReceive()
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		w := newWatchTest(t, database, "b.")
+		dbtest := DatabaseTest{T: t, Database: database}
+		dbtest.Put("b.1", "1")
+		dbtest.Put("b.2", "2")
+		w.MustReceive(sortedkv.EventPut, "b.1", "1")
+		w.MustReceive(sortedkv.EventPut, "b.2", "2")
+
+		batch := BatchTest{T: t, Batch: database.NewBatch()}
+		batch.MustPut("b.3", "3")
+		batch.MustDelete("b.4") // Absent, no event.
+		batch.MustPut("b.5", "5")
+		batch.MustDelete("b.1")
+		w.MustNotReceive()
+		if err := rangeDeleter(t, batch.Batch).DeleteRange("b.2", "b.4"); err != nil {
+			t.Fatalf("DeleteRange(): Failed with reason %v.\n", err)
+		}
+		batch.MustApply()
+
+		w.MustReceive(sortedkv.EventPut, "b.3", "3")
+		w.MustReceive(sortedkv.EventPut, "b.5", "5")
+		w.MustReceive(sortedkv.EventDelete, "b.1", "")
+		w.MustReceive(sortedkv.EventDelete, "b.2", "")
+		w.MustReceive(sortedkv.EventDelete, "b.3", "")
+		w.MustNotReceive()
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		w := newWatchTest(t, database, "t.")
+		dbtest := DatabaseTest{T: t, Database: database}
+		dbtest.Put("t.1", "1")
+		w.MustReceive(sortedkv.EventPut, "t.1", "1")
+
+		tx := TransactionTest{T: t, Transaction: newTransaction(t, database)}
+		tx.MustPut("t.2", "2")
+		tx.MustDelete("t.1")
+		w.MustNotReceive()
+		tx.MustCommit()
+		w.MustReceive(sortedkv.EventPut, "t.2", "2")
+		w.MustReceive(sortedkv.EventDelete, "t.1", "")
+		w.MustNotReceive()
+
+		tx = TransactionTest{T: t, Transaction: newTransaction(t, database)}
+		tx.MustGetEqual("t.2", "2")
+		tx.MustPut("t.3", "3")
+		dbtest.Put("t.2", "2'")
+		w.MustReceive(sortedkv.EventPut, "t.2", "2'")
+		tx.MustConflict("t.2")
+		w.MustNotReceive()
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		table := sortedkv.NewTable(database, "T.")
+		inner := sortedkv.NewTable(table, "I.")
+		w := newWatchTest(t, table, "")
+		wInner := newWatchTest(t, inner, "")
+
+		(&DatabaseTest{T: t, Database: table}).Put("1", "1")
+		(&DatabaseTest{T: t, Database: inner}).Put("2", "2")
+		batch := BatchTest{T: t, Batch: inner.NewBatch()}
+		batch.MustDelete("2")
+		batch.MustApply()
+		(&DatabaseTest{T: t, Database: database}).Put("T.I.3", "3")
+
+		w.MustReceive(sortedkv.EventPut, "1", "1")
+		w.MustReceive(sortedkv.EventPut, "I.2", "2")
+		w.MustReceive(sortedkv.EventDelete, "I.2", "")
+		w.MustReceive(sortedkv.EventPut, "I.3", "3")
+		w.MustNotReceive()
+		wInner.MustReceive(sortedkv.EventPut, "2", "2")
+		wInner.MustReceive(sortedkv.EventDelete, "2", "")
+		wInner.MustReceive(sortedkv.EventPut, "3", "3")
+		wInner.MustNotReceive()
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := watcher(t, database).Watch(ctx, "c.")
+		if err != nil {
+			t.Fatalf("Watch(): Failed with reason %v.\n", err)
+		}
+		cancel()
+		for range events { // nolint: revive
+			// The channel must be closed eventually, pending events may be
+			// received before.
+		}
+	})
+
+	t.Run("Slow consumer", func(t *testing.T) {
+		w := newWatchTest(t, database, "s.")
+		dbtest := DatabaseTest{T: t, Database: database}
+		for i := 0; i <= sortedkv.WatchBufferSize; i++ {
+			dbtest.Put("s."+strconv.Itoa(i), "v")
+		}
+		for i := 0; i < sortedkv.WatchBufferSize; i++ {
+			w.MustReceive(sortedkv.EventPut, "s."+strconv.Itoa(i), "v")
+		}
+		w.MustReceive(sortedkv.EventOverflow, "", "")
+		w.MustBeClosed()
+	})
+}
+
+// WatchTest tests the events of a watch.
+type WatchTest struct {
+	*testing.T
+	Events <-chan sortedkv.Event
+}
+
+func newWatchTest(t *testing.T, database sortedkv.Database, prefix string) *WatchTest {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events, err := watcher(t, database).Watch(ctx, prefix)
+	if err != nil {
+		t.Fatalf("Watch(): Failed with reason %v.\n", err)
+	}
+	return &WatchTest{T: t, Events: events}
+}
+
+func watcher(t *testing.T, database sortedkv.Database) sortedkv.Watcher {
+	t.Helper()
+	w, ok := database.(sortedkv.Watcher)
+	if !ok {
+		t.Fatalf("%T does not implement sortedkv.Watcher.\n", database)
+	}
+	return w
+}
+
+// MustReceive tests that the next event is as expected. Events are sent
+// before the write returns, so it does not wait.
+func (w *WatchTest) MustReceive(typ sortedkv.EventType, key, value string) {
+	w.Helper()
+	select {
+	case e, ok := <-w.Events:
+		if !ok {
+			w.Fatalf("Expected event %v %q, but the channel is closed.\n", typ, key)
+		}
+		if e.Type != typ || e.Key != key || e.Value != value {
+			w.Errorf("Expected event %v %q = %q, but got %v %q = %q.\n", typ, key, value, e.Type, e.Key, e.Value)
+		}
+	default:
+		w.Fatalf("Expected event %v %q, but there is none.\n", typ, key)
+	}
+}
+
+// MustNotReceive tests that there is no pending event.
+func (w *WatchTest) MustNotReceive() {
+	w.Helper()
+	select {
+	case e, ok := <-w.Events:
+		if ok {
+			w.Errorf("Expected no event, but got %v %q.\n", e.Type, e.Key)
+		} else {
+			w.Errorf("Expected no event, but the channel is closed.\n")
+		}
+	default:
+	}
+}
+
+// MustBeClosed tests that the channel is closed.
+func (w *WatchTest) MustBeClosed() {
+	w.Helper()
+	select {
+	case e, ok := <-w.Events:
+		if ok {
+			w.Errorf("Expected closed channel, but got %v %q.\n", e.Type, e.Key)
+		}
+	default:
+		w.Errorf("Expected closed channel, but it is open.\n")
+	}
+}
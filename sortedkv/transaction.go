@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+// Transaction is a read-write view on a database. Writes are buffered until
+// Commit() is called and are visible to the transaction's own reads and
+// iterators.
+//
+// Transactions are optimistic: they do not lock the database. Instead, Commit
+// fails with a *ConflictError if the value of a key that was read by the
+// transaction, either directly or via an iterator, differs from the database's
+// value at the time of the commit. In that case, nothing is written and the
+// transaction can be retried from the start. Keys that are added to an
+// iterated range by others are not detected as conflicts.
+//
+// A Transaction is not safe for concurrent use. After Commit() or Discard()
+// was called, all operations return ErrTransactionDone.
+type Transaction interface {
+	Reader
+	Writer
+	Iterable
+
+	// Commit atomically validates the transaction's reads and applies its
+	// writes to the database.
+	Commit() error
+
+	// Discard drops all buffered writes without applying them.
+	Discard()
+}
+
+// Transactor wraps the NewTransaction method of a backing data store.
+type Transactor interface {
+	// NewTransaction creates a Transaction on the data store.
+	NewTransaction() (Transaction, error)
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Batch is a batch of a Database that supports expiring keys.
+type Batch struct {
+	db  *Database
+	ops []batchOp
+}
+
+// batchOp is a buffered write of a batch.
+type batchOp struct {
+	key    string
+	value  []byte
+	ttl    time.Duration
+	delete bool
+}
+
+// Put adds a write of a key that does not expire to the batch.
+func (b *Batch) Put(key string, value string) error {
+	return b.PutBytes(key, []byte(value))
+}
+
+// PutBytes adds a write of a key that does not expire to the batch.
+func (b *Batch) PutBytes(key string, value []byte) error {
+	b.ops = append(b.ops, batchOp{key: key, value: append([]byte(nil), value...)})
+	return nil
+}
+
+// PutWithTTL adds a write of a key to the batch that expires ttl after the
+// batch is applied.
+func (b *Batch) PutWithTTL(key string, value string, ttl time.Duration) error {
+	return b.PutBytesWithTTL(key, []byte(value), ttl)
+}
+
+// PutBytesWithTTL adds a write of a key to the batch that expires ttl after
+// the batch is applied.
+func (b *Batch) PutBytesWithTTL(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.Errorf("non-positive TTL %v", ttl)
+	}
+	b.ops = append(b.ops, batchOp{key: key, value: append([]byte(nil), value...), ttl: ttl})
+	return nil
+}
+
+// Delete adds the deletion of a key to the batch.
+func (b *Batch) Delete(key string) error {
+	b.ops = append(b.ops, batchOp{key: key, delete: true})
+	return nil
+}
+
+// Apply applies the batch atomically. Deleting keys that are not present is
+// not an error.
+func (b *Batch) Apply() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+
+	now := b.db.clock.Now()
+	w := b.db.newWriteSet()
+	for _, op := range b.ops {
+		var err error
+		switch {
+		case op.delete:
+			err = w.delete(op.key)
+		case op.ttl > 0:
+			err = w.put(op.key, op.value, now.Add(op.ttl))
+		default:
+			err = w.put(op.key, op.value, time.Time{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.b.Apply()
+}
+
+// Reset resets the batch.
+func (b *Batch) Reset() {
+	b.ops = nil
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+	polysync "polycry.pt/poly-go/sync"
+)
+
+// Database is a sortedkv.Database with expiring keys. It implements
+// sortedkv.Snapshotter if the underlying database does.
+type Database struct {
+	view
+	db sortedkv.Database
+	o  options
+
+	mu      sync.Mutex // Serializes writes, which update the expiry index.
+	closer  polysync.Closer
+	sweeper chan struct{} // Closed when the sweeper stopped, or nil.
+}
+
+// New creates a Database that stores its entries in db and starts the
+// sweeper, unless it is disabled with WithSweepInterval. It fails if the index
+// prefix is empty.
+func New(db sortedkv.Database, opts ...Option) (*Database, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	d := &Database{
+		view: view{store: db, clock: o.clock, index: o.indexPrefix},
+		db:   db,
+		o:    o,
+	}
+	if o.sweepInterval > 0 {
+		d.sweeper = make(chan struct{})
+		go d.runSweeper()
+	}
+	return d, nil
+}
+
+// Put saves a value under a key that does not expire.
+func (d *Database) Put(key string, value string) error {
+	return d.PutBytes(key, []byte(value))
+}
+
+// PutBytes saves a value under a key that does not expire.
+func (d *Database) PutBytes(key string, value []byte) error {
+	return d.put(key, value, time.Time{})
+}
+
+// PutWithTTL saves a value under a key that expires after ttl.
+func (d *Database) PutWithTTL(key string, value string, ttl time.Duration) error {
+	return d.PutBytesWithTTL(key, []byte(value), ttl)
+}
+
+// PutBytesWithTTL saves a value under a key that expires after ttl.
+func (d *Database) PutBytesWithTTL(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.Errorf("non-positive TTL %v", ttl)
+	}
+	return d.put(key, value, d.clock.Now().Add(ttl))
+}
+
+func (d *Database) put(key string, value []byte, expiry time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w := d.newWriteSet()
+	if err := w.put(key, value, expiry); err != nil {
+		return err
+	}
+	return w.b.Apply()
+}
+
+// Delete deletes a key. It fails with a *sortedkv.NotFoundError if the key is
+// not present or expired.
+func (d *Database) Delete(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, _, err := d.lookup(key); err != nil {
+		return err
+	}
+	w := d.newWriteSet()
+	if err := w.delete(key); err != nil {
+		return err
+	}
+	return w.b.Apply()
+}
+
+// NewBatch creates a new batch.
+func (d *Database) NewBatch() sortedkv.Batch {
+	return &Batch{db: d}
+}
+
+// NewSnapshot creates a snapshot of the underlying database. Whether a key is
+// expired is decided when it is read from the snapshot.
+func (d *Database) NewSnapshot() (sortedkv.Snapshot, error) {
+	snapshotter, ok := d.db.(sortedkv.Snapshotter)
+	if !ok {
+		return nil, &sortedkv.NotSupportedError{Op: "NewSnapshot"}
+	}
+	s, err := snapshotter.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{view: view{store: s, clock: d.clock, index: d.index}, s: s}, nil
+}
+
+// Sweep deletes all expired keys from the underlying database in batches and
+// returns their number.
+func (d *Database) Sweep() (int, error) {
+	var total int
+	for {
+		n, more, err := d.sweepBatch()
+		total += n
+		if err != nil || !more {
+			return total, err
+		}
+	}
+}
+
+// sweepBatch deletes up to one batch of expired keys. It returns the number of
+// deleted keys and whether there may be more expired keys.
+func (d *Database) sweepBatch() (int, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	end := d.index + encodeExpiry(d.clock.Now().Add(1)) // All expiries <= now.
+	it := d.db.NewIteratorWithRange(d.index, end)
+	defer it.Close()
+	var indexKeys []string
+	for len(indexKeys) < d.o.batchSize && it.Next() {
+		indexKeys = append(indexKeys, it.Key())
+	}
+	if err := it.Close(); err != nil {
+		return 0, false, errors.WithMessage(err, "reading expiry index")
+	}
+
+	w := d.newWriteSet()
+	var n int
+	for _, indexKey := range indexKeys {
+		key := indexKey[len(d.index)+expirySize:]
+		expiry := decodeExpiry([]byte(indexKey[len(d.index) : len(d.index)+expirySize]))
+		stored, ok, err := w.expiry(key)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok && stored.Equal(expiry) {
+			if err := w.delete(key); err != nil {
+				return 0, false, err
+			}
+			n++
+		} else if err := w.b.Delete(indexKey); err != nil {
+			return 0, false, err // Stale index entry.
+		}
+	}
+	if err := w.b.Apply(); err != nil {
+		return 0, false, err
+	}
+	return n, len(indexKeys) == d.o.batchSize, nil
+}
+
+// runSweeper periodically sweeps the database until it is closed.
+func (d *Database) runSweeper() {
+	defer close(d.sweeper)
+	ticker := time.NewTicker(d.o.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.closer.Closed():
+			return
+		case <-ticker.C:
+			if _, err := d.Sweep(); err != nil {
+				d.o.onError(errors.WithMessage(err, "sweeping expired keys"))
+			}
+		}
+	}
+}
+
+// Close stops the sweeper and closes the underlying database.
+func (d *Database) Close() error {
+	// An already closed closer results in the database's error below.
+	_ = d.closer.Close()
+	if d.sweeper != nil {
+		<-d.sweeper
+	}
+	return d.db.Close()
+}
+
+// writeSet collects writes to the underlying database in a batch and
+// maintains the expiry index. It tracks the expiries of the keys that it
+// wrote, so that later writes to the same keys see them.
+type writeSet struct {
+	d       *Database
+	b       sortedkv.Batch
+	pending map[string]*time.Time // nil for deleted keys.
+}
+
+// newWriteSet creates a writeSet. The database must be locked until the
+// batch is applied.
+func (d *Database) newWriteSet() *writeSet {
+	return &writeSet{
+		d:       d,
+		b:       d.db.NewBatch(),
+		pending: make(map[string]*time.Time),
+	}
+}
+
+// expiry returns the stored expiry of a key, including expired keys, and
+// whether the key is stored.
+func (w *writeSet) expiry(key string) (time.Time, bool, error) {
+	if expiry, ok := w.pending[key]; ok {
+		if expiry == nil {
+			return time.Time{}, false, nil
+		}
+		return *expiry, true, nil
+	}
+	data, err := w.d.db.GetBytes(key)
+	if sortedkv.IsNotFound(err) {
+		return time.Time{}, false, nil
+	} else if err != nil {
+		return time.Time{}, false, err
+	}
+	_, expiry, err := decodeValue(data)
+	return expiry, err == nil, err
+}
+
+// put writes a value with the given expiry and updates the expiry index.
+func (w *writeSet) put(key string, value []byte, expiry time.Time) error {
+	if strings.HasPrefix(key, w.d.index) {
+		return errors.Errorf("key %q has the reserved prefix of the expiry index", key)
+	}
+	if err := w.unindex(key); err != nil {
+		return err
+	}
+	if err := w.b.PutBytes(key, encodeValue(value, expiry)); err != nil {
+		return err
+	}
+	w.pending[key] = &expiry
+	if expiry.IsZero() {
+		return nil
+	}
+	return w.b.Put(w.d.index+encodeExpiry(expiry)+key, "")
+}
+
+// delete deletes a key and its index entry. Deleting a key that is not
+// stored is not an error.
+func (w *writeSet) delete(key string) error {
+	if err := w.unindex(key); err != nil {
+		return err
+	}
+	w.pending[key] = nil
+	return w.b.Delete(key)
+}
+
+// unindex deletes the index entry of the stored key, if it has one.
+func (w *writeSet) unindex(key string) error {
+	expiry, ok, err := w.expiry(key)
+	if err != nil || !ok || expiry.IsZero() {
+		return err
+	}
+	return w.b.Delete(w.d.index + encodeExpiry(expiry) + key)
+}
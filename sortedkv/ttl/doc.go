@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ttl provides a sortedkv database decorator for keys that expire.
+//
+// A Database wraps another database. Keys that are written with PutWithTTL
+// expire after the given duration. Expired keys are hidden from Has, Get,
+// GetBytes, Delete and all iterators immediately, and a background sweeper
+// deletes them from the underlying database in batches. Keys that are written
+// with Put do not expire; overwriting a key with Put removes its expiry.
+//
+// The expiry of a key is stored in front of its value, so the underlying
+// database must only be written through a Database. For the sweeper to find
+// expired keys, the Database also maintains an index of the expiries under
+// DefaultIndexPrefix or the prefix set by WithIndexPrefix. Keys with that
+// prefix are reserved and hidden.
+//
+// The current time is read from a Clock, which can be replaced by WithClock,
+// for example to test expiry deterministically.
+package ttl // import "polycry.pt/poly-go/sortedkv/ttl"
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// Stored values start with a header byte, which is followed by the expiry if
+// the key expires.
+const (
+	headerPersistent = 0x00
+	headerExpiring   = 0x01
+	expirySize       = 8
+)
+
+// encodeValue prepends the header to a value. A zero expiry means that the
+// key does not expire.
+func encodeValue(value []byte, expiry time.Time) []byte {
+	if expiry.IsZero() {
+		return append([]byte{headerPersistent}, value...)
+	}
+	data := make([]byte, 0, 1+expirySize+len(value))
+	data = append(data, headerExpiring)
+	data = append(data, encodeExpiry(expiry)...)
+	return append(data, value...)
+}
+
+// decodeValue returns the value and expiry of stored data.
+func decodeValue(data []byte) ([]byte, time.Time, error) {
+	switch {
+	case len(data) >= 1 && data[0] == headerPersistent:
+		return data[1:], time.Time{}, nil
+	case len(data) >= 1+expirySize && data[0] == headerExpiring:
+		return data[1+expirySize:], decodeExpiry(data[1 : 1+expirySize]), nil
+	default:
+		return nil, time.Time{}, &sortedkv.CorruptedError{Err: errors.New("invalid value header")}
+	}
+}
+
+// encodeExpiry encodes an expiry as its Unix nanoseconds, 8 bytes big-endian
+// with a flipped sign bit, so that the encoding preserves order.
+func encodeExpiry(expiry time.Time) string {
+	var buf [expirySize]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(expiry.UnixNano())^(1<<63)) // nolint: gomnd
+	return string(buf[:])
+}
+
+func decodeExpiry(data []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(data)^(1<<63))) // nolint: gomnd
+}
+
+// expired returns whether a key with the given expiry is expired at now.
+func expired(expiry, now time.Time) bool {
+	return !expiry.IsZero() && !now.Before(expiry)
+}
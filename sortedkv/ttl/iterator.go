@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"strings"
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// iterator skips the keys that were expired when it was created and the
+// expiry index.
+type iterator struct {
+	it    sortedkv.Iterator
+	now   time.Time
+	index string
+
+	key   string
+	value []byte
+	err   error
+}
+
+// Next moves the iterator to the next key that is not expired.
+func (i *iterator) Next() bool {
+	for i.err == nil && i.it.Next() {
+		if strings.HasPrefix(i.it.Key(), i.index) {
+			continue
+		}
+		value, expiry, err := decodeValue(i.it.ValueBytes())
+		if err != nil {
+			i.err = err
+			break
+		}
+		if !expired(expiry, i.now) {
+			i.key, i.value = i.it.Key(), value
+			return true
+		}
+	}
+	i.key, i.value = "", nil
+	return false
+}
+
+// Key returns the key of the current entry, or "" if done.
+func (i *iterator) Key() string {
+	return i.key
+}
+
+// Value returns the value of the current entry, or "" if done.
+func (i *iterator) Value() string {
+	return string(i.value)
+}
+
+// ValueBytes returns the value of the current entry, or nil if done.
+func (i *iterator) ValueBytes() []byte {
+	return i.value
+}
+
+// Close closes the underlying iterator and returns its error or the first
+// error that was encountered.
+func (i *iterator) Close() error {
+	err := i.it.Close()
+	if i.err != nil {
+		err, i.err = i.err, nil
+	}
+	i.key, i.value = "", nil
+	return err
+}
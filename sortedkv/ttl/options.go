@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultIndexPrefix is the default key prefix of the expiry index.
+	DefaultIndexPrefix = "\x00ttl."
+	// DefaultSweepInterval is the default interval of the sweeper.
+	DefaultSweepInterval = time.Minute
+	// DefaultSweepBatchSize is the default number of expired keys that the
+	// sweeper deletes per batch.
+	DefaultSweepBatchSize = 256
+)
+
+type (
+	// Clock provides the current time.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// SystemClock is the Clock of the system time.
+	SystemClock struct{}
+
+	// Option configures a Database. Options are passed to New.
+	Option func(*options)
+
+	options struct {
+		clock         Clock
+		indexPrefix   string
+		sweepInterval time.Duration
+		batchSize     int
+		onError       func(error)
+	}
+)
+
+// Now returns the current system time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock sets the clock that decides whether keys are expired.
+func WithClock(clock Clock) Option {
+	return func(o *options) { o.clock = clock }
+}
+
+// WithIndexPrefix sets the key prefix of the expiry index. It must not be
+// empty, since all keys with the prefix are reserved.
+func WithIndexPrefix(prefix string) Option {
+	return func(o *options) { o.indexPrefix = prefix }
+}
+
+// WithSweepInterval sets the interval in which the sweeper deletes expired
+// keys. A non-positive interval disables the sweeper, so that expired keys
+// are only deleted by Sweep.
+func WithSweepInterval(interval time.Duration) Option {
+	return func(o *options) { o.sweepInterval = interval }
+}
+
+// WithSweepBatchSize sets the number of expired keys that are deleted per
+// batch.
+func WithSweepBatchSize(n int) Option {
+	return func(o *options) { o.batchSize = n }
+}
+
+// WithErrorHandler sets a function that is called with the errors of the
+// sweeper. By default, they are ignored and the sweeper tries again in the
+// next interval.
+func WithErrorHandler(handler func(error)) Option {
+	return func(o *options) { o.onError = handler }
+}
+
+func newOptions(opts []Option) (options, error) {
+	o := options{
+		clock:         SystemClock{},
+		indexPrefix:   DefaultIndexPrefix,
+		sweepInterval: DefaultSweepInterval,
+		batchSize:     DefaultSweepBatchSize,
+		onError:       func(error) {},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.indexPrefix == "" {
+		return options{}, errors.New("empty index prefix")
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = DefaultSweepBatchSize
+	}
+	return o, nil
+}
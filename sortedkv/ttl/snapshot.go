@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import "polycry.pt/poly-go/sortedkv"
+
+// snapshot hides the expired keys of a snapshot of the underlying database.
+type snapshot struct {
+	view
+	s sortedkv.Snapshot
+}
+
+// Close closes the underlying snapshot.
+func (s *snapshot) Close() error {
+	return s.s.Close()
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+	"polycry.pt/poly-go/sortedkv/test"
+)
+
+// fakeClock is a Clock that only moves when it is advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestDB(t *testing.T, clock Clock, opts ...Option) (*Database, sortedkv.Database) {
+	t.Helper()
+	mem := memorydb.NewDatabase()
+	opts = append([]Option{WithClock(clock), WithSweepInterval(0)}, opts...)
+	db, err := New(mem, opts...)
+	require.NoError(t, err)
+	return db, mem
+}
+
+func TestDatabase(t *testing.T) {
+	newDB := func() sortedkv.Database {
+		db, _ := newTestDB(t, newFakeClock())
+		return db
+	}
+	test.GenericDatabaseTest(t, newDB())
+	test.GenericBatchTest(t, newDB())
+	test.GenericIteratorTest(t, newDB())
+	test.GenericTableTest(t, newDB())
+	test.GenericSnapshotTest(t, newDB())
+	test.GenericClosedDatabaseTest(t, newDB())
+}
+
+func TestPutWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	db, mem := newTestDB(t, clock)
+	dbtest := &test.DatabaseTest{T: t, Database: db}
+
+	require.NoError(t, db.PutWithTTL("session", "s", time.Minute))
+	require.NoError(t, db.PutBytesWithTTL("short", []byte("x"), time.Second))
+	dbtest.Put("persistent", "p")
+	assert.Error(t, db.PutWithTTL("k", "v", 0))
+
+	dbtest.MustGetEqual("short", "x")
+	it := db.NewIterator() // Iterators use the time of their creation.
+
+	clock.Advance(time.Second)
+	dbtest.MustFailGet("short")
+	dbtest.MustNotHave("short")
+	dbtest.MustFailDelete("short")
+	dbtest.MustGetEqual("session", "s")
+	assert.Equal(t, map[string]string{"persistent": "p", "session": "s"}, test.ReadAll(t, db))
+	assert.Equal(t, map[string]string{"persistent": "p", "session": "s"},
+		test.ReadAll(t, sortedkv.NewTable(db, "")))
+
+	ittest := test.IteratorTest{T: t, Iterator: it}
+	ittest.NextMustEqual("persistent", "p")
+	ittest.NextMustEqual("session", "s")
+	ittest.NextMustEqual("short", "x")
+	ittest.MustEnd()
+
+	// Overwriting removes or renews the expiry.
+	dbtest.Put("session", "s2")
+	require.NoError(t, db.PutWithTTL("short", "y", time.Hour))
+	clock.Advance(time.Minute)
+	dbtest.MustGetEqual("session", "s2")
+	dbtest.MustGetEqual("short", "y")
+
+	// The expiry index is hidden.
+	for k := range test.ReadAll(t, mem) {
+		if k[0] == 0 {
+			dbtest.MustFailGet(k)
+		}
+	}
+	assert.Error(t, db.Put(DefaultIndexPrefix+"x", "v"))
+}
+
+func TestSweep(t *testing.T) {
+	clock := newFakeClock()
+	db, mem := newTestDB(t, clock, WithSweepBatchSize(3))
+	for i := 0; i < 10; i++ {
+		key := string(rune('a' + i))
+		require.NoError(t, db.PutWithTTL(key, key, time.Duration(i+1)*time.Second))
+	}
+	require.NoError(t, db.PutWithTTL("d", "d", time.Hour)) // Renewed expiry.
+	require.NoError(t, db.Delete("e"))
+	require.NoError(t, db.Put("f", "f")) // Expiry removed.
+
+	// Keys a, b, ..., g are expired. d, e and f are not deleted by the sweep.
+	clock.Advance(7 * time.Second)
+	n, err := db.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// The underlying database holds d, f, h, i and j and the index of the
+	// expiring keys.
+	assert.Len(t, test.ReadAll(t, mem), 5+4)
+	assert.Equal(t, map[string]string{"d": "d", "f": "f", "h": "h", "i": "i", "j": "j"}, test.ReadAll(t, db))
+
+	n, err = db.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	clock.Advance(time.Hour)
+	n, err = db.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, map[string]string{"f": string([]byte{headerPersistent}) + "f"}, test.ReadAll(t, mem))
+}
+
+func TestBatch(t *testing.T) {
+	clock := newFakeClock()
+	db, mem := newTestDB(t, clock)
+	dbtest := &test.DatabaseTest{T: t, Database: db}
+	dbtest.Put("a", "a")
+
+	b := db.NewBatch().(*Batch)
+	require.NoError(t, b.PutWithTTL("a", "a2", time.Second))
+	require.NoError(t, b.PutWithTTL("b", "b", time.Second))
+	require.NoError(t, b.PutWithTTL("c", "c", time.Second))
+	require.NoError(t, b.Put("b", "b2"))
+	require.NoError(t, b.Delete("c"))
+	require.NoError(t, b.Delete("x"))
+	assert.Error(t, b.PutWithTTL("d", "d", -time.Second))
+	require.NoError(t, b.Apply())
+
+	dbtest.MustGetEqual("a", "a2")
+	clock.Advance(time.Second)
+	dbtest.MustFailGet("a")
+	dbtest.MustGetEqual("b", "b2")
+	dbtest.MustFailGet("c")
+
+	n, err := db.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, test.ReadAll(t, mem), 1)
+}
+
+func TestSweeper(t *testing.T) {
+	clock := newFakeClock()
+	mem := memorydb.NewDatabase()
+	db, err := New(mem, WithClock(clock), WithSweepInterval(time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, db.PutWithTTL("k", "v", time.Second))
+	clock.Advance(time.Second)
+
+	assert.Eventually(t, func() bool {
+		has, err := mem.Has("k")
+		return err == nil && !has
+	}, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, db.Close())
+	assert.True(t, db.closer.IsClosed())
+}
+
+func TestSweeper_Error(t *testing.T) {
+	errs := make(chan error, 1)
+	mem := memorydb.NewDatabase()
+	require.NoError(t, mem.Close())
+	db, err := New(mem, WithSweepInterval(time.Millisecond), WithErrorHandler(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}))
+	require.NoError(t, err)
+	assert.True(t, sortedkv.IsClosed(<-errs))
+	require.NoError(t, db.Close())
+}
+
+func TestDatabase_Corrupted(t *testing.T) {
+	db, mem := newTestDB(t, newFakeClock())
+	require.NoError(t, mem.Put("k", "\x01short"))
+	_, err := db.Get("k")
+	assert.True(t, sortedkv.IsCorrupted(err))
+
+	it := db.NewIterator()
+	assert.False(t, it.Next())
+	assert.True(t, sortedkv.IsCorrupted(it.Close()))
+}
+
+func TestDatabase_NotSupported(t *testing.T) {
+	db, err := New(struct{ sortedkv.Database }{memorydb.NewDatabase()}, WithSweepInterval(0))
+	require.NoError(t, err)
+	_, err = db.NewSnapshot()
+	assert.True(t, sortedkv.IsNotSupported(err))
+}
+
+func TestNew_EmptyIndexPrefix(t *testing.T) {
+	_, err := New(memorydb.NewDatabase(), WithIndexPrefix(""), WithSweepInterval(0))
+	assert.Error(t, err)
+
+	db, mem := newTestDB(t, newFakeClock(), WithIndexPrefix("idx."))
+	require.NoError(t, db.PutWithTTL("k", "v", time.Second))
+	assert.Len(t, test.ReadAll(t, sortedkv.NewTable(mem, "idx.")), 1)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ttl
+
+import (
+	"strings"
+	"time"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+type (
+	// store is the part of a database or snapshot that a view reads.
+	store interface {
+		sortedkv.Reader
+		sortedkv.Iterable
+	}
+
+	// view hides the expired keys and the expiry index of a store. It
+	// implements sortedkv.Reader and sortedkv.Iterable.
+	view struct {
+		store store
+		clock Clock
+		index string // Prefix of the expiry index.
+	}
+)
+
+// Has returns whether the key is present and not expired.
+func (v *view) Has(key string) (bool, error) {
+	_, _, err := v.lookup(key)
+	if sortedkv.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the value of a key that is not expired.
+func (v *view) Get(key string) (string, error) {
+	value, err := v.GetBytes(key)
+	return string(value), err
+}
+
+// GetBytes returns the value of a key that is not expired.
+func (v *view) GetBytes(key string) ([]byte, error) {
+	value, _, err := v.lookup(key)
+	return value, err
+}
+
+// lookup returns the value and expiry of a key that is not expired.
+func (v *view) lookup(key string) ([]byte, time.Time, error) {
+	if strings.HasPrefix(key, v.index) {
+		return nil, time.Time{}, &sortedkv.NotFoundError{Key: key}
+	}
+	data, err := v.store.GetBytes(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	value, expiry, err := decodeValue(data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if expired(expiry, v.clock.Now()) {
+		return nil, time.Time{}, &sortedkv.NotFoundError{Key: key}
+	}
+	return value, expiry, nil
+}
+
+// NewIterator creates an iterator over all keys that are not expired.
+func (v *view) NewIterator() sortedkv.Iterator {
+	return v.newIterator(v.store.NewIterator())
+}
+
+// NewIteratorWithRange creates an iterator over the keys in the range
+// [start, end) that are not expired.
+func (v *view) NewIteratorWithRange(start string, end string) sortedkv.Iterator {
+	return v.newIterator(v.store.NewIteratorWithRange(start, end))
+}
+
+// NewIteratorWithPrefix creates an iterator over the keys with the given
+// prefix that are not expired.
+func (v *view) NewIteratorWithPrefix(prefix string) sortedkv.Iterator {
+	return v.newIterator(v.store.NewIteratorWithPrefix(prefix))
+}
+
+func (v *view) newIterator(it sortedkv.Iterator) *iterator {
+	return &iterator{it: it, now: v.clock.Now(), index: v.index}
+}
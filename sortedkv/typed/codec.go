@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+type (
+	// KeyCodec encodes and decodes keys. The encoding must preserve order:
+	// EncodeKey(a) < EncodeKey(b) if and only if a < b in the natural order of
+	// K.
+	KeyCodec[K any] interface {
+		EncodeKey(key K) string
+		DecodeKey(data string) (K, error)
+	}
+
+	// ValueCodec encodes and decodes values.
+	ValueCodec[V any] interface {
+		EncodeValue(value V) ([]byte, error)
+		DecodeValue(data []byte) (V, error)
+	}
+)
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package typed provides typed tables on top of sortedkv databases. A
+// Table[K, V] encodes its keys with a KeyCodec[K] and its values with a
+// ValueCodec[V], so that users do not need to encode them by hand.
+//
+// Key codecs preserve order: the encoded keys sort in the natural order of
+// the keys. Therefore, iterators of a table return the entries in key order
+// and ranges of keys can be iterated. The package provides key codecs for
+// integers, big integers, strings, byte slices, timestamps and tuples of
+// keys, and value codecs based on gob, JSON and encoding/binary.
+package typed // import "polycry.pt/poly-go/sortedkv/typed"
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+import (
+	"encoding/binary"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Signed is the set of signed integer types.
+	Signed interface {
+		~int | ~int8 | ~int16 | ~int32 | ~int64
+	}
+
+	// Unsigned is the set of unsigned integer types.
+	Unsigned interface {
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+	}
+
+	// Int encodes signed integers as 8 bytes big-endian with a flipped sign
+	// bit.
+	Int[K Signed] struct{}
+
+	// Uint encodes unsigned integers as 8 bytes big-endian.
+	Uint[K Unsigned] struct{}
+
+	// String encodes strings as they are.
+	String[K ~string] struct{}
+
+	// Bytes encodes byte slices as they are.
+	Bytes struct{}
+
+	// BigInt encodes big integers of arbitrary size. A nil key is encoded like
+	// zero.
+	BigInt struct{}
+
+	// Time encodes timestamps with nanosecond precision. Decoded timestamps
+	// are in UTC and have no monotonic clock reading.
+	Time struct{}
+)
+
+const (
+	intSize  = 8
+	timeSize = 12
+
+	// Sign bytes of BigInt, ordered by sign.
+	bigIntNegative = 0x00
+	bigIntZero     = 0x01
+	bigIntPositive = 0x02
+	bigIntLenSize  = 4
+)
+
+// EncodeKey encodes a signed integer.
+func (Int[K]) EncodeKey(key K) string {
+	return string(appendInt(nil, int64(key)))
+}
+
+// DecodeKey decodes a signed integer.
+func (Int[K]) DecodeKey(data string) (K, error) {
+	if len(data) != intSize {
+		return 0, errors.Errorf("invalid integer length %d", len(data))
+	}
+	x := int64(binary.BigEndian.Uint64([]byte(data)) ^ (1 << 63)) // nolint: gomnd
+	if int64(K(x)) != x {
+		return 0, errors.Errorf("integer %d out of range", x)
+	}
+	return K(x), nil
+}
+
+// EncodeKey encodes an unsigned integer.
+func (Uint[K]) EncodeKey(key K) string {
+	var data [intSize]byte
+	binary.BigEndian.PutUint64(data[:], uint64(key))
+	return string(data[:])
+}
+
+// DecodeKey decodes an unsigned integer.
+func (Uint[K]) DecodeKey(data string) (K, error) {
+	if len(data) != intSize {
+		return 0, errors.Errorf("invalid integer length %d", len(data))
+	}
+	x := binary.BigEndian.Uint64([]byte(data))
+	if uint64(K(x)) != x {
+		return 0, errors.Errorf("integer %d out of range", x)
+	}
+	return K(x), nil
+}
+
+// EncodeKey returns the string.
+func (String[K]) EncodeKey(key K) string {
+	return string(key)
+}
+
+// DecodeKey returns the string.
+func (String[K]) DecodeKey(data string) (K, error) {
+	return K(data), nil
+}
+
+// EncodeKey returns the bytes as string.
+func (Bytes) EncodeKey(key []byte) string {
+	return string(key)
+}
+
+// DecodeKey returns the bytes of the string.
+func (Bytes) DecodeKey(data string) ([]byte, error) {
+	return []byte(data), nil
+}
+
+// EncodeKey encodes a big integer as a sign byte, followed by the length of
+// its magnitude as 4 bytes big-endian and the magnitude. The length and
+// magnitude of negative integers are inverted, so that larger magnitudes sort
+// first.
+func (BigInt) EncodeKey(key *big.Int) string {
+	if key == nil || key.Sign() == 0 {
+		return string([]byte{bigIntZero})
+	}
+
+	mag := key.Bytes()
+	data := make([]byte, 1+bigIntLenSize, 1+bigIntLenSize+len(mag))
+	binary.BigEndian.PutUint32(data[1:], uint32(len(mag)))
+	data = append(data, mag...)
+	if key.Sign() > 0 {
+		data[0] = bigIntPositive
+		return string(data)
+	}
+	data[0] = bigIntNegative
+	for i := 1; i < len(data); i++ {
+		data[i] = ^data[i]
+	}
+	return string(data)
+}
+
+// DecodeKey decodes a big integer.
+func (BigInt) DecodeKey(data string) (*big.Int, error) {
+	if len(data) == 1 && data[0] == bigIntZero {
+		return new(big.Int), nil
+	}
+	if len(data) < 1+bigIntLenSize || (data[0] != bigIntNegative && data[0] != bigIntPositive) {
+		return nil, errors.New("invalid big integer encoding")
+	}
+
+	buf := []byte(data[1:])
+	if data[0] == bigIntNegative {
+		for i := range buf {
+			buf[i] = ^buf[i]
+		}
+	}
+	length := binary.BigEndian.Uint32(buf)
+	mag := buf[bigIntLenSize:]
+	if uint64(length) != uint64(len(mag)) || len(mag) == 0 || mag[0] == 0 {
+		return nil, errors.New("invalid big integer encoding")
+	}
+
+	x := new(big.Int).SetBytes(mag)
+	if data[0] == bigIntNegative {
+		x.Neg(x)
+	}
+	return x, nil
+}
+
+// EncodeKey encodes a timestamp as its Unix seconds like Int, followed by the
+// nanoseconds as 4 bytes big-endian.
+func (Time) EncodeKey(key time.Time) string {
+	data := appendInt(make([]byte, 0, timeSize), key.Unix())
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[intSize:], uint32(key.Nanosecond()))
+	return string(data)
+}
+
+// DecodeKey decodes a timestamp.
+func (Time) DecodeKey(data string) (time.Time, error) {
+	if len(data) != timeSize {
+		return time.Time{}, errors.Errorf("invalid timestamp length %d", len(data))
+	}
+	sec, err := Int[int64]{}.DecodeKey(data[:intSize])
+	if err != nil {
+		return time.Time{}, err
+	}
+	nsec := binary.BigEndian.Uint32([]byte(data[intSize:]))
+	if nsec >= uint32(time.Second) {
+		return time.Time{}, errors.Errorf("invalid nanoseconds %d", nsec)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), nil
+}
+
+// appendInt appends x as 8 bytes big-endian with a flipped sign bit.
+func appendInt(data []byte, x int64) []byte {
+	var buf [intSize]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(x)^(1<<63)) // nolint: gomnd
+	return append(data, buf[:]...)
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv/key"
+	pkgtest "polycry.pt/poly-go/test"
+)
+
+const orderTestIterations = 1000
+
+// testOrder tests that codec preserves the order of random keys and that the
+// keys survive a round trip.
+func testOrder[K any](t *testing.T, codec KeyCodec[K], gen func(*rand.Rand) K, compare func(a, b K) int, equal func(a, b K) bool) {
+	t.Helper()
+	rng := pkgtest.Prng(t)
+	for i := 0; i < orderTestIterations; i++ {
+		a, b := gen(rng), gen(rng)
+		ea, eb := codec.EncodeKey(a), codec.EncodeKey(b)
+		require.Equal(t, compare(a, b), strings.Compare(ea, eb), "order of %v and %v", a, b)
+
+		decoded, err := codec.DecodeKey(ea)
+		require.NoError(t, err)
+		require.True(t, equal(a, decoded), "round trip of %v, got %v", a, decoded)
+	}
+}
+
+func compareInts[K Signed | Unsigned](a, b K) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func equal[K comparable](a, b K) bool { return a == b }
+
+func genInt64(rng *rand.Rand) int64 {
+	switch rng.Intn(4) {
+	case 0:
+		return rng.Int63n(10) - 5
+	case 1:
+		return []int64{math.MinInt64, math.MaxInt64, -1, 0}[rng.Intn(4)]
+	default:
+		return int64(rng.Uint64())
+	}
+}
+
+func genBigInt(rng *rand.Rand) *big.Int {
+	x := new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), uint(rng.Intn(200))))
+	if rng.Intn(2) == 0 {
+		x.Neg(x)
+	}
+	return x
+}
+
+func genString(rng *rand.Rand) string {
+	// Small alphabet with zero bytes, so that escaping and prefixes matter.
+	b := make([]byte, rng.Intn(5))
+	for i := range b {
+		b[i] = []byte{0x00, 0x01, 'a', 0xff}[rng.Intn(4)]
+	}
+	return string(b)
+}
+
+func TestInt(t *testing.T) {
+	testOrder[int64](t, Int[int64]{}, genInt64, compareInts[int64], equal[int64])
+	testOrder[int8](t, Int[int8]{}, func(rng *rand.Rand) int8 { return int8(rng.Uint32()) }, compareInts[int8], equal[int8])
+
+	_, err := Int[int8]{}.DecodeKey(Int[int64]{}.EncodeKey(1000))
+	assert.Error(t, err, "out of range")
+	_, err = Int[int64]{}.DecodeKey("short")
+	assert.Error(t, err, "invalid length")
+}
+
+func TestUint(t *testing.T) {
+	testOrder[uint64](t, Uint[uint64]{}, func(rng *rand.Rand) uint64 { return rng.Uint64() >> rng.Intn(64) }, compareInts[uint64], equal[uint64])
+
+	_, err := Uint[uint16]{}.DecodeKey(Uint[uint64]{}.EncodeKey(1 << 16))
+	assert.Error(t, err, "out of range")
+}
+
+func TestBigInt(t *testing.T) {
+	testOrder[*big.Int](t, BigInt{}, genBigInt, (*big.Int).Cmp, func(a, b *big.Int) bool { return a.Cmp(b) == 0 })
+
+	assert.Equal(t, BigInt{}.EncodeKey(new(big.Int)), BigInt{}.EncodeKey(nil))
+	for _, invalid := range []string{"", "\x03", "\x02\x00\x00\x00\x02\x01", "\x02\x00\x00\x00\x01\x00"} {
+		_, err := BigInt{}.DecodeKey(invalid)
+		assert.Error(t, err, "decoding %q", invalid)
+	}
+}
+
+func TestTime(t *testing.T) {
+	gen := func(rng *rand.Rand) time.Time {
+		return time.Unix(rng.Int63n(1<<40)-1<<39, rng.Int63n(int64(time.Second)))
+	}
+	compare := func(a, b time.Time) int {
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		default:
+			return 0
+		}
+	}
+	testOrder[time.Time](t, Time{}, gen, compare, time.Time.Equal)
+}
+
+func TestTuple(t *testing.T) {
+	codec2 := Tuple2Codec[string, int64]{String[string]{}, Int[int64]{}}
+	gen2 := func(rng *rand.Rand) Tuple2[string, int64] {
+		return Tuple2[string, int64]{genString(rng), rng.Int63n(5) - 2}
+	}
+	compare2 := func(a, b Tuple2[string, int64]) int {
+		if c := strings.Compare(a.V1, b.V1); c != 0 {
+			return c
+		}
+		return compareInts(a.V2, b.V2)
+	}
+	testOrder[Tuple2[string, int64]](t, codec2, gen2, compare2, equal[Tuple2[string, int64]])
+
+	codec3 := Tuple3Codec[string, string, *big.Int]{String[string]{}, String[string]{}, BigInt{}}
+	gen3 := func(rng *rand.Rand) Tuple3[string, string, *big.Int] {
+		return Tuple3[string, string, *big.Int]{genString(rng), genString(rng), big.NewInt(rng.Int63n(5) - 2)}
+	}
+	compare3 := func(a, b Tuple3[string, string, *big.Int]) int {
+		if c := strings.Compare(a.V1, b.V1); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.V2, b.V2); c != 0 {
+			return c
+		}
+		return a.V3.Cmp(b.V3)
+	}
+	equal3 := func(a, b Tuple3[string, string, *big.Int]) bool { return compare3(a, b) == 0 }
+	testOrder[Tuple3[string, string, *big.Int]](t, codec3, gen3, compare3, equal3)
+
+	for _, invalid := range []string{"", "a", "a\x00", "a\x00\x02\x00\x01", "a\x00\x01"} {
+		_, err := codec2.DecodeKey(invalid)
+		assert.Error(t, err, "decoding %q", invalid)
+	}
+	_, err := codec2.DecodeKey(codec2.EncodeKey(Tuple2[string, int64]{"a", 1}) + "x")
+	assert.Error(t, err, "trailing bytes")
+}
+
+func TestTuple_KeyTuple(t *testing.T) {
+	codec := Tuple2Codec[string, int64]{String[string]{}, Int[int64]{}}
+	start, end, err := key.Tuple{[]byte("a")}.Range()
+	require.NoError(t, err)
+
+	// The range of the first element contains exactly its tuples.
+	for _, tuple := range []Tuple2[string, int64]{{"a", -1}, {"a", 0}, {"a", 1 << 40}, {"a\x00", 0}, {"", 0}, {"b", 0}} {
+		k := codec.EncodeKey(tuple)
+		assert.Equal(t, tuple.V1 == "a", k >= start && k < end, "tuple %v", tuple)
+
+		decoded, err := key.Decode(k)
+		require.NoError(t, err)
+		assert.Equal(t, key.Tuple{[]byte(tuple.V1), []byte(Int[int64]{}.EncodeKey(tuple.V2))}, decoded)
+	}
+}
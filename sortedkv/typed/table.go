@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+import (
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv"
+)
+
+// Table is a typed view on a table of a database. Keys are encoded with a
+// KeyCodec and values with a ValueCodec.
+type Table[K, V any] struct {
+	db     sortedkv.Database
+	keys   KeyCodec[K]
+	values ValueCodec[V]
+}
+
+// NewTable creates a typed table on the table of db with the given prefix.
+func NewTable[K, V any](db sortedkv.Database, prefix string, keys KeyCodec[K], values ValueCodec[V]) *Table[K, V] {
+	return &Table[K, V]{
+		db:     sortedkv.NewTable(db, prefix),
+		keys:   keys,
+		values: values,
+	}
+}
+
+// Database returns the untyped table.
+func (t *Table[K, V]) Database() sortedkv.Database {
+	return t.db
+}
+
+// Has returns true if the table contains a key.
+func (t *Table[K, V]) Has(key K) (bool, error) {
+	return t.db.Has(t.keys.EncodeKey(key))
+}
+
+// Get returns the value of a key. If the key is not present, a
+// *sortedkv.NotFoundError is returned.
+func (t *Table[K, V]) Get(key K) (value V, err error) {
+	data, err := t.db.GetBytes(t.keys.EncodeKey(key))
+	if err != nil {
+		return value, err
+	}
+	return t.values.DecodeValue(data)
+}
+
+// Put inserts the value into the table. If the key is already present, it is
+// overwritten.
+func (t *Table[K, V]) Put(key K, value V) error {
+	data, err := t.values.EncodeValue(value)
+	if err != nil {
+		return err
+	}
+	return t.db.PutBytes(t.keys.EncodeKey(key), data)
+}
+
+// Delete removes a key from the table. If the key is not present, a
+// *sortedkv.NotFoundError is returned.
+func (t *Table[K, V]) Delete(key K) error {
+	return t.db.Delete(t.keys.EncodeKey(key))
+}
+
+// NewIterator creates an iterator over all entries, in key order.
+func (t *Table[K, V]) NewIterator() *Iterator[K, V] {
+	return t.newIterator(t.db.NewIterator())
+}
+
+// NewIteratorWithRange creates an iterator over the entries with keys in the
+// range [start, end), in key order.
+func (t *Table[K, V]) NewIteratorWithRange(start, end K) *Iterator[K, V] {
+	encodedEnd := t.keys.EncodeKey(end)
+	if encodedEnd == "" {
+		// No key sorts before the empty key, but an empty end of a sortedkv
+		// range has no upper bound.
+		return &Iterator[K, V]{}
+	}
+	return t.newIterator(t.db.NewIteratorWithRange(t.keys.EncodeKey(start), encodedEnd))
+}
+
+// NewIteratorFrom creates an iterator over the entries with keys that are
+// equal to or greater than start, in key order.
+func (t *Table[K, V]) NewIteratorFrom(start K) *Iterator[K, V] {
+	return t.newIterator(t.db.NewIteratorWithRange(t.keys.EncodeKey(start), ""))
+}
+
+func (t *Table[K, V]) newIterator(it sortedkv.Iterator) *Iterator[K, V] {
+	return &Iterator[K, V]{it: it, keys: t.keys, values: t.values}
+}
+
+// NewBatch creates a batch on the table.
+func (t *Table[K, V]) NewBatch() *Batch[K, V] {
+	return &Batch[K, V]{batch: t.db.NewBatch(), keys: t.keys, values: t.values}
+}
+
+// Batch is a typed batch. It buffers changes to a table until Apply is
+// called.
+type Batch[K, V any] struct {
+	batch  sortedkv.Batch
+	keys   KeyCodec[K]
+	values ValueCodec[V]
+}
+
+// Put puts a value into the batch.
+func (b *Batch[K, V]) Put(key K, value V) error {
+	data, err := b.values.EncodeValue(value)
+	if err != nil {
+		return err
+	}
+	return b.batch.PutBytes(b.keys.EncodeKey(key), data)
+}
+
+// Delete deletes a key in the batch.
+func (b *Batch[K, V]) Delete(key K) error {
+	return b.batch.Delete(b.keys.EncodeKey(key))
+}
+
+// Apply applies the batch atomically.
+func (b *Batch[K, V]) Apply() error {
+	return b.batch.Apply()
+}
+
+// Reset resets the batch so that it can be reused.
+func (b *Batch[K, V]) Reset() {
+	b.batch.Reset()
+}
+
+// Iterator is a typed iterator. It iterates over the entries of a table in
+// key order. An entry that cannot be decoded stops the iteration and its
+// error is returned by Close.
+type Iterator[K, V any] struct {
+	it     sortedkv.Iterator
+	keys   KeyCodec[K]
+	values ValueCodec[V]
+
+	key   K
+	value V
+	err   error
+}
+
+// Next moves the iterator to the next entry. It returns false if the iterator
+// is exhausted or failed.
+func (it *Iterator[K, V]) Next() bool {
+	if it.it == nil || it.err != nil || !it.it.Next() {
+		return false
+	}
+	if it.key, it.err = it.keys.DecodeKey(it.it.Key()); it.err != nil {
+		it.err = errors.WithMessagef(it.err, "decoding key %q", it.it.Key())
+		return false
+	}
+	if it.value, it.err = it.values.DecodeValue(it.it.ValueBytes()); it.err != nil {
+		return false
+	}
+	return true
+}
+
+// Key returns the key of the current entry.
+func (it *Iterator[K, V]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current entry.
+func (it *Iterator[K, V]) Value() V {
+	return it.value
+}
+
+// Close releases the iterator. It returns any accumulated error.
+func (it *Iterator[K, V]) Close() error {
+	if it.it == nil {
+		return nil
+	}
+	err := it.it.Close()
+	if it.err != nil {
+		return it.err
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"polycry.pt/poly-go/sortedkv"
+	"polycry.pt/poly-go/sortedkv/memorydb"
+)
+
+type testValue struct {
+	Name  string
+	Count int64
+}
+
+type testFixed struct {
+	A uint32
+	B int16
+}
+
+func TestTable(t *testing.T) {
+	db := memorydb.NewDatabase()
+	table := NewTable[int64, testValue](db, "values.", Int[int64]{}, JSON[testValue]{})
+
+	_, err := table.Get(1)
+	assert.True(t, sortedkv.IsNotFound(err), "expected NotFoundError, got %v", err)
+	for _, k := range []int64{3, -7, 0, 1 << 40, -1} {
+		require.NoError(t, table.Put(k, testValue{Name: "v", Count: k}))
+	}
+	has, err := table.Has(-7)
+	require.NoError(t, err)
+	assert.True(t, has)
+	v, err := table.Get(-7)
+	require.NoError(t, err)
+	assert.Equal(t, testValue{Name: "v", Count: -7}, v)
+	require.NoError(t, table.Delete(0))
+	assert.True(t, sortedkv.IsNotFound(table.Delete(0)))
+
+	assertEntries(t, table.NewIterator(), -7, -1, 3, 1<<40)
+	assertEntries(t, table.NewIteratorWithRange(-1, 1<<40), -1, 3)
+	assertEntries(t, table.NewIteratorFrom(0), 3, 1<<40)
+
+	batch := table.NewBatch()
+	require.NoError(t, batch.Put(5, testValue{Name: "v", Count: 5}))
+	require.NoError(t, batch.Delete(-7))
+	assertEntries(t, table.NewIterator(), -7, -1, 3, 1<<40)
+	require.NoError(t, batch.Apply())
+	assertEntries(t, table.NewIterator(), -1, 3, 5, 1<<40)
+
+	// The table is a table of the database.
+	n := 0
+	it := db.NewIteratorWithPrefix("values.")
+	for it.Next() {
+		n++
+	}
+	require.NoError(t, it.Close())
+	assert.Equal(t, 4, n)
+}
+
+func TestTable_DecodeError(t *testing.T) {
+	db := memorydb.NewDatabase()
+	table := NewTable[int64, testValue](db, "", Int[int64]{}, JSON[testValue]{})
+	require.NoError(t, table.Put(1, testValue{}))
+	require.NoError(t, db.Put("\xffinvalid key", "{}"))
+
+	it := table.NewIterator()
+	assert.True(t, it.Next())
+	assert.Equal(t, int64(1), it.Key())
+	assert.False(t, it.Next())
+	assert.Error(t, it.Close())
+
+	require.NoError(t, db.Put(Int[int64]{}.EncodeKey(2), "invalid value"))
+	_, err := table.Get(2)
+	assert.Error(t, err)
+}
+
+func TestTable_EmptyEnd(t *testing.T) {
+	table := NewTable[string, string](memorydb.NewDatabase(), "", String[string]{}, JSON[string]{})
+	require.NoError(t, table.Put("", "empty"))
+	require.NoError(t, table.Put("a", "a"))
+
+	it := table.NewIteratorWithRange("", "")
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Close())
+}
+
+func TestValueCodecs(t *testing.T) {
+	value := testValue{Name: "name", Count: -3}
+	testValueCodec[testValue](t, Gob[testValue]{}, value)
+	testValueCodec[testValue](t, JSON[testValue]{}, value)
+	testValueCodec[testFixed](t, Binary[testFixed]{}, testFixed{A: 7, B: -2})
+	testValueCodec[time.Duration](t, Binary[time.Duration]{}, time.Minute)
+
+	_, err := Binary[testValue]{}.EncodeValue(value)
+	assert.Error(t, err, "Binary needs fixed-size values")
+	_, err = Binary[uint16]{}.DecodeValue([]byte{1, 2, 3})
+	assert.Error(t, err, "trailing bytes")
+	_, err = JSON[testValue]{}.DecodeValue([]byte("{"))
+	assert.Error(t, err)
+	_, err = Gob[testValue]{}.DecodeValue([]byte("invalid"))
+	assert.Error(t, err)
+}
+
+func testValueCodec[V any](t *testing.T, codec ValueCodec[V], value V) {
+	t.Helper()
+	data, err := codec.EncodeValue(value)
+	require.NoError(t, err)
+	decoded, err := codec.DecodeValue(data)
+	require.NoError(t, err)
+	assert.Equal(t, value, decoded)
+}
+
+func assertEntries(t *testing.T, it *Iterator[int64, testValue], keys ...int64) {
+	t.Helper()
+	var actual []int64
+	for it.Next() {
+		assert.Equal(t, it.Key(), it.Value().Count)
+		actual = append(actual, it.Key())
+	}
+	require.NoError(t, it.Close())
+	assert.Equal(t, keys, actual)
+}
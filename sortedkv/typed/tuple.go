@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+import (
+	"github.com/pkg/errors"
+
+	"polycry.pt/poly-go/sortedkv/key"
+)
+
+type (
+	// Tuple2 is a key that consists of two keys. Tuples are ordered
+	// lexicographically.
+	Tuple2[T1, T2 any] struct {
+		V1 T1
+		V2 T2
+	}
+
+	// Tuple3 is a key that consists of three keys. Tuples are ordered
+	// lexicographically.
+	Tuple3[T1, T2, T3 any] struct {
+		V1 T1
+		V2 T2
+		V3 T3
+	}
+
+	// Tuple2Codec encodes Tuple2 keys by encoding the elements with their
+	// codecs. The encoded elements form a key.Tuple of byte slices, so the
+	// keys of all tuples with a given first element v1 lie in the range of
+	// key.Tuple{[]byte(C1.EncodeKey(v1))}.
+	Tuple2Codec[T1, T2 any] struct {
+		C1 KeyCodec[T1]
+		C2 KeyCodec[T2]
+	}
+
+	// Tuple3Codec encodes Tuple3 keys like Tuple2Codec.
+	Tuple3Codec[T1, T2, T3 any] struct {
+		C1 KeyCodec[T1]
+		C2 KeyCodec[T2]
+		C3 KeyCodec[T3]
+	}
+)
+
+// EncodeKey encodes a Tuple2.
+func (c Tuple2Codec[T1, T2]) EncodeKey(key Tuple2[T1, T2]) string {
+	return encodeTuple(c.C1.EncodeKey(key.V1), c.C2.EncodeKey(key.V2))
+}
+
+// DecodeKey decodes a Tuple2.
+func (c Tuple2Codec[T1, T2]) DecodeKey(data string) (key Tuple2[T1, T2], err error) {
+	elements, err := decodeTuple(data, 2) // nolint: gomnd
+	if err != nil {
+		return key, err
+	}
+	if err = decodeTupleElement(elements[0], c.C1, &key.V1); err != nil {
+		return key, err
+	}
+	return key, decodeTupleElement(elements[1], c.C2, &key.V2)
+}
+
+// EncodeKey encodes a Tuple3.
+func (c Tuple3Codec[T1, T2, T3]) EncodeKey(key Tuple3[T1, T2, T3]) string {
+	return encodeTuple(c.C1.EncodeKey(key.V1), c.C2.EncodeKey(key.V2), c.C3.EncodeKey(key.V3))
+}
+
+// DecodeKey decodes a Tuple3.
+func (c Tuple3Codec[T1, T2, T3]) DecodeKey(data string) (key Tuple3[T1, T2, T3], err error) {
+	elements, err := decodeTuple(data, 3) // nolint: gomnd
+	if err != nil {
+		return key, err
+	}
+	if err = decodeTupleElement(elements[0], c.C1, &key.V1); err != nil {
+		return key, err
+	}
+	if err = decodeTupleElement(elements[1], c.C2, &key.V2); err != nil {
+		return key, err
+	}
+	return key, decodeTupleElement(elements[2], c.C3, &key.V3)
+}
+
+// encodeTuple encodes the encoded elements as a key.Tuple of byte slices.
+func encodeTuple(elements ...string) string {
+	tuple := make(key.Tuple, len(elements))
+	for i, e := range elements {
+		tuple[i] = []byte(e)
+	}
+	data, err := tuple.Encode()
+	if err != nil {
+		panic(err) // Byte slices are always encodable.
+	}
+	return data
+}
+
+// decodeTuple decodes a key.Tuple of n byte slices.
+func decodeTuple(data string, n int) (key.Tuple, error) {
+	tuple, err := key.Decode(data)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decoding tuple")
+	}
+	if len(tuple) != n {
+		return nil, errors.Errorf("tuple has %d elements, expected %d", len(tuple), n)
+	}
+	return tuple, nil
+}
+
+// decodeTupleElement decodes an element of a tuple into v.
+func decodeTupleElement[T any](element interface{}, codec KeyCodec[T], v *T) error {
+	data, ok := element.([]byte)
+	if !ok {
+		return errors.Errorf("tuple element of type %T, expected []byte", element)
+	}
+	var err error
+	*v, err = codec.DecodeKey(string(data))
+	return errors.WithMessage(err, "decoding tuple element")
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Gob encodes values with encoding/gob.
+	Gob[V any] struct{}
+
+	// JSON encodes values with encoding/json.
+	JSON[V any] struct{}
+
+	// Binary encodes fixed-size values with encoding/binary in big-endian
+	// byte order.
+	Binary[V any] struct{}
+)
+
+// EncodeValue encodes a value with gob.
+func (Gob[V]) EncodeValue(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(value)
+	return buf.Bytes(), errors.WithMessage(err, "gob-encoding value")
+}
+
+// DecodeValue decodes a value with gob.
+func (Gob[V]) DecodeValue(data []byte) (value V, err error) {
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, errors.WithMessage(err, "gob-decoding value")
+}
+
+// EncodeValue encodes a value as JSON.
+func (JSON[V]) EncodeValue(value V) ([]byte, error) {
+	data, err := json.Marshal(value)
+	return data, errors.WithMessage(err, "JSON-encoding value")
+}
+
+// DecodeValue decodes a value from JSON.
+func (JSON[V]) DecodeValue(data []byte) (value V, err error) {
+	err = json.Unmarshal(data, &value)
+	return value, errors.WithMessage(err, "JSON-decoding value")
+}
+
+// EncodeValue encodes a value with encoding/binary.
+func (Binary[V]) EncodeValue(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	err := binary.Write(&buf, binary.BigEndian, value)
+	return buf.Bytes(), errors.WithMessage(err, "binary-encoding value")
+}
+
+// DecodeValue decodes a value with encoding/binary.
+func (Binary[V]) DecodeValue(data []byte) (value V, err error) {
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.BigEndian, &value); err != nil {
+		return value, errors.WithMessage(err, "binary-decoding value")
+	}
+	if r.Len() != 0 {
+		return value, errors.New("binary-decoding value: trailing bytes")
+	}
+	return value, nil
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import "context"
+
+// WatchBufferSize is the number of events that are buffered for a watcher. If
+// a watcher falls further behind, it receives an EventOverflow and its event
+// channel is closed.
+const WatchBufferSize = 1024
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventPut denotes that a value was put.
+	EventPut EventType = iota
+	// EventDelete denotes that a key was deleted.
+	EventDelete
+	// EventOverflow denotes that the watcher did not keep up with the writes.
+	// It is the last event of a watch and carries no key.
+	EventOverflow
+)
+
+// Event is a change of a key.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value string // The new value of a put.
+}
+
+// Watcher wraps the Watch method of a backing data store.
+type Watcher interface {
+	// Watch returns a channel that receives an Event for every change of a key
+	// with the given prefix, in commit order. The events of a batch or
+	// transaction are sent after it was applied, in the order of its
+	// operations. A range deletion results in a delete event for every deleted
+	// key.
+	//
+	// The channel is closed when the context is done or the data store is
+	// closed. Events are never blocked by a slow receiver: if more than
+	// WatchBufferSize events are pending, the receiver gets an EventOverflow
+	// and the channel is closed.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+}
+
+// WatchableDatabase is a Database that can be watched.
+type WatchableDatabase interface {
+	Database
+	Watcher
+}
+
+// prefixWatcher is implemented by the watchable database and tables. It
+// watches a prefix and trims the first trim bytes of the event keys, so that
+// nested tables do not need to rewrite the events.
+type prefixWatcher interface {
+	watch(ctx context.Context, prefix string, trim int) (<-chan Event, error)
+}
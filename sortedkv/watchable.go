@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"polycry.pt/poly-go/sortedkv/key"
+	polysync "polycry.pt/poly-go/sync"
+)
+
+type (
+	// watchable is a wrapper around a database that emits events for all
+	// writes that are made through it. Writes are serialized, so that the
+	// events are emitted in commit order.
+	watchable struct {
+		Database
+		mu     sync.Mutex // Serializes writes.
+		closer polysync.Closer
+
+		subsMu sync.Mutex // Protects subs and sending on their channels.
+		subs   map[*subscription]struct{}
+	}
+
+	// subscription is a single call to Watch.
+	subscription struct {
+		prefix string
+		trim   int
+		events chan Event    // Has one extra slot for the EventOverflow.
+		done   chan struct{} // Closed when the subscription ends.
+	}
+)
+
+// NewWatchable wraps a database so that it can be watched. Only writes that
+// are made through the returned database, including its tables, batches and
+// transactions, are reported. Closing the returned database ends all watches
+// and closes db.
+func NewWatchable(db Database) WatchableDatabase {
+	return &watchable{
+		Database: db,
+		subs:     make(map[*subscription]struct{}),
+	}
+}
+
+// Watch returns a channel that receives an Event for every change of a key
+// with the given prefix.
+func (w *watchable) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	return w.watch(ctx, prefix, 0)
+}
+
+func (w *watchable) watch(ctx context.Context, prefix string, trim int) (<-chan Event, error) {
+	sub := &subscription{
+		prefix: prefix,
+		trim:   trim,
+		events: make(chan Event, WatchBufferSize+1),
+		done:   make(chan struct{}),
+	}
+
+	w.subsMu.Lock()
+	if w.closer.IsClosed() {
+		w.subsMu.Unlock()
+		return nil, &ClosedError{}
+	}
+	w.subs[sub] = struct{}{}
+	w.subsMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.closer.Closed():
+		case <-sub.done:
+			return
+		}
+		w.subsMu.Lock()
+		defer w.subsMu.Unlock()
+		w.unsubscribe(sub)
+	}()
+	return sub.events, nil
+}
+
+// unsubscribe ends a subscription. w.subsMu must be held.
+func (w *watchable) unsubscribe(sub *subscription) {
+	if _, ok := w.subs[sub]; !ok {
+		return
+	}
+	delete(w.subs, sub)
+	close(sub.events)
+	close(sub.done)
+}
+
+// emit sends the events to all matching subscriptions. A subscription that
+// cannot take all its events receives an EventOverflow and ends.
+func (w *watchable) emit(events ...Event) {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+
+	for sub := range w.subs {
+		for _, e := range events {
+			if !strings.HasPrefix(e.Key, sub.prefix) {
+				continue
+			}
+			if len(sub.events) >= WatchBufferSize {
+				sub.events <- Event{Type: EventOverflow}
+				w.unsubscribe(sub)
+				break
+			}
+			e.Key = e.Key[sub.trim:]
+			sub.events <- e
+		}
+	}
+}
+
+// Put calls db.Put and emits an EventPut.
+func (w *watchable) Put(key, value string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.Database.Put(key, value); err != nil {
+		return err
+	}
+	w.emit(Event{Type: EventPut, Key: key, Value: value})
+	return nil
+}
+
+// PutBytes calls db.PutBytes and emits an EventPut.
+func (w *watchable) PutBytes(key string, value []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.Database.PutBytes(key, value); err != nil {
+		return err
+	}
+	w.emit(Event{Type: EventPut, Key: key, Value: string(value)})
+	return nil
+}
+
+// Delete calls db.Delete and emits an EventDelete.
+func (w *watchable) Delete(key string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.Database.Delete(key); err != nil {
+		return err
+	}
+	w.emit(Event{Type: EventDelete, Key: key})
+	return nil
+}
+
+// DeleteRange calls db.DeleteRange and emits an EventDelete for every deleted
+// key. It fails if the underlying database is not a RangeDeleter.
+func (w *watchable) DeleteRange(start string, end string) error {
+	db, ok := w.Database.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: "DeleteRange"}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Holding w.mu, the range cannot change until it is deleted.
+	events, err := w.rangeDeleteEvents(start, end, nil)
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteRange(start, end); err != nil {
+		return err
+	}
+	w.emit(events...)
+	return nil
+}
+
+// DeletePrefix calls db.DeletePrefix and emits an EventDelete for every
+// deleted key. It fails if the underlying database is not a RangeDeleter.
+func (w *watchable) DeletePrefix(prefix string) error {
+	return w.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// rangeDeleteEvents returns the events of deleting the range [start, end),
+// sorted by key. Keys in pending override the database: true means that the
+// key is present.
+func (w *watchable) rangeDeleteEvents(start, end string, pending map[string]bool) ([]Event, error) {
+	var keys []string
+	it := w.Database.NewIteratorWithRange(start, end)
+	for it.Next() {
+		if _, ok := pending[it.Key()]; !ok {
+			keys = append(keys, it.Key())
+		}
+	}
+	if err := it.Close(); err != nil {
+		return nil, err
+	}
+	for k, present := range pending {
+		if present && k >= start && (end == "" || k < end) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	events := make([]Event, len(keys))
+	for i, k := range keys {
+		events[i] = Event{Type: EventDelete, Key: k}
+	}
+	return events, nil
+}
+
+// NewBatch creates a batch that emits events when it is applied.
+func (w *watchable) NewBatch() Batch {
+	return &watchBatch{Batch: w.Database.NewBatch(), w: w}
+}
+
+// NewSnapshot calls db.NewSnapshot. It fails if the underlying database is
+// not a Snapshotter.
+func (w *watchable) NewSnapshot() (Snapshot, error) {
+	db, ok := w.Database.(Snapshotter)
+	if !ok {
+		return nil, &NotSupportedError{Op: "NewSnapshot"}
+	}
+	return db.NewSnapshot()
+}
+
+// NewTransaction creates a transaction that emits events when it is
+// committed. It fails if the underlying database is not a Transactor.
+func (w *watchable) NewTransaction() (Transaction, error) {
+	db, ok := w.Database.(Transactor)
+	if !ok {
+		return nil, &NotSupportedError{Op: "NewTransaction"}
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &watchTransaction{Transaction: tx, w: w}, nil
+}
+
+// CompactRange calls db.CompactRange. It fails if the underlying database is
+// not a Compacter.
+func (w *watchable) CompactRange(start string, end string) error {
+	db, ok := w.Database.(Compacter)
+	if !ok {
+		return &NotSupportedError{Op: "CompactRange"}
+	}
+	return db.CompactRange(start, end)
+}
+
+// ApproximateSize calls db.ApproximateSize. It fails if the underlying
+// database is not a Stater.
+func (w *watchable) ApproximateSize(start string, end string) (int64, error) {
+	db, ok := w.Database.(Stater)
+	if !ok {
+		return 0, &NotSupportedError{Op: "ApproximateSize"}
+	}
+	return db.ApproximateSize(start, end)
+}
+
+// Stats calls db.Stats. It fails if the underlying database is not a Stater.
+func (w *watchable) Stats() (map[string]string, error) {
+	db, ok := w.Database.(Stater)
+	if !ok {
+		return nil, &NotSupportedError{Op: "Stats"}
+	}
+	return db.Stats()
+}
+
+// Close ends all watches and closes the underlying database.
+func (w *watchable) Close() error {
+	w.subsMu.Lock()
+	// An already closed closer results in the database's error below.
+	_ = w.closer.Close()
+	for sub := range w.subs {
+		w.unsubscribe(sub)
+	}
+	w.subsMu.Unlock()
+	return w.Database.Close()
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+import "polycry.pt/poly-go/sortedkv/key"
+
+// watchBatch is a wrapper around a batch of a watchable database. It records
+// its operations to emit the events when it is applied.
+type watchBatch struct {
+	Batch
+	w   *watchable
+	ops []watchOp
+}
+
+type (
+	// watchOp is a recorded operation of a watchBatch.
+	watchOp struct {
+		kind  watchOpKind
+		key   string
+		value string
+		end   string // End of the range of a range deletion.
+	}
+
+	watchOpKind int
+)
+
+const (
+	watchPut watchOpKind = iota
+	watchDelete
+	watchDeleteRange
+)
+
+// Put calls batch.Put and records the operation.
+func (b *watchBatch) Put(key, value string) error {
+	if err := b.Batch.Put(key, value); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, watchOp{kind: watchPut, key: key, value: value})
+	return nil
+}
+
+// PutBytes calls batch.PutBytes and records the operation.
+func (b *watchBatch) PutBytes(key string, value []byte) error {
+	if err := b.Batch.PutBytes(key, value); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, watchOp{kind: watchPut, key: key, value: string(value)})
+	return nil
+}
+
+// Delete calls batch.Delete and records the operation.
+func (b *watchBatch) Delete(key string) error {
+	if err := b.Batch.Delete(key); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, watchOp{kind: watchDelete, key: key})
+	return nil
+}
+
+// DeleteRange calls batch.DeleteRange and records the operation. It fails if
+// the underlying batch is not a RangeDeleter.
+func (b *watchBatch) DeleteRange(start string, end string) error {
+	batch, ok := b.Batch.(RangeDeleter)
+	if !ok {
+		return &NotSupportedError{Op: "DeleteRange"}
+	}
+	if err := batch.DeleteRange(start, end); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, watchOp{kind: watchDeleteRange, key: start, end: end})
+	return nil
+}
+
+// DeletePrefix calls batch.DeletePrefix and records the operation. It fails
+// if the underlying batch is not a RangeDeleter.
+func (b *watchBatch) DeletePrefix(prefix string) error {
+	return b.DeleteRange(prefix, key.IncPrefix(prefix))
+}
+
+// Apply applies the batch and emits its events.
+func (b *watchBatch) Apply() error {
+	b.w.mu.Lock()
+	defer b.w.mu.Unlock()
+
+	// Holding w.mu, the database cannot change until the batch is applied.
+	events, err := b.events()
+	if err != nil {
+		return err
+	}
+	if err := b.Batch.Apply(); err != nil {
+		return err
+	}
+	b.w.emit(events...)
+	return nil
+}
+
+// Reset calls batch.Reset and drops the recorded operations.
+func (b *watchBatch) Reset() {
+	b.Batch.Reset()
+	b.ops = nil
+}
+
+// events returns the events of applying the batch to the current state of the
+// database. Deletions of absent keys have no event. b.w.mu must be held.
+func (b *watchBatch) events() ([]Event, error) {
+	var events []Event
+	// pending holds whether the keys that the batch wrote are present.
+	pending := make(map[string]bool)
+	for _, op := range b.ops {
+		switch op.kind {
+		case watchDeleteRange:
+			deletes, err := b.w.rangeDeleteEvents(op.key, op.end, pending)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range deletes {
+				pending[e.Key] = false
+			}
+			events = append(events, deletes...)
+		case watchPut:
+			pending[op.key] = true
+			events = append(events, Event{Type: EventPut, Key: op.key, Value: op.value})
+		case watchDelete:
+			present, ok := pending[op.key]
+			if !ok {
+				var err error
+				if present, err = b.w.Database.Has(op.key); err != nil {
+					return nil, err
+				}
+			}
+			if present {
+				events = append(events, Event{Type: EventDelete, Key: op.key})
+			}
+			pending[op.key] = false
+		}
+	}
+	return events, nil
+}
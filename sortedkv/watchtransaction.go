@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sortedkv
+
+// watchTransaction is a wrapper around a transaction of a watchable database.
+// It records its writes to emit the events when it is committed.
+type watchTransaction struct {
+	Transaction
+	w      *watchable
+	events []Event
+}
+
+// Put calls tx.Put and records the event.
+func (tx *watchTransaction) Put(key, value string) error {
+	if err := tx.Transaction.Put(key, value); err != nil {
+		return err
+	}
+	tx.events = append(tx.events, Event{Type: EventPut, Key: key, Value: value})
+	return nil
+}
+
+// PutBytes calls tx.PutBytes and records the event.
+func (tx *watchTransaction) PutBytes(key string, value []byte) error {
+	if err := tx.Transaction.PutBytes(key, value); err != nil {
+		return err
+	}
+	tx.events = append(tx.events, Event{Type: EventPut, Key: key, Value: string(value)})
+	return nil
+}
+
+// Delete calls tx.Delete and records the event. Since tx.Delete fails for
+// absent keys, only deletions of present keys are recorded.
+func (tx *watchTransaction) Delete(key string) error {
+	if err := tx.Transaction.Delete(key); err != nil {
+		return err
+	}
+	tx.events = append(tx.events, Event{Type: EventDelete, Key: key})
+	return nil
+}
+
+// Commit commits the transaction and emits its events.
+func (tx *watchTransaction) Commit() error {
+	tx.w.mu.Lock()
+	defer tx.w.mu.Unlock()
+
+	if err := tx.Transaction.Commit(); err != nil {
+		return err
+	}
+	tx.w.emit(tx.events...)
+	tx.events = nil
+	return nil
+}
+
+// Discard discards the transaction and its events.
+func (tx *watchTransaction) Discard() {
+	tx.Transaction.Discard()
+	tx.events = nil
+}